@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"ksniff/pkg/cri"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// ContainerInspector resolves container details using the api of the node container runtime.
+type ContainerInspector interface {
+	InspectContainer(ctx context.Context, containerId string) (*cri.ContainerInfo, error)
+	Close() error
+}
+
+// ksniff-helper ships in the privileged pod helper image, it talks to the container runtime
+// over its socket instead of relying on crictl, jq or the docker cli being available on the node or image.
+func main() {
+	var runtimeEndpoint string
+	var runtimeApi string
+	var timeout time.Duration
+	var output string
+
+	root := &cobra.Command{
+		Use:          "ksniff-helper",
+		Short:        "Resolve container details using the api of the node container runtime.",
+		SilenceUsage: true,
+	}
+
+	root.PersistentFlags().StringVarP(&runtimeEndpoint, "runtime-endpoint", "e", "unix:///run/containerd/containerd.sock",
+		"the container runtime endpoint")
+	root.PersistentFlags().StringVarP(&runtimeApi, "api", "a", "cri",
+		"the container runtime api exposed by the endpoint, one of: cri, docker")
+	root.PersistentFlags().DurationVarP(&timeout, "timeout", "t", 30*time.Second,
+		"the length of time to wait for the container runtime to respond")
+
+	inspect := &cobra.Command{
+		Use:   "inspect CONTAINER_ID",
+		Short: "Print the process id and network namespace path of a container.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			inspector, err := newContainerInspector(ctx, runtimeApi, runtimeEndpoint, timeout)
+			if err != nil {
+				return err
+			}
+			defer inspector.Close()
+
+			info, err := inspector.InspectContainer(ctx, args[0])
+			if err != nil {
+				return err
+			}
+
+			return printContainerInfo(info, output)
+		},
+	}
+	inspect.Flags().StringVarP(&output, "output", "o", "json", "output format, one of: json, pid, netns")
+
+	root.AddCommand(inspect)
+
+	if err := root.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func newContainerInspector(ctx context.Context, runtimeApi string, runtimeEndpoint string, timeout time.Duration) (ContainerInspector, error) {
+	switch runtimeApi {
+	case "cri":
+		return cri.NewClient(ctx, runtimeEndpoint, timeout)
+	case "docker":
+		return cri.NewDockerClient(runtimeEndpoint, timeout), nil
+	default:
+		return nil, errors.Errorf("unknown container runtime api: '%s'", runtimeApi)
+	}
+}
+
+func printContainerInfo(info *cri.ContainerInfo, output string) error {
+	switch output {
+	case "json":
+		return json.NewEncoder(os.Stdout).Encode(info)
+	case "pid":
+		fmt.Println(info.Pid)
+	case "netns":
+		fmt.Println(info.NetworkNamespacePath)
+	default:
+		return errors.Errorf("unknown output format: '%s'", output)
+	}
+
+	return nil
+}
@@ -19,11 +19,15 @@ require (
 	github.com/spf13/pflag v1.0.5
 	github.com/spf13/viper v1.7.0
 	github.com/stretchr/testify v1.6.1
+	golang.org/x/net v0.7.0
+	golang.org/x/term v0.5.0
+	google.golang.org/grpc v1.40.0
 	gopkg.in/ini.v1 v1.51.1 // indirect
 	k8s.io/api v0.20.6
 	k8s.io/apimachinery v0.20.6
 	k8s.io/cli-runtime v0.20.6
 	k8s.io/client-go v0.20.6
+	k8s.io/cri-api v0.20.6
 )
 
-go 1.13
+go 1.16
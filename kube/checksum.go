@@ -0,0 +1,75 @@
+package kube
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const contentAddressLength = 12
+
+// FileChecksums holds the digests of a local file, compared with the output of the digest tools available on
+// the remote container.
+type FileChecksums struct {
+	Sha256 string
+	Md5    string
+}
+
+func ComputeChecksums(reader io.Reader) (FileChecksums, error) {
+	sha256Hash := sha256.New()
+	md5Hash := md5.New()
+
+	if _, err := io.Copy(io.MultiWriter(sha256Hash, md5Hash), reader); err != nil {
+		return FileChecksums{}, err
+	}
+
+	return FileChecksums{
+		Sha256: hex.EncodeToString(sha256Hash.Sum(nil)),
+		Md5:    hex.EncodeToString(md5Hash.Sum(nil)),
+	}, nil
+}
+
+// ComputeUploadChecksums computes the checksums of the content if set, otherwise of the local file.
+func ComputeUploadChecksums(localPath string, content []byte) (FileChecksums, error) {
+	if content != nil {
+		return ComputeChecksums(bytes.NewReader(content))
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return FileChecksums{}, err
+	}
+	defer file.Close()
+
+	return ComputeChecksums(file)
+}
+
+// ContentAddressedPath suffixes the remote path with the file checksum, so different versions of a file
+// are never mistaken for one another.
+func ContentAddressedPath(remotePath string, checksums FileChecksums) string {
+	return fmt.Sprintf("%s-%s", remotePath, checksums.Sha256[:contentAddressLength])
+}
+
+// remoteChecksumTools lists the digest tools looked up on the remote container, by order of preference.
+var remoteChecksumTools = []struct {
+	command  string
+	checksum func(FileChecksums) string
+}{
+	{command: "sha256sum", checksum: func(c FileChecksums) string { return c.Sha256 }},
+	{command: "md5sum", checksum: func(c FileChecksums) string { return c.Md5 }},
+}
+
+// parseChecksumOutput extracts the digest from the '<digest>  <path>' output of sha256sum and md5sum.
+func parseChecksumOutput(output string) string {
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	return strings.ToLower(fields[0])
+}
@@ -0,0 +1,45 @@
+package kube
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeChecksums(t *testing.T) {
+	// when
+	result, err := ComputeChecksums(strings.NewReader("ksniff"))
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, "05f6011df9fc928707d2ae7c7d6d2ddd5100195fd0baa90af9b00f4ec1935acc", result.Sha256)
+	assert.Equal(t, "3bf76dc8bfe6f24049d6c2b8ecef8e2f", result.Md5)
+}
+
+func TestContentAddressedPath(t *testing.T) {
+	// given
+	checksums, _ := ComputeChecksums(strings.NewReader("ksniff"))
+
+	// when
+	result := ContentAddressedPath("/tmp/static-tcpdump", checksums)
+
+	// then
+	assert.Equal(t, "/tmp/static-tcpdump-05f6011df9fc", result)
+}
+
+func TestParseChecksumOutput(t *testing.T) {
+	// when
+	result := parseChecksumOutput("3B5D5C3712955042212316173CCF37BE  /tmp/static-tcpdump\n")
+
+	// then
+	assert.Equal(t, "3b5d5c3712955042212316173ccf37be", result)
+}
+
+func TestParseChecksumOutput_Empty(t *testing.T) {
+	// when
+	result := parseChecksumOutput("")
+
+	// then
+	assert.Equal(t, "", result)
+}
@@ -0,0 +1,157 @@
+package kube
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// FilterResolver resolves the kubernetes references of capture filters using the live api.
+type FilterResolver struct {
+	clientset       kubernetes.Interface
+	targetNamespace string
+	targetPod       *corev1.Pod
+}
+
+func NewFilterResolver(clientset kubernetes.Interface, targetNamespace string, targetPod *corev1.Pod) *FilterResolver {
+	return &FilterResolver{clientset: clientset, targetNamespace: targetNamespace, targetPod: targetPod}
+}
+
+func (r *FilterResolver) namespace(namespace string) string {
+	if namespace == "" {
+		return r.targetNamespace
+	}
+
+	return namespace
+}
+
+func podIPs(pod *corev1.Pod) []string {
+	var ips []string
+	for _, podIP := range pod.Status.PodIPs {
+		ips = append(ips, podIP.IP)
+	}
+
+	if len(ips) == 0 && pod.Status.PodIP != "" {
+		ips = append(ips, pod.Status.PodIP)
+	}
+
+	return ips
+}
+
+func (r *FilterResolver) PodIPs(namespace string, name string) ([]string, error) {
+	pod, err := r.clientset.CoreV1().Pods(r.namespace(namespace)).Get(context.TODO(), name, v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return podIPs(pod), nil
+}
+
+func (r *FilterResolver) ServiceIPs(namespace string, name string) ([]string, error) {
+	service, err := r.clientset.CoreV1().Services(r.namespace(namespace)).Get(context.TODO(), name, v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	// ClusterIPs includes ClusterIP, which is the only one set by older api servers
+	clusterIPs := service.Spec.ClusterIPs
+	if len(clusterIPs) == 0 {
+		clusterIPs = []string{service.Spec.ClusterIP}
+	}
+
+	var ips []string
+	for _, clusterIP := range clusterIPs {
+		if clusterIP != "" && clusterIP != corev1.ClusterIPNone {
+			ips = append(ips, clusterIP)
+		}
+	}
+
+	// the packets of the service clients and its pods carry the endpoint ips
+	endpoints, err := r.clientset.CoreV1().Endpoints(r.namespace(namespace)).Get(context.TODO(), name, v1.GetOptions{})
+	if err != nil {
+		log.WithError(err).Debugf("failed to get endpoints of service: '%s'", name)
+		return ips, nil
+	}
+
+	for _, subset := range endpoints.Subsets {
+		for _, address := range subset.Addresses {
+			ips = append(ips, address.IP)
+		}
+	}
+
+	return ips, nil
+}
+
+func (r *FilterResolver) listPodIPs(namespace string, selector string) ([]string, error) {
+	pods, err := r.clientset.CoreV1().Pods(namespace).List(context.TODO(), v1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []string
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+
+		// host network pods have the node ip, matching it would capture all of the node traffic
+		if pod.Spec.HostNetwork {
+			log.Debugf("skipping host network pod: '%s'", pod.Name)
+			continue
+		}
+
+		ips = append(ips, podIPs(pod)...)
+	}
+
+	return ips, nil
+}
+
+func (r *FilterResolver) NamespaceIPs(namespace string) ([]string, error) {
+	return r.listPodIPs(r.namespace(namespace), "")
+}
+
+func (r *FilterResolver) LabelSelectorIPs(namespace string, selector string) ([]string, error) {
+	return r.listPodIPs(r.namespace(namespace), selector)
+}
+
+// NamedPorts returns the target pod container ports with the given name, or the ports of the target namespace
+// services with that name when the target pod has none.
+func (r *FilterResolver) NamedPorts(name string) ([]int, error) {
+	var ports []int
+
+	if r.targetPod != nil {
+		for _, container := range r.targetPod.Spec.Containers {
+			for _, port := range container.Ports {
+				if port.Name == name {
+					ports = append(ports, int(port.ContainerPort))
+				}
+			}
+		}
+	}
+
+	if len(ports) > 0 {
+		return ports, nil
+	}
+
+	services, err := r.clientset.CoreV1().Services(r.targetNamespace).List(context.TODO(), v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, service := range services.Items {
+		for _, port := range service.Spec.Ports {
+			if port.Name != name {
+				continue
+			}
+
+			ports = append(ports, int(port.Port))
+			if port.TargetPort.Type == intstr.Int && port.TargetPort.IntVal != 0 {
+				ports = append(ports, int(port.TargetPort.IntVal))
+			}
+		}
+	}
+
+	return ports, nil
+}
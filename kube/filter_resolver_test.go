@@ -0,0 +1,72 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func buildPod(name string, labels map[string]string, ip string, hostNetwork bool) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+		Spec:       corev1.PodSpec{HostNetwork: hostNetwork},
+		Status:     corev1.PodStatus{PodIP: ip, PodIPs: []corev1.PodIP{{IP: ip}}},
+	}
+}
+
+func TestFilterResolver_LabelSelectorIPs(t *testing.T) {
+	// given
+	clientset := fake.NewSimpleClientset(
+		buildPod("redis-0", map[string]string{"app": "redis"}, "10.0.0.1", false),
+		buildPod("redis-1", map[string]string{"app": "redis"}, "10.0.0.2", false),
+		buildPod("node-exporter", map[string]string{"app": "redis"}, "192.168.1.10", true),
+		buildPod("web", map[string]string{"app": "web"}, "10.0.0.3", false))
+	resolver := NewFilterResolver(clientset, "default", nil)
+
+	// when
+	ips, err := resolver.LabelSelectorIPs("", "app=redis")
+
+	// then
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"10.0.0.1", "10.0.0.2"}, ips)
+}
+
+func TestFilterResolver_ServiceIPs(t *testing.T) {
+	// given
+	clientset := fake.NewSimpleClientset(
+		&corev1.Service{ObjectMeta: v1.ObjectMeta{Name: "payments", Namespace: "default"},
+			Spec: corev1.ServiceSpec{ClusterIP: "10.96.0.10", ClusterIPs: []string{"10.96.0.10"}}},
+		&corev1.Endpoints{ObjectMeta: v1.ObjectMeta{Name: "payments", Namespace: "default"},
+			Subsets: []corev1.EndpointSubset{{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.5"}, {IP: "10.0.0.6"}}}}})
+	resolver := NewFilterResolver(clientset, "default", nil)
+
+	// when
+	ips, err := resolver.ServiceIPs("", "payments")
+
+	// then
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"10.96.0.10", "10.0.0.5", "10.0.0.6"}, ips)
+}
+
+func TestFilterResolver_NamedPorts(t *testing.T) {
+	// given
+	targetPod := buildPod("web", nil, "10.0.0.3", false)
+	targetPod.Spec.Containers = []corev1.Container{{Name: "web", Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}}}}
+	clientset := fake.NewSimpleClientset(&corev1.Service{ObjectMeta: v1.ObjectMeta{Name: "redis", Namespace: "default"},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "redis", Port: 6379, TargetPort: intstr.FromInt(16379)}}}})
+	resolver := NewFilterResolver(clientset, "default", targetPod)
+
+	// when
+	containerPorts, containerErr := resolver.NamedPorts("http")
+	servicePorts, serviceErr := resolver.NamedPorts("redis")
+
+	// then
+	assert.Nil(t, containerErr)
+	assert.Equal(t, []int{8080}, containerPorts)
+	assert.Nil(t, serviceErr)
+	assert.Equal(t, []int{6379, 16379}, servicePorts)
+}
@@ -0,0 +1,163 @@
+package kube
+
+import (
+	"context"
+	"time"
+
+	"ksniff/pkg/filter"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// filterRefreshDelay groups the changes of a rollout, which updates several pods and endpoints in a short time.
+var filterRefreshDelay = 2 * time.Second
+
+const watchRetryDelay = 5 * time.Second
+
+// FilterWatcher watches the pods and endpoints referenced by a kubernetes-aware filter, and notifies the expanded
+// filter when the referenced ips change.
+type FilterWatcher struct {
+	clientset       kubernetes.Interface
+	targetNamespace string
+	resolver        filter.Resolver
+	expression      string
+	expandedFilter  string
+}
+
+func NewFilterWatcher(clientset kubernetes.Interface, targetNamespace string, resolver filter.Resolver,
+	expression string, expandedFilter string) *FilterWatcher {
+
+	return &FilterWatcher{clientset: clientset, targetNamespace: targetNamespace, resolver: resolver,
+		expression: expression, expandedFilter: expandedFilter}
+}
+
+// watchedNamespaces returns the namespaces whose pods and endpoints are referenced by the filter.
+func (w *FilterWatcher) watchedNamespaces() (podNamespaces map[string]bool, endpointNamespaces map[string]bool) {
+	podNamespaces, endpointNamespaces = map[string]bool{}, map[string]bool{}
+
+	for _, reference := range filter.FindReferences(w.expression) {
+		namespace := reference.Namespace
+		if namespace == "" {
+			namespace = w.targetNamespace
+		}
+
+		switch reference.Kind {
+		case filter.PodReference, filter.NamespaceReference, filter.LabelReference:
+			podNamespaces[namespace] = true
+		case filter.ServiceReference:
+			endpointNamespaces[namespace] = true
+		}
+	}
+
+	return podNamespaces, endpointNamespaces
+}
+
+// Watch notifies the updated expanded filter until the context is done.
+func (w *FilterWatcher) Watch(ctx context.Context) <-chan string {
+	events := make(chan struct{}, 1)
+	updates := make(chan string)
+
+	podNamespaces, endpointNamespaces := w.watchedNamespaces()
+
+	for namespace := range podNamespaces {
+		pods := w.clientset.CoreV1().Pods(namespace)
+		go w.watchEvents(ctx, events, "pods", namespace, func() (watch.Interface, error) {
+			return pods.Watch(ctx, v1.ListOptions{})
+		})
+	}
+
+	for namespace := range endpointNamespaces {
+		endpoints := w.clientset.CoreV1().Endpoints(namespace)
+		go w.watchEvents(ctx, events, "endpoints", namespace, func() (watch.Interface, error) {
+			return endpoints.Watch(ctx, v1.ListOptions{})
+		})
+	}
+
+	go w.refresh(ctx, events, updates)
+
+	return updates
+}
+
+// watchEvents signals the events of the watched resource, watching again when the watch ends.
+func (w *FilterWatcher) watchEvents(ctx context.Context, events chan<- struct{}, resource string, namespace string,
+	startWatch func() (watch.Interface, error)) {
+
+	for ctx.Err() == nil {
+		watcher, err := startWatch()
+		if err != nil {
+			log.WithError(err).Warnf("failed to watch %s of namespace: '%s', capture filter won't be refreshed", resource, namespace)
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(watchRetryDelay):
+			}
+
+			continue
+		}
+
+		log.Debugf("watching %s of namespace: '%s'", resource, namespace)
+
+		for {
+			var ok bool
+
+			select {
+			case <-ctx.Done():
+			case _, ok = <-watcher.ResultChan():
+			}
+
+			if !ok {
+				break
+			}
+
+			select {
+			case events <- struct{}{}:
+			default:
+			}
+		}
+
+		watcher.Stop()
+	}
+}
+
+// refresh expands the filter after the watched resources changed, and notifies it when it's different.
+func (w *FilterWatcher) refresh(ctx context.Context, events <-chan struct{}, updates chan<- string) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-events:
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(filterRefreshDelay):
+		}
+
+		// drops the events received while waiting, the expansion below reflects them
+		select {
+		case <-events:
+		default:
+		}
+
+		expandedFilter, err := filter.Expand(w.expression, w.resolver)
+		if err != nil {
+			log.WithError(err).Warnf("failed to refresh capture filter, keeping: '%s'", w.expandedFilter)
+			continue
+		}
+
+		if expandedFilter == w.expandedFilter {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case updates <- expandedFilter:
+			w.expandedFilter = expandedFilter
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package kube
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestFilterWatcher_NotifiesChangedIPs(t *testing.T) {
+	// given
+	defer func(delay time.Duration) { filterRefreshDelay = delay }(filterRefreshDelay)
+	filterRefreshDelay = 10 * time.Millisecond
+
+	clientset := fake.NewSimpleClientset(buildPod("redis-0", map[string]string{"app": "redis"}, "10.0.0.1", false))
+
+	watchStarted := make(chan struct{}, 1)
+	clientset.PrependWatchReactor("pods", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		watcher, err := clientset.Tracker().Watch(action.GetResource(), action.GetNamespace())
+		select {
+		case watchStarted <- struct{}{}:
+		default:
+		}
+		return true, watcher, err
+	})
+
+	watcher := NewFilterWatcher(clientset, "default", NewFilterResolver(clientset, "default", nil),
+		"label:app=redis", "host 10.0.0.1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := watcher.Watch(ctx)
+
+	// the pods created before the watch started wouldn't be notified
+	select {
+	case <-watchStarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pods watch wasn't started")
+	}
+
+	// when
+	_, err := clientset.CoreV1().Pods("default").Create(ctx,
+		buildPod("redis-1", map[string]string{"app": "redis"}, "10.0.0.2", false), v1.CreateOptions{})
+	assert.Nil(t, err)
+
+	// then
+	select {
+	case update := <-updates:
+		assert.Equal(t, "(host 10.0.0.1 or host 10.0.0.2)", update)
+	case <-time.After(5 * time.Second):
+		t.Fatal("capture filter update wasn't notified")
+	}
+}
@@ -4,10 +4,8 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"strings"
 	"time"
 
-	"ksniff/pkg/service/sniffer/runtime"
 	"ksniff/utils"
 
 	"github.com/pkg/errors"
@@ -21,11 +19,19 @@ import (
 type KubernetesApiService interface {
 	ExecuteCommand(podName string, containerName string, command []string, stdOut io.Writer) (int, error)
 
+	// ExecuteCommandWithStdErr executes the command like ExecuteCommand while also writing its stderr to stdErr,
+	// allowing callers to report the actual failure reason.
+	ExecuteCommandWithStdErr(podName string, containerName string, command []string, stdOut io.Writer, stdErr io.Writer) (int, error)
+
 	DeletePod(podName string) error
 
 	CreatePrivilegedPod(nodeName string, containerName string, image string, socketPath string, timeout time.Duration) (*corev1.Pod, error)
 
-	UploadFile(localPath string, remotePath string, podName string, containerName string) error
+	// UploadFile uploads the local file to the container, the returned flag is false when a file with a matching
+	// checksum was already found on the container and the upload was skipped.
+	UploadFile(localPath string, remotePath string, podName string, containerName string) (bool, error)
+
+	UploadFileContent(content []byte, remotePath string, podName string, containerName string) (bool, error)
 }
 
 type KubernetesApiServiceImpl struct {
@@ -42,27 +48,26 @@ func NewKubernetesApiService(clientset *kubernetes.Clientset,
 		targetNamespace: targetNamespace}
 }
 
-func (k *KubernetesApiServiceImpl) IsSupportedContainerRuntime(nodeName string) (bool, error) {
-	node, err := k.clientset.CoreV1().Nodes().Get(context.TODO(), nodeName, v1.GetOptions{})
-	if err != nil {
-		return false, err
-	}
+func (k *KubernetesApiServiceImpl) ExecuteCommand(podName string, containerName string, command []string, stdOut io.Writer) (int, error) {
+	stdErr := new(Writer)
 
-	nodeRuntimeVersion := node.Status.NodeInfo.ContainerRuntimeVersion
+	exitCode, err := k.ExecuteCommandWithStdErr(podName, containerName, command, stdOut, stdErr)
+	if err != nil {
+		log.WithError(err).Errorf("failed executing command: '%s', exitCode: '%d', stdErr: '%s'",
+			command, exitCode, stdErr.Output)
 
-	for _, runtime := range runtime.SupportedContainerRuntimes {
-		if strings.HasPrefix(nodeRuntimeVersion, runtime) {
-			return true, nil
-		}
+		return exitCode, err
 	}
 
-	return false, nil
+	log.Infof("command: '%s' executing successfully exitCode: '%d', stdErr :'%s'", command, exitCode, stdErr.Output)
+
+	return exitCode, err
 }
 
-func (k *KubernetesApiServiceImpl) ExecuteCommand(podName string, containerName string, command []string, stdOut io.Writer) (int, error) {
+func (k *KubernetesApiServiceImpl) ExecuteCommandWithStdErr(podName string, containerName string, command []string,
+	stdOut io.Writer, stdErr io.Writer) (int, error) {
 
 	log.Infof("executing command: '%s' on container: '%s', pod: '%s', namespace: '%s'", command, containerName, podName, k.targetNamespace)
-	stdErr := new(Writer)
 
 	executeTcpdumpRequest := ExecCommandRequest{
 		KubeRequest: KubeRequest{
@@ -77,17 +82,7 @@ func (k *KubernetesApiServiceImpl) ExecuteCommand(podName string, containerName
 		StdOut:  stdOut,
 	}
 
-	exitCode, err := PodExecuteCommand(executeTcpdumpRequest)
-	if err != nil {
-		log.WithError(err).Errorf("failed executing command: '%s', exitCode: '%d', stdErr: '%s'",
-			command, exitCode, stdErr.Output)
-
-		return exitCode, err
-	}
-
-	log.Infof("command: '%s' executing successfully exitCode: '%d', stdErr :'%s'", command, exitCode, stdErr.Output)
-
-	return exitCode, err
+	return PodExecuteCommand(executeTcpdumpRequest)
 }
 
 func (k *KubernetesApiServiceImpl) DeletePod(podName string) error {
@@ -107,15 +102,6 @@ func (k *KubernetesApiServiceImpl) DeletePod(podName string) error {
 func (k *KubernetesApiServiceImpl) CreatePrivilegedPod(nodeName string, containerName string, image string, socketPath string, timeout time.Duration) (*corev1.Pod, error) {
 	log.Debugf("creating privileged pod on remote node")
 
-	isSupported, err := k.IsSupportedContainerRuntime(nodeName)
-	if err != nil {
-		return nil, err
-	}
-
-	if !isSupported {
-		return nil, errors.Errorf("Container runtime on node %s isn't supported. Supported container runtimes are: %v", nodeName, runtime.SupportedContainerRuntimes)
-	}
-
 	typeMetadata := v1.TypeMeta{
 		Kind:       "Pod",
 		APIVersion: "v1",
@@ -135,11 +121,6 @@ func (k *KubernetesApiServiceImpl) CreatePrivilegedPod(nodeName string, containe
 			ReadOnly:  true,
 			MountPath: socketPath,
 		},
-		{
-			Name:      "host",
-			ReadOnly:  false,
-			MountPath: "/host",
-		},
 	}
 
 	privileged := true
@@ -156,7 +137,6 @@ func (k *KubernetesApiServiceImpl) CreatePrivilegedPod(nodeName string, containe
 	}
 
 	hostPathType := corev1.HostPathSocket
-	directoryType := corev1.HostPathDirectory
 
 	podSpecs := corev1.PodSpec{
 		NodeName:      nodeName,
@@ -164,15 +144,6 @@ func (k *KubernetesApiServiceImpl) CreatePrivilegedPod(nodeName string, containe
 		HostPID:       true,
 		Containers:    []corev1.Container{privilegedContainer},
 		Volumes: []corev1.Volume{
-			{
-				Name: "host",
-				VolumeSource: corev1.VolumeSource{
-					HostPath: &corev1.HostPathVolumeSource{
-						Path: "/",
-						Type: &directoryType,
-					},
-				},
-			},
 			{
 				Name: "container-socket",
 				VolumeSource: corev1.VolumeSource{
@@ -221,11 +192,12 @@ func (k *KubernetesApiServiceImpl) CreatePrivilegedPod(nodeName string, containe
 	return createdPod, nil
 }
 
-func (k *KubernetesApiServiceImpl) checkIfFileExistOnPod(remotePath string, podName string, containerName string) (bool, error) {
+// checkIfPathExistOnPod tests the remote path using the given test flag, e.g. '-f' for a regular file.
+func (k *KubernetesApiServiceImpl) checkIfPathExistOnPod(remotePath string, podName string, containerName string, testFlag string) (bool, error) {
 	stdOut := new(Writer)
 	stdErr := new(Writer)
 
-	command := []string{"/bin/sh", "-c", fmt.Sprintf("test -f %s", remotePath)}
+	command := []string{"/bin/sh", "-c", fmt.Sprintf("test %s %s", testFlag, remotePath)}
 
 	exitCode, err := k.ExecuteCommand(podName, containerName, command, stdOut)
 	if err != nil {
@@ -245,21 +217,99 @@ func (k *KubernetesApiServiceImpl) checkIfFileExistOnPod(remotePath string, podN
 	return true, nil
 }
 
-func (k *KubernetesApiServiceImpl) UploadFile(localPath string, remotePath string, podName string, containerName string) error {
-	log.Infof("uploading file: '%s' to '%s' on container: '%s'", localPath, remotePath, containerName)
+// commandNotExecutableExitCode is the lowest exit code used by shells and container runtimes to report
+// a command that couldn't be executed (126: not executable, 127: not found).
+const commandNotExecutableExitCode = 126
 
-	isExist, err := k.checkIfFileExistOnPod(remotePath, podName, containerName)
-	if err != nil {
-		return err
+func (k *KubernetesApiServiceImpl) isShellAvailable(podName string, containerName string) bool {
+	exitCode, err := k.ExecuteCommand(podName, containerName, []string{"sh", "-c", "true"}, &NopWriter{})
+	return err == nil && exitCode == 0
+}
+
+func (k *KubernetesApiServiceImpl) isCommandAvailable(podName string, containerName string, command string, isShellAvailable bool) bool {
+	if isShellAvailable {
+		exitCode, err := k.ExecuteCommand(podName, containerName, []string{"sh", "-c", "command -v " + command}, &NopWriter{})
+		return err == nil && exitCode == 0
 	}
 
-	if isExist {
-		log.Info("file was already found on remote pod")
-		return nil
+	exitCode, err := k.ExecuteCommand(podName, containerName, []string{command, "--help"}, &NopWriter{})
+	return err == nil && exitCode < commandNotExecutableExitCode
+}
+
+// selectUploadMethod returns the first upload method whose required commands are all available on the container.
+func (k *KubernetesApiServiceImpl) selectUploadMethod(podName string, containerName string, isDirectory bool) (*UploadMethod, error) {
+	isShellAvailable := k.isShellAvailable(podName, containerName)
+	availableCommands := map[string]bool{}
+
+	isAvailable := func(command string) bool {
+		available, probed := availableCommands[command]
+		if !probed {
+			available = k.isCommandAvailable(podName, containerName, command, isShellAvailable)
+			availableCommands[command] = available
+		}
+
+		return available
 	}
 
-	log.Infof("file not found on: '%s', starting to upload", remotePath)
+	for i := range UploadMethods {
+		method := &UploadMethods[i]
+		if method.RequiresShell && !isShellAvailable || isDirectory && !method.SupportsDirectories {
+			continue
+		}
+
+		supported := true
+		for _, command := range method.RequiredCommands {
+			if !isAvailable(command) {
+				supported = false
+				break
+			}
+		}
+
+		if supported {
+			return method, nil
+		}
+	}
+
+	return nil, errors.Errorf("no upload method is supported by container: '%s', supported methods are: %v",
+		containerName, UploadMethods)
+}
+
+func (k *KubernetesApiServiceImpl) UploadFile(localPath string, remotePath string, podName string, containerName string) (bool, error) {
+	log.Infof("uploading file: '%s' to '%s' on container: '%s'", localPath, remotePath, containerName)
 
+	return k.uploadFile(localPath, nil, remotePath, podName, containerName)
+}
+
+func (k *KubernetesApiServiceImpl) UploadFileContent(content []byte, remotePath string, podName string, containerName string) (bool, error) {
+	log.Infof("uploading '%d' bytes to '%s' on container: '%s'", len(content), remotePath, containerName)
+
+	return k.uploadFile("", content, remotePath, podName, containerName)
+}
+
+// remoteFileChecksumMatches compares the remote file with the local checksums using the first digest tool
+// available on the container, verifiable is false when no tool could produce a digest of the remote file.
+func (k *KubernetesApiServiceImpl) remoteFileChecksumMatches(remotePath string, podName string, containerName string,
+	checksums FileChecksums) (matches bool, verifiable bool) {
+
+	for _, tool := range remoteChecksumTools {
+		stdOut := new(Writer)
+
+		exitCode, err := k.ExecuteCommand(podName, containerName, []string{tool.command, remotePath}, stdOut)
+		if err != nil || exitCode != 0 {
+			log.Debugf("'%s' unavailable or file missing, exitCode: '%d'", tool.command, exitCode)
+			continue
+		}
+
+		remoteChecksum := parseChecksumOutput(stdOut.Output)
+		log.Debugf("remote '%s': '%s', local: '%s'", tool.command, remoteChecksum, tool.checksum(checksums))
+
+		return remoteChecksum == tool.checksum(checksums), true
+	}
+
+	return false, false
+}
+
+func (k *KubernetesApiServiceImpl) uploadFile(localPath string, content []byte, remotePath string, podName string, containerName string) (bool, error) {
 	req := UploadFileRequest{
 		KubeRequest: KubeRequest{
 			Clientset:  k.clientset,
@@ -268,18 +318,93 @@ func (k *KubernetesApiServiceImpl) UploadFile(localPath string, remotePath strin
 			Pod:        podName,
 			Container:  containerName,
 		},
-		Src: localPath,
-		Dst: remotePath,
+		Src:     localPath,
+		Content: content,
+		Dst:     remotePath,
 	}
 
-	exitCode, err := PodUploadFile(req)
-	if err != nil || exitCode != 0 {
-		return errors.Wrapf(err, "upload file failed, exitCode: %d", exitCode)
+	source, err := newUploadSource(req)
+	if err != nil {
+		return false, err
+	}
+
+	if source.isDir() {
+		return true, k.uploadDirectory(req, source)
+	}
+
+	checksums, err := ComputeUploadChecksums(localPath, content)
+	if err != nil {
+		return false, err
+	}
+
+	log.Debugf("local file sha256: '%s'", checksums.Sha256)
+
+	matches, verifiable := k.remoteFileChecksumMatches(remotePath, podName, containerName, checksums)
+	if matches {
+		log.Info("file with a matching checksum was already found on remote pod")
+		return false, nil
+	}
+
+	if verifiable {
+		log.Infof("file on: '%s' doesn't match the local checksum, starting to upload", remotePath)
+	} else {
+		log.Infof("unable to verify file on: '%s', starting to upload", remotePath)
+	}
+
+	if err := k.uploadSource(req, source); err != nil {
+		return false, err
 	}
 
 	log.Info("verifying file uploaded successfully")
 
-	isExist, err = k.checkIfFileExistOnPod(remotePath, podName, containerName)
+	matches, verifiable = k.remoteFileChecksumMatches(remotePath, podName, containerName, checksums)
+	if verifiable {
+		if !matches {
+			return true, errors.Errorf("checksum of uploaded file: '%s' doesn't match the local file", remotePath)
+		}
+
+		log.Info("file uploaded successfully, checksum verified")
+
+		return true, nil
+	}
+
+	return true, k.verifyUploadedPath(remotePath, podName, containerName, "-f")
+}
+
+// uploadDirectory uploads a directory tree, directories are always uploaded as their content can't be verified
+// using a single digest.
+func (k *KubernetesApiServiceImpl) uploadDirectory(req UploadFileRequest, source *uploadSource) error {
+	log.Infof("uploading directory: '%s' to '%s'", req.Src, req.Dst)
+
+	if err := k.uploadSource(req, source); err != nil {
+		return err
+	}
+
+	return k.verifyUploadedPath(req.Dst, req.Pod, req.Container, "-d")
+}
+
+func (k *KubernetesApiServiceImpl) uploadSource(req UploadFileRequest, source *uploadSource) error {
+	method, err := k.selectUploadMethod(req.Pod, req.Container, source.isDir())
+	if err != nil {
+		return err
+	}
+
+	log.Infof("uploading using: '%s'", method.Name)
+
+	exitCode, err := method.upload(req, source)
+	if err != nil {
+		return errors.Wrapf(err, "upload file failed, exitCode: %d", exitCode)
+	}
+
+	if exitCode != 0 {
+		return errors.Errorf("upload file failed, exitCode: %d", exitCode)
+	}
+
+	return nil
+}
+
+func (k *KubernetesApiServiceImpl) verifyUploadedPath(remotePath string, podName string, containerName string, testFlag string) error {
+	isExist, err := k.checkIfPathExistOnPod(remotePath, podName, containerName, testFlag)
 	if err != nil {
 		return err
 	}
@@ -1,9 +1,7 @@
 package kube
 
 import (
-	"bytes"
 	"io"
-	"io/ioutil"
 	"path"
 
 	log "github.com/sirupsen/logrus"
@@ -34,7 +32,9 @@ type ExecCommandRequest struct {
 type UploadFileRequest struct {
 	KubeRequest
 	Src string
-	Dst string
+	// Content is uploaded instead of reading Src when set, e.g. for binaries embedded in ksniff.
+	Content []byte
+	Dst     string
 }
 
 func (w *NopWriter) Write(p []byte) (n int, err error) {
@@ -56,28 +56,30 @@ type Writer struct {
 	Output string
 }
 
+// PodUploadFile uploads a file or a directory tree using tar, the tar is streamed to the container
+// while being written so the uploaded content is never fully held in memory.
 func PodUploadFile(req UploadFileRequest) (int, error) {
 	stdOut := new(Writer)
 	stdErr := new(Writer)
 
 	log.Debugf("uploading file from: '%s' to '%s'", req.Src, req.Dst)
 
-	fileContent, err := ioutil.ReadFile(req.Src)
+	source, err := newUploadSource(req)
 	if err != nil {
 		return 0, err
 	}
 
-	log.Debugf("read '%s' to memory, file size: '%d'", req.Src, len(fileContent))
-
-	destFileName := path.Base(req.Dst)
-	tarFile, err := WrapAsTar(destFileName, fileContent)
+	progress, err := source.newProgress(req)
 	if err != nil {
 		return 0, err
 	}
 
-	log.Debugf("formatted '%s' as tar, tar size: '%d'", req.Src, len(tarFile))
+	stdIn, tarWriter := io.Pipe()
+	defer stdIn.Close()
 
-	stdIn := bytes.NewReader(tarFile)
+	go func() {
+		tarWriter.CloseWithError(source.writeTar(tarWriter, path.Base(req.Dst), progress))
+	}()
 
 	tarCmd := []string{"tar", "-xf", "-"}
 
@@ -0,0 +1,80 @@
+package kube
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PodNames maps the pod ips to their 'namespace/name', so the captured addresses can be shown as pods.
+type PodNames struct {
+	clientset       kubernetes.Interface
+	targetNamespace string
+	mutex           sync.RWMutex
+	names           map[string]string
+}
+
+func NewPodNames(clientset kubernetes.Interface, targetNamespace string) *PodNames {
+	return &PodNames{clientset: clientset, targetNamespace: targetNamespace, names: map[string]string{}}
+}
+
+// Refresh lists the pods of the cluster, or of the target namespace when the cluster pods can't be listed.
+func (p *PodNames) Refresh(ctx context.Context) error {
+	pods, err := p.clientset.CoreV1().Pods(v1.NamespaceAll).List(ctx, v1.ListOptions{})
+	if err != nil {
+		log.WithError(err).Debugf("failed to list the cluster pods, listing the pods of namespace: '%s'", p.targetNamespace)
+
+		pods, err = p.clientset.CoreV1().Pods(p.targetNamespace).List(ctx, v1.ListOptions{})
+		if err != nil {
+			return err
+		}
+	}
+
+	names := map[string]string{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+
+		// host network pods share the node ip
+		if pod.Spec.HostNetwork || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+
+		for _, ip := range podIPs(pod) {
+			names[ip] = pod.Namespace + "/" + pod.Name
+		}
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.names = names
+
+	return nil
+}
+
+// Watch refreshes the pod names periodically until the context is done.
+func (p *PodNames) Watch(ctx context.Context, interval time.Duration) {
+	for {
+		if err := p.Refresh(ctx); err != nil && ctx.Err() == nil {
+			log.WithError(err).Debug("failed to refresh pod names")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Name returns the 'namespace/name' of the pod with the given ip, or an empty string.
+func (p *PodNames) Name(ip string) string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	return p.names[ip]
+}
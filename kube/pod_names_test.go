@@ -0,0 +1,25 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPodNames_Refresh(t *testing.T) {
+	// given
+	clientset := fake.NewSimpleClientset(
+		buildPod("redis-0", nil, "10.0.0.1", false),
+		buildPod("node-exporter", nil, "192.168.1.10", true))
+	podNames := NewPodNames(clientset, "default")
+
+	// when
+	err := podNames.Refresh(context.Background())
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, "default/redis-0", podNames.Name("10.0.0.1"))
+	assert.Equal(t, "", podNames.Name("192.168.1.10"))
+}
@@ -0,0 +1,53 @@
+package kube
+
+import (
+	"io"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const progressReportPercentStep = 10
+
+// uploadProgress reports the progress of an upload every progressReportPercentStep percent,
+// the content may be read through several readers, e.g. one per uploaded file.
+type uploadProgress struct {
+	description  string
+	total        int64
+	transferred  int64
+	reportedStep int64
+}
+
+func newUploadProgress(description string, total int64) *uploadProgress {
+	return &uploadProgress{description: description, total: total}
+}
+
+func (p *uploadProgress) add(n int64) {
+	p.transferred += n
+
+	if p.total <= 0 {
+		return
+	}
+
+	step := p.transferred * 100 / p.total / progressReportPercentStep
+	if step > p.reportedStep {
+		p.reportedStep = step
+		log.Infof("%s: %d%% (%d/%d bytes)", p.description, step*progressReportPercentStep, p.transferred, p.total)
+	}
+}
+
+// Reader returns a reader counting the bytes read through it towards the upload progress.
+func (p *uploadProgress) Reader(reader io.Reader) io.Reader {
+	return &progressReader{reader: reader, progress: p}
+}
+
+type progressReader struct {
+	reader   io.Reader
+	progress *uploadProgress
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.reader.Read(b)
+	r.progress.add(int64(n))
+
+	return n, err
+}
@@ -0,0 +1,24 @@
+package kube
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadProgress_MultipleReaders(t *testing.T) {
+	// given
+	progress := newUploadProgress("uploading", 10)
+
+	// when
+	_, _ = io.Copy(ioutil.Discard, progress.Reader(strings.NewReader("abcd")))
+	_, _ = io.Copy(ioutil.Discard, progress.Reader(bytes.NewReader([]byte("efghij"))))
+
+	// then
+	assert.Equal(t, int64(10), progress.transferred)
+	assert.Equal(t, int64(10), progress.reportedStep)
+}
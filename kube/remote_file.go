@@ -0,0 +1,47 @@
+package kube
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// RemoteFile is a file of a container, read using 'cat' as the container file system isn't otherwise reachable.
+type RemoteFile struct {
+	request KubeRequest
+	path    string
+}
+
+func NewRemoteFile(clientset *kubernetes.Clientset, restConfig *rest.Config, namespace string, podName string,
+	containerName string, path string) *RemoteFile {
+
+	return &RemoteFile{path: path, request: KubeRequest{Clientset: clientset, RestConfig: restConfig,
+		Namespace: namespace, Pod: podName, Container: containerName}}
+}
+
+// Read returns the content of the file, the command isn't logged as the file may be read periodically.
+func (f *RemoteFile) Read() ([]byte, error) {
+	stdOut := new(bytes.Buffer)
+	stdErr := new(Writer)
+
+	exitCode, err := PodExecuteCommand(ExecCommandRequest{KubeRequest: f.request,
+		Command: []string{"cat", f.path}, StdOut: stdOut, StdErr: stdErr})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read file: '%s'", f)
+	}
+
+	if exitCode != 0 {
+		return nil, errors.Errorf("failed to read file: '%s', exitCode: '%d', stdErr: '%s'", f, exitCode,
+			strings.TrimSpace(stdErr.Output))
+	}
+
+	return stdOut.Bytes(), nil
+}
+
+func (f *RemoteFile) String() string {
+	return fmt.Sprintf("%s/%s:%s", f.request.Pod, f.request.Container, f.path)
+}
@@ -2,30 +2,93 @@ package kube
 
 import (
 	"archive/tar"
-	"bytes"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
 )
 
-func WrapAsTar(fileNameOnTar string, fileContent []byte) ([]byte, error) {
-	var buf bytes.Buffer
-	tw := tar.NewWriter(&buf)
+// StreamReaderAsTar writes a tar holding a single file with the content read from the reader to the writer.
+func StreamReaderAsTar(w io.Writer, fileNameOnTar string, reader io.Reader, size int64, mode int64) error {
+	tw := tar.NewWriter(w)
 
 	hdr := &tar.Header{
 		Name: fileNameOnTar,
-		Mode: 0755,
-		Size: int64(len(fileContent)),
+		Mode: mode,
+		Size: size,
 	}
 
 	if err := tw.WriteHeader(hdr); err != nil {
-		return nil, err
+		return err
 	}
 
-	if _, err := tw.Write(fileContent); err != nil {
-		return nil, err
+	if _, err := io.Copy(tw, reader); err != nil {
+		return err
 	}
 
-	if err := tw.Close(); err != nil {
-		return nil, err
+	return tw.Close()
+}
+
+// StreamAsTar writes the local file or directory tree at src to the writer as a tar, under nameOnTar.
+// File modes and the symbolic links of a directory tree are preserved, a symbolic link src is archived as its target.
+// File contents are streamed so they are never fully held in memory.
+// wrapFile allows to observe the content of every regular file, e.g. to report upload progress.
+func StreamAsTar(w io.Writer, src string, nameOnTar string, wrapFile func(io.Reader) io.Reader) error {
+	src, err := filepath.EvalSymlinks(src)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+
+	err = filepath.Walk(src, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(src, filePath)
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(filePath); err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+
+		hdr.Name = path.Join(nameOnTar, filepath.ToSlash(relativePath))
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(tw, wrapFile(file))
+		return err
+	})
+
+	if err != nil {
+		return err
 	}
 
-	return buf.Bytes(), nil
+	return tw.Close()
 }
@@ -0,0 +1,115 @@
+package kube
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamReaderAsTar(t *testing.T) {
+	// given
+	var buf bytes.Buffer
+
+	// when
+	err := StreamReaderAsTar(&buf, "static-tcpdump", bytes.NewReader([]byte("content")), int64(len("content")), 0755)
+
+	// then
+	assert.Nil(t, err)
+
+	reader := tar.NewReader(&buf)
+	hdr, err := reader.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, "static-tcpdump", hdr.Name)
+	assert.Equal(t, int64(0755), hdr.Mode)
+
+	content, _ := ioutil.ReadAll(reader)
+	assert.Equal(t, "content", string(content))
+}
+
+func TestStreamAsTar_Directory(t *testing.T) {
+	// given
+	dir, err := ioutil.TempDir("", "ksniff")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, os.Chmod(dir, 0755))
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "lib"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "tcpdump"), []byte("binary"), 0750))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "lib", "libpcap.so"), []byte("library"), 0644))
+
+	// explicit modes, independent of the umask
+	for name, mode := range map[string]os.FileMode{"lib": 0755, "tcpdump": 0750, "lib/libpcap.so": 0644} {
+		assert.Nil(t, os.Chmod(filepath.Join(dir, name), mode))
+	}
+
+	var buf bytes.Buffer
+	var streamed int
+
+	// when
+	err = StreamAsTar(&buf, dir, "ksniff", func(reader io.Reader) io.Reader {
+		content, _ := ioutil.ReadAll(reader)
+		streamed += len(content)
+		return bytes.NewReader(content)
+	})
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, len("binary")+len("library"), streamed)
+
+	modes := map[string]int64{}
+	reader := tar.NewReader(&buf)
+	for {
+		hdr, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		modes[hdr.Name] = hdr.Mode & 0777
+	}
+
+	assert.Equal(t, map[string]int64{
+		"ksniff/":               0755,
+		"ksniff/lib/":           0755,
+		"ksniff/lib/libpcap.so": 0644,
+		"ksniff/tcpdump":        0750,
+	}, modes)
+}
+
+func TestStreamAsTar_SymlinkedFile(t *testing.T) {
+	// given
+	dir, err := ioutil.TempDir("", "ksniff")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "tcpdump")
+	link := filepath.Join(dir, "static-tcpdump")
+	assert.Nil(t, ioutil.WriteFile(target, []byte("binary"), 0755))
+	assert.Nil(t, os.Chmod(target, 0755))
+	assert.Nil(t, os.Symlink(target, link))
+
+	var buf bytes.Buffer
+
+	// when
+	err = StreamAsTar(&buf, link, "static-tcpdump", func(reader io.Reader) io.Reader {
+		return reader
+	})
+
+	// then
+	assert.Nil(t, err)
+
+	reader := tar.NewReader(&buf)
+	hdr, err := reader.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, "static-tcpdump", hdr.Name)
+	assert.Equal(t, byte(tar.TypeReg), hdr.Typeflag)
+	assert.Equal(t, int64(0755), hdr.Mode)
+
+	content, _ := ioutil.ReadAll(reader)
+	assert.Equal(t, "binary", string(content))
+}
@@ -0,0 +1,263 @@
+package kube
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const uploadedContentMode = 0755
+
+// printfChunkSize keeps each printf argument well below the kernel single argument limit (128KiB),
+// octal escaping grows the content 4 times and base64 encoding 4/3 times.
+const printfChunkSize = 16 * 1024
+
+// UploadMethod uploads a file to a container using the tools available in it.
+type UploadMethod struct {
+	Name                string
+	RequiresShell       bool
+	RequiredCommands    []string
+	SupportsDirectories bool
+	upload              func(req UploadFileRequest, source *uploadSource) (int, error)
+}
+
+// UploadMethods lists the upload methods by order of preference, the first method whose requirements are
+// available on the container is used.
+var UploadMethods = []UploadMethod{
+	{Name: "tar", RequiredCommands: []string{"tar"}, SupportsDirectories: true, upload: uploadUsingTar},
+	{Name: "cat", RequiresShell: true, RequiredCommands: []string{"cat", "chmod"}, upload: uploadUsingCat},
+	{Name: "dd", RequiredCommands: []string{"dd", "chmod"}, upload: uploadUsingDd},
+	{Name: "printf-base64", RequiresShell: true, RequiredCommands: []string{"base64", "chmod"}, upload: uploadUsingPrintfBase64},
+	{Name: "printf", RequiresShell: true, RequiredCommands: []string{"chmod"}, upload: uploadUsingPrintfOctal},
+}
+
+func (m UploadMethod) String() string {
+	requirements := m.RequiredCommands
+	if m.RequiresShell {
+		requirements = append([]string{"sh"}, requirements...)
+	}
+
+	return fmt.Sprintf("%s (requires: %s)", m.Name, strings.Join(requirements, ", "))
+}
+
+// uploadSource is the local side of an upload: in memory content, or a file or directory on disk.
+type uploadSource struct {
+	path    string
+	content []byte
+	info    os.FileInfo
+}
+
+func newUploadSource(req UploadFileRequest) (*uploadSource, error) {
+	if req.Content != nil {
+		return &uploadSource{content: req.Content}, nil
+	}
+
+	// a symlinked root, e.g. a tcpdump linked from /usr/local/bin, is uploaded as its target
+	path, err := filepath.EvalSymlinks(req.Src)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &uploadSource{path: path, info: info}, nil
+}
+
+func (s *uploadSource) isDir() bool {
+	return s.info != nil && s.info.IsDir()
+}
+
+// size returns the total size of the regular files of the source.
+func (s *uploadSource) size() (int64, error) {
+	if s.info == nil {
+		return int64(len(s.content)), nil
+	}
+
+	var total int64
+	err := filepath.Walk(s.path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			total += info.Size()
+		}
+
+		return nil
+	})
+
+	return total, err
+}
+
+// mode returns the permissions to set on a single uploaded file.
+func (s *uploadSource) mode() os.FileMode {
+	if s.info == nil {
+		return uploadedContentMode
+	}
+
+	return s.info.Mode().Perm()
+}
+
+// open returns the content of a single file source.
+func (s *uploadSource) open() (io.ReadCloser, error) {
+	if s.info == nil {
+		return ioutil.NopCloser(bytes.NewReader(s.content)), nil
+	}
+
+	if s.isDir() {
+		return nil, errors.Errorf("'%s' is a directory", s.path)
+	}
+
+	return os.Open(s.path)
+}
+
+func (s *uploadSource) writeTar(w io.Writer, nameOnTar string, progress *uploadProgress) error {
+	if s.info == nil {
+		return StreamReaderAsTar(w, nameOnTar, progress.Reader(bytes.NewReader(s.content)), int64(len(s.content)), uploadedContentMode)
+	}
+
+	return StreamAsTar(w, s.path, nameOnTar, progress.Reader)
+}
+
+func (s *uploadSource) newProgress(req UploadFileRequest) (*uploadProgress, error) {
+	total, err := s.size()
+	if err != nil {
+		return nil, err
+	}
+
+	return newUploadProgress(fmt.Sprintf("uploading '%s'", req.Dst), total), nil
+}
+
+func uploadUsingTar(req UploadFileRequest, _ *uploadSource) (int, error) {
+	return PodUploadFile(req)
+}
+
+// uploadUsingCat streams the content to 'cat' through a shell redirection, the destination is passed as a
+// positional parameter so it never needs quoting.
+func uploadUsingCat(req UploadFileRequest, source *uploadSource) (int, error) {
+	script := fmt.Sprintf(`cat > "$1" && chmod %o "$1"`, source.mode())
+
+	return streamUploadCommand(req, source, []string{"sh", "-c", script, "sh", req.Dst})
+}
+
+func uploadUsingDd(req UploadFileRequest, source *uploadSource) (int, error) {
+	exitCode, err := streamUploadCommand(req, source, []string{"dd", "of=" + req.Dst})
+	if err != nil || exitCode != 0 {
+		return exitCode, err
+	}
+
+	return execUploadCommand(req, []string{"chmod", fmt.Sprintf("%o", source.mode()), req.Dst}, nil)
+}
+
+func uploadUsingPrintfBase64(req UploadFileRequest, source *uploadSource) (int, error) {
+	return uploadUsingPrintf(req, source, `printf '%s' "$2" | base64 -d >> "$1"`, func(chunk []byte) string {
+		return base64.StdEncoding.EncodeToString(chunk)
+	})
+}
+
+func uploadUsingPrintfOctal(req UploadFileRequest, source *uploadSource) (int, error) {
+	return uploadUsingPrintf(req, source, `printf "$2" >> "$1"`, printfOctalEscape)
+}
+
+// uploadUsingPrintf appends the content to the destination chunk by chunk, each chunk is encoded and passed
+// as an argument to a printf based shell script.
+func uploadUsingPrintf(req UploadFileRequest, source *uploadSource, appendScript string, encode func([]byte) string) (int, error) {
+	progress, err := source.newProgress(req)
+	if err != nil {
+		return 0, err
+	}
+
+	file, err := source.open()
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	exitCode, err := execUploadCommand(req, []string{"sh", "-c", `: > "$1"`, "sh", req.Dst}, nil)
+	if err != nil || exitCode != 0 {
+		return exitCode, err
+	}
+
+	reader := progress.Reader(file)
+	chunk := make([]byte, printfChunkSize)
+
+	for {
+		n, err := io.ReadFull(reader, chunk)
+		if n > 0 {
+			exitCode, err := execUploadCommand(req, []string{"sh", "-c", appendScript, "sh", req.Dst, encode(chunk[:n])}, nil)
+			if err != nil || exitCode != 0 {
+				return exitCode, err
+			}
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return execUploadCommand(req, []string{"chmod", fmt.Sprintf("%o", source.mode()), req.Dst}, nil)
+}
+
+// printfOctalEscape escapes every byte as a printf octal escape sequence, so the content never contains
+// printf format directives or characters interpreted by the shell.
+func printfOctalEscape(content []byte) string {
+	var builder strings.Builder
+	builder.Grow(len(content) * 4)
+
+	for _, b := range content {
+		fmt.Fprintf(&builder, "\\%03o", b)
+	}
+
+	return builder.String()
+}
+
+// streamUploadCommand executes the command with the content of a single file source as its standard input.
+func streamUploadCommand(req UploadFileRequest, source *uploadSource, command []string) (int, error) {
+	progress, err := source.newProgress(req)
+	if err != nil {
+		return 0, err
+	}
+
+	file, err := source.open()
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	return execUploadCommand(req, command, progress.Reader(file))
+}
+
+func execUploadCommand(req UploadFileRequest, command []string, stdIn io.Reader) (int, error) {
+	stdOut := new(Writer)
+	stdErr := new(Writer)
+
+	execRequest := ExecCommandRequest{
+		KubeRequest: req.KubeRequest,
+		Command:     command,
+		StdIn:       stdIn,
+		StdOut:      stdOut,
+		StdErr:      stdErr,
+	}
+
+	exitCode, err := PodExecuteCommand(execRequest)
+
+	log.Debugf("executed upload command: '%s', exitCode: '%d', stdOut: '%s', stdErr: '%s'",
+		command[0], exitCode, stdOut.Output, stdErr.Output)
+
+	return exitCode, err
+}
@@ -0,0 +1,56 @@
+package kube
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintfOctalEscape(t *testing.T) {
+	// when
+	result := printfOctalEscape([]byte{0x7f, 'E', 'L', 'F', '%', '\n', 0})
+
+	// then
+	assert.Equal(t, `\177\105\114\106\045\012\000`, result)
+}
+
+func TestUploadMethodString(t *testing.T) {
+	// when
+	result := UploadMethods[1].String()
+
+	// then
+	assert.Equal(t, "cat (requires: sh, cat, chmod)", result)
+}
+
+func TestNewUploadSource_SymlinkedBinary(t *testing.T) {
+	// given
+	dir, err := ioutil.TempDir("", "ksniff")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "tcpdump")
+	link := filepath.Join(dir, "static-tcpdump")
+	assert.Nil(t, ioutil.WriteFile(target, []byte("binary"), 0750))
+	assert.Nil(t, os.Chmod(target, 0750))
+	assert.Nil(t, os.Symlink(target, link))
+
+	// when
+	source, err := newUploadSource(UploadFileRequest{Src: link})
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0750), source.mode())
+
+	size, err := source.size()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len("binary")), size)
+
+	file, err := source.open()
+	assert.Nil(t, err)
+	defer file.Close()
+	content, _ := ioutil.ReadAll(file)
+	assert.Equal(t, "binary", string(content))
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"ksniff/pkg/filter"
+	"ksniff/pkg/service/sniffer"
+
+	"github.com/pkg/errors"
+)
+
+// Exit codes returned by ksniff, allowing scripts to branch on the capture failure reason.
+const (
+	ExitCodeFailure              = 1
+	ExitCodeInvalidFilter        = 3
+	ExitCodeInterfaceNotFound    = 4
+	ExitCodePermissionDenied     = 5
+	ExitCodeTcpdumpNotExecutable = 6
+)
+
+// ExitCode maps the error returned by the sniff command to the process exit code.
+func ExitCode(err error) int {
+	var missingCapabilityErr *sniffer.MissingCapabilityError
+	var filterSyntaxErr *filter.SyntaxError
+
+	switch {
+	case errors.Is(err, sniffer.ErrInvalidFilter), errors.As(err, &filterSyntaxErr):
+		return ExitCodeInvalidFilter
+	case errors.Is(err, sniffer.ErrInterfaceNotFound):
+		return ExitCodeInterfaceNotFound
+	case errors.Is(err, sniffer.ErrPermissionDenied), errors.As(err, &missingCapabilityErr):
+		return ExitCodePermissionDenied
+	case errors.Is(err, sniffer.ErrTcpdumpNotExecutable):
+		return ExitCodeTcpdumpNotExecutable
+	default:
+		return ExitCodeFailure
+	}
+}
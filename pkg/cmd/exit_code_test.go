@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"ksniff/pkg/filter"
+	"ksniff/pkg/service/sniffer"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, ExitCodeInvalidFilter, ExitCode(sniffer.NewTcpdumpError(1, "syntax error", nil)))
+	assert.Equal(t, ExitCodeInvalidFilter, ExitCode(&filter.SyntaxError{Filter: "port", Position: 4}))
+	assert.Equal(t, ExitCodeInterfaceNotFound, ExitCode(sniffer.NewTcpdumpError(1, "eth7: No such device exists", nil)))
+	assert.Equal(t, ExitCodePermissionDenied, ExitCode(errors.Wrap(&sniffer.MissingCapabilityError{ContainerName: "app"}, "validate")))
+	assert.Equal(t, ExitCodeTcpdumpNotExecutable, ExitCode(sniffer.NewTcpdumpError(127, "", nil)))
+	assert.Equal(t, ExitCodeFailure, ExitCode(errors.New("context doesn't exist")))
+}
@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"ksniff/pkg/filter"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ksniff implements the wireshark extcap interface, see https://www.wireshark.org/docs/wsdg_html_chunked/ChCaptureExtcap.html.
+const extcapInterfaceName = "ksniff"
+
+// extcapLinkType is the link type of the default 'any' capture interface, wireshark reads the actual link type
+// from the captured stream.
+const extcapLinkType = 113
+
+// The extcap config arguments, wireshark passes the selected values as the matching flags when capturing.
+const (
+	extcapArgContext = iota
+	extcapArgNamespace
+	extcapArgPod
+	extcapArgContainer
+	extcapArgInterface
+	extcapArgPrivileged
+)
+
+type extcapSettings struct {
+	interfaces    bool
+	dlts          bool
+	config        bool
+	capture       bool
+	iface         string
+	version       string
+	captureFilter string
+	reloadOption  string
+	pod           string
+}
+
+func addExtcapFlags(cmd *cobra.Command, settings *extcapSettings) {
+	cmd.Flags().BoolVarP(&settings.interfaces, "extcap-interfaces", "", false, "list the wireshark extcap interfaces")
+	cmd.Flags().BoolVarP(&settings.dlts, "extcap-dlts", "", false, "list the wireshark extcap interface link types")
+	cmd.Flags().BoolVarP(&settings.config, "extcap-config", "", false, "list the wireshark extcap interface options")
+	cmd.Flags().BoolVarP(&settings.capture, "capture", "", false, "capture as a wireshark extcap interface")
+	cmd.Flags().StringVarP(&settings.iface, "extcap-interface", "", "", "the wireshark extcap interface")
+	cmd.Flags().StringVarP(&settings.version, "extcap-version", "", "", "the wireshark version")
+	cmd.Flags().StringVarP(&settings.captureFilter, "extcap-capture-filter", "", "", "the wireshark capture filter")
+	cmd.Flags().StringVarP(&settings.reloadOption, "extcap-reload-option", "", "", "the wireshark extcap option to reload")
+	cmd.Flags().StringVarP(&settings.pod, "pod", "", "", "the pod to capture as a wireshark extcap interface")
+
+	for _, name := range []string{"extcap-interfaces", "extcap-dlts", "extcap-config", "capture", "extcap-interface",
+		"extcap-version", "extcap-capture-filter", "extcap-reload-option", "pod"} {
+		_ = cmd.Flags().MarkHidden(name)
+	}
+}
+
+// isQuery reports whether wireshark queries the extcap interface, rather than starting a capture. The capture
+// filter alone is passed when wireshark validates it.
+func (e *extcapSettings) isQuery() bool {
+	return e.interfaces || e.dlts || e.config || (e.captureFilter != "" && !e.capture)
+}
+
+func extcapValue(value string) string {
+	// braces delimit the extcap fields
+	return strings.NewReplacer("{", "(", "}", ")").Replace(value)
+}
+
+func writeExtcapInterfaces(w io.Writer) {
+	_, _ = fmt.Fprintf(w, "extcap {version=1.0}{help=https://github.com/eldadru/ksniff}\n")
+	_, _ = fmt.Fprintf(w, "interface {value=%s}{display=Kubernetes pod capture (ksniff)}\n", extcapInterfaceName)
+}
+
+func writeExtcapDlts(w io.Writer) {
+	_, _ = fmt.Fprintf(w, "dlt {number=%d}{name=LINUX_SLL}{display=Linux cooked capture}\n", extcapLinkType)
+}
+
+// writeExtcapFilterValidation writes nothing when the capture filter is valid, wireshark shows the written error
+// otherwise. The kubernetes references can't be resolved without a target pod, they are validated once expanded.
+func writeExtcapFilterValidation(w io.Writer, captureFilter string) {
+	if filter.HasReferences(captureFilter) {
+		return
+	}
+
+	if err := filter.Validate(captureFilter, filter.LinkTypeForInterface("any")); err != nil {
+		_, _ = fmt.Fprintln(w, err.Error())
+	}
+}
+
+func writeExtcapValues(w io.Writer, arg int, values []string, defaultValue string) {
+	for _, value := range values {
+		_, _ = fmt.Fprintf(w, "value {arg=%d}{value=%s}{display=%s}{default=%t}\n",
+			arg, extcapValue(value), extcapValue(value), value == defaultValue)
+	}
+}
+
+// extcapTarget lists the values of the config selectors, the namespaces and pods are those of the selected context.
+type extcapTarget struct {
+	contexts         []string
+	currentContext   string
+	namespaces       []string
+	currentNamespace string
+	pods             []string
+}
+
+// writeExtcapConfig writes the config arguments and their values, or only the values of the reloaded argument.
+func writeExtcapConfig(w io.Writer, target *extcapTarget, reloadOption string) {
+	switch strings.TrimPrefix(reloadOption, "--") {
+	case "namespace":
+		writeExtcapValues(w, extcapArgNamespace, target.namespaces, target.currentNamespace)
+		return
+	case "pod":
+		writeExtcapValues(w, extcapArgPod, target.pods, "")
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "arg {number=%d}{call=--context}{display=Context}{type=selector}"+
+		"{tooltip=The kubeconfig context}{group=Target}\n", extcapArgContext)
+	_, _ = fmt.Fprintf(w, "arg {number=%d}{call=--namespace}{display=Namespace}{type=selector}{reload=true}"+
+		"{placeholder=Reload after selecting the context}{tooltip=The pod namespace}{group=Target}\n", extcapArgNamespace)
+	_, _ = fmt.Fprintf(w, "arg {number=%d}{call=--pod}{display=Pod}{type=selector}{reload=true}{required=true}"+
+		"{placeholder=Reload after selecting the namespace}{tooltip=The pod to capture}{group=Target}\n", extcapArgPod)
+	_, _ = fmt.Fprintf(w, "arg {number=%d}{call=--container}{display=Container}{type=string}"+
+		"{tooltip=The container to capture, the first container if empty}{group=Target}\n", extcapArgContainer)
+	_, _ = fmt.Fprintf(w, "arg {number=%d}{call=--interface}{display=Interface}{type=string}{default=any}"+
+		"{tooltip=The pod interface to capture}{group=Capture}\n", extcapArgInterface)
+	_, _ = fmt.Fprintf(w, "arg {number=%d}{call=--privileged}{display=Privileged}{type=boolflag}{default=false}"+
+		"{tooltip=Capture from a privileged pod on the pod node}{group=Capture}\n", extcapArgPrivileged)
+
+	writeExtcapValues(w, extcapArgContext, target.contexts, target.currentContext)
+	writeExtcapValues(w, extcapArgNamespace, target.namespaces, target.currentNamespace)
+	writeExtcapValues(w, extcapArgPod, target.pods, "")
+}
+
+// loadExtcapTarget lists the kubeconfig contexts, and the namespaces and running pods of the selected context.
+// The api failures are only logged, wireshark shows the options without their values.
+func (o *Ksniff) loadExtcapTarget() (*extcapTarget, error) {
+	if err := o.loadKubeConfig(); err != nil {
+		return nil, err
+	}
+
+	target := &extcapTarget{currentContext: o.settings.UserSpecifiedKubeContext, currentNamespace: o.resultingContext.Namespace}
+	if target.currentContext == "" {
+		target.currentContext = o.rawConfig.CurrentContext
+	}
+	if target.currentNamespace == "" {
+		target.currentNamespace = corev1.NamespaceDefault
+	}
+
+	for name := range o.rawConfig.Contexts {
+		target.contexts = append(target.contexts, name)
+	}
+	sort.Strings(target.contexts)
+
+	namespaces, err := o.clientset.CoreV1().Namespaces().List(context.TODO(), v1.ListOptions{})
+	if err != nil {
+		log.WithError(err).Warn("failed to list namespaces")
+		target.namespaces = []string{target.currentNamespace}
+	} else {
+		for _, namespace := range namespaces.Items {
+			target.namespaces = append(target.namespaces, namespace.Name)
+		}
+	}
+
+	pods, err := o.clientset.CoreV1().Pods(target.currentNamespace).List(context.TODO(), v1.ListOptions{})
+	if err != nil {
+		log.WithError(err).Warnf("failed to list pods of namespace: '%s'", target.currentNamespace)
+		return target, nil
+	}
+
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning {
+			target.pods = append(target.pods, pod.Name)
+		}
+	}
+
+	return target, nil
+}
+
+// RunExtcapQuery answers the wireshark queries of the extcap interface.
+func (o *Ksniff) RunExtcapQuery(w io.Writer) error {
+	if o.extcap.interfaces {
+		writeExtcapInterfaces(w)
+		return nil
+	}
+
+	if o.extcap.iface != extcapInterfaceName {
+		return errors.Errorf("unknown extcap interface: '%s'", o.extcap.iface)
+	}
+
+	if o.extcap.dlts {
+		writeExtcapDlts(w)
+		return nil
+	}
+
+	if !o.extcap.config {
+		writeExtcapFilterValidation(w, o.extcap.captureFilter)
+		return nil
+	}
+
+	o.settings.UserSpecifiedKubeContext = viper.GetString("context")
+	o.settings.UserSpecifiedNamespace = viper.GetString("namespace")
+
+	target, err := o.loadExtcapTarget()
+	if err != nil {
+		return err
+	}
+
+	writeExtcapConfig(w, target, o.extcap.reloadOption)
+
+	return nil
+}
+
+// completeExtcapCapture maps the wireshark capture arguments to the ksniff settings, the capture is written to the
+// fifo created by wireshark.
+func (o *Ksniff) completeExtcapCapture() error {
+	if o.extcap.iface != extcapInterfaceName {
+		return errors.Errorf("unknown extcap interface: '%s'", o.extcap.iface)
+	}
+
+	if o.settings.UserSpecifiedFifoPath == "" {
+		return errors.New("the extcap capture requires the wireshark fifo")
+	}
+
+	if o.extcap.pod == "" {
+		return errors.New("pod name is empty")
+	}
+
+	o.settings.UserSpecifiedPodName = o.extcap.pod
+	o.settings.UserSpecifiedOutputFile = o.settings.UserSpecifiedFifoPath
+	o.settings.UserSpecifiedFifoPath = ""
+
+	if o.extcap.captureFilter != "" {
+		o.settings.UserSpecifiedFilter = o.extcap.captureFilter
+	}
+
+	return nil
+}
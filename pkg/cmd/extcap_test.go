@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"bytes"
+	"ksniff/pkg/config"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func TestWriteExtcapInterfaces(t *testing.T) {
+	// given
+	output := new(bytes.Buffer)
+
+	// when
+	writeExtcapInterfaces(output)
+
+	// then
+	assert.Contains(t, output.String(), "extcap {version=1.0}")
+	assert.Contains(t, output.String(), "interface {value=ksniff}")
+}
+
+func TestWriteExtcapConfig(t *testing.T) {
+	// given
+	output := new(bytes.Buffer)
+	target := &extcapTarget{contexts: []string{"dev", "prod"}, currentContext: "prod",
+		namespaces: []string{"default", "kube-system"}, currentNamespace: "default", pods: []string{"redis-0"}}
+
+	// when
+	writeExtcapConfig(output, target, "")
+
+	// then
+	assert.Contains(t, output.String(), "arg {number=0}{call=--context}")
+	assert.Contains(t, output.String(), "arg {number=2}{call=--pod}")
+	assert.Contains(t, output.String(), "value {arg=0}{value=dev}{display=dev}{default=false}")
+	assert.Contains(t, output.String(), "value {arg=0}{value=prod}{display=prod}{default=true}")
+	assert.Contains(t, output.String(), "value {arg=1}{value=default}{display=default}{default=true}")
+	assert.Contains(t, output.String(), "value {arg=2}{value=redis-0}{display=redis-0}{default=false}")
+}
+
+func TestWriteExtcapConfig_ReloadPods(t *testing.T) {
+	// given
+	output := new(bytes.Buffer)
+	target := &extcapTarget{contexts: []string{"dev"}, namespaces: []string{"default"}, pods: []string{"redis-0", "redis-1"}}
+
+	// when
+	writeExtcapConfig(output, target, "--pod")
+
+	// then
+	assert.Equal(t, "value {arg=2}{value=redis-0}{display=redis-0}{default=false}\n"+
+		"value {arg=2}{value=redis-1}{display=redis-1}{default=false}\n", output.String())
+}
+
+func TestWriteExtcapFilterValidation(t *testing.T) {
+	// given
+	tests := map[string]bool{
+		"tcp port 80":               true,
+		"svc:payments and port 443": true,
+		"tcp prot 80":               false,
+	}
+
+	for captureFilter, valid := range tests {
+		output := new(bytes.Buffer)
+
+		// when
+		writeExtcapFilterValidation(output, captureFilter)
+
+		// then
+		assert.Equal(t, valid, output.Len() == 0, captureFilter)
+	}
+}
+
+func TestCompleteExtcapCapture(t *testing.T) {
+	// given
+	settings := config.NewKsniffSettings(genericclioptions.IOStreams{})
+	settings.UserSpecifiedFifoPath = "/tmp/wireshark_extcap_ksniff"
+	sniff := NewKsniff(settings)
+	sniff.extcap = extcapSettings{capture: true, iface: "ksniff", pod: "redis-0", captureFilter: "port 6379"}
+
+	// when
+	err := sniff.completeExtcapCapture()
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, "redis-0", settings.UserSpecifiedPodName)
+	assert.Equal(t, "/tmp/wireshark_extcap_ksniff", settings.UserSpecifiedOutputFile)
+	assert.Equal(t, "", settings.UserSpecifiedFifoPath)
+	assert.Equal(t, "port 6379", settings.UserSpecifiedFilter)
+}
+
+func TestCompleteExtcapCapture_UnknownInterface(t *testing.T) {
+	// given
+	settings := config.NewKsniffSettings(genericclioptions.IOStreams{})
+	settings.UserSpecifiedFifoPath = "/tmp/wireshark_extcap_ksniff"
+	sniff := NewKsniff(settings)
+	sniff.extcap = extcapSettings{capture: true, iface: "eth0"}
+
+	// when
+	err := sniff.completeExtcapCapture()
+
+	// then
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "unknown extcap interface"))
+}
@@ -2,16 +2,31 @@ package cmd
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"ksniff/kube"
 	"ksniff/pkg/config"
+	"ksniff/pkg/dns"
+	"ksniff/pkg/filter"
+	"ksniff/pkg/httplog"
+	"ksniff/pkg/keylog"
+	"ksniff/pkg/output"
+	"ksniff/pkg/report"
 	"ksniff/pkg/service/sniffer"
 	"ksniff/pkg/service/sniffer/runtime"
+	"ksniff/pkg/tcpdump"
+	"ksniff/pkg/tui"
+	"ksniff/pkg/viewer"
 	"os"
-	"os/exec"
+	"os/signal"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/mitchellh/go-homedir"
@@ -37,10 +52,21 @@ var (
 )
 
 const minimumNumberOfArguments = 1
-const tcpdumpBinaryName = "static-tcpdump"
 const tcpdumpRemotePath = "/tmp/static-tcpdump"
+const podNamesRefreshInterval = 30 * time.Second
 
-var tcpdumpLocalBinaryPathLookupList []string
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+const defaultNodeOperatingSystem = "linux"
+const defaultNodeArchitecture = "amd64"
+
+// keyLogReadInterval is the delay between the reads of the tls key log file.
+const keyLogReadInterval = 2 * time.Second
+
+var tcpdumpLocalBinaryDirLookupList []string
 
 type Ksniff struct {
 	configFlags      *genericclioptions.ConfigFlags
@@ -50,6 +76,9 @@ type Ksniff struct {
 	rawConfig        api.Config
 	settings         *config.KsniffSettings
 	snifferService   sniffer.SnifferService
+	filterResolver   filter.Resolver
+	extcap           extcapSettings
+	viewer           *viewer.Viewer
 }
 
 func NewKsniff(settings *config.KsniffSettings) *Ksniff {
@@ -67,6 +96,9 @@ func NewCmdSniff(streams genericclioptions.IOStreams) *cobra.Command {
 		Example:      ksniffExample,
 		SilenceUsage: true,
 		RunE: func(c *cobra.Command, args []string) error {
+			if ksniff.extcap.isQuery() {
+				return ksniff.RunExtcapQuery(streams.Out)
+			}
 			if err := ksniff.Complete(c, args); err != nil {
 				return err
 			}
@@ -97,11 +129,90 @@ func NewCmdSniff(streams genericclioptions.IOStreams) *cobra.Command {
 	_ = viper.BindEnv("filter", "KUBECTL_PLUGINS_LOCAL_FLAG_FILTER")
 	_ = viper.BindPFlag("filter", cmd.Flags().Lookup("filter"))
 
+	cmd.Flags().BoolVarP(&ksniffSettings.UserSpecifiedSkipFilterValidation, "skip-filter-validation", "", false,
+		"if specified, the capture filter is only validated by the remote tcpdump (optional)")
+	_ = viper.BindEnv("skip-filter-validation", "KUBECTL_PLUGINS_LOCAL_FLAG_SKIP_FILTER_VALIDATION")
+	_ = viper.BindPFlag("skip-filter-validation", cmd.Flags().Lookup("skip-filter-validation"))
+
+	cmd.Flags().BoolVarP(&ksniffSettings.UserSpecifiedNoFilterRefresh, "no-filter-refresh", "", false,
+		"if specified, the kubernetes references of the capture filter (e.g. 'svc:name') are only resolved when "+
+			"the capture starts, the output is then a pcap rather than a pcapng stream (optional)")
+	_ = viper.BindEnv("no-filter-refresh", "KUBECTL_PLUGINS_LOCAL_FLAG_NO_FILTER_REFRESH")
+	_ = viper.BindPFlag("no-filter-refresh", cmd.Flags().Lookup("no-filter-refresh"))
+
+	cmd.Flags().StringVarP(&ksniffSettings.UserSpecifiedTLSKeyLog, "tls-keylog", "", "",
+		"local tls key log file (SSLKEYLOGFILE), its secrets are embedded in the pcapng output so wireshark "+
+			"decrypts the tls connections (optional)")
+	_ = viper.BindEnv("tls-keylog", "KUBECTL_PLUGINS_LOCAL_FLAG_TLS_KEYLOG")
+	_ = viper.BindPFlag("tls-keylog", cmd.Flags().Lookup("tls-keylog"))
+
+	cmd.Flags().StringVarP(&ksniffSettings.UserSpecifiedTLSKeyLogRemote, "tls-keylog-remote", "", "",
+		"tls key log file (SSLKEYLOGFILE) in the target container, read periodically using 'cat' and embedded "+
+			"like --tls-keylog (optional)")
+	_ = viper.BindEnv("tls-keylog-remote", "KUBECTL_PLUGINS_LOCAL_FLAG_TLS_KEYLOG_REMOTE")
+	_ = viper.BindPFlag("tls-keylog-remote", cmd.Flags().Lookup("tls-keylog-remote"))
+
 	cmd.Flags().StringVarP(&ksniffSettings.UserSpecifiedOutputFile, "output-file", "o", "",
 		"output file path, tcpdump output will be redirect to this file instead of wireshark (optional) ('-' stdout)")
 	_ = viper.BindEnv("output-file", "KUBECTL_PLUGINS_LOCAL_FLAG_OUTPUT_FILE")
 	_ = viper.BindPFlag("output-file", cmd.Flags().Lookup("output-file"))
 
+	cmd.Flags().StringVarP(&ksniffSettings.UserSpecifiedListenAddress, "listen", "", "",
+		"stream the capture to the consumers connecting to this address instead of wireshark, "+
+			"e.g. 'tcp://127.0.0.1:19000' or 'udp://0.0.0.0:19000' (optional)")
+	_ = viper.BindEnv("listen", "KUBECTL_PLUGINS_LOCAL_FLAG_LISTEN")
+	_ = viper.BindPFlag("listen", cmd.Flags().Lookup("listen"))
+
+	cmd.Flags().StringVarP(&ksniffSettings.UserSpecifiedFifoPath, "fifo", "", "",
+		"stream the capture to the readers of this named pipe instead of wireshark, created if missing (optional)")
+	_ = viper.BindEnv("fifo", "KUBECTL_PLUGINS_LOCAL_FLAG_FIFO")
+	_ = viper.BindPFlag("fifo", cmd.Flags().Lookup("fifo"))
+
+	cmd.Flags().BoolVarP(&ksniffSettings.UserSpecifiedTui, "tui", "", false,
+		"if specified, the capture is shown by a live terminal view of its connections and packets instead of wireshark (optional)")
+	_ = viper.BindEnv("tui", "KUBECTL_PLUGINS_LOCAL_FLAG_TUI")
+	_ = viper.BindPFlag("tui", cmd.Flags().Lookup("tui"))
+
+	cmd.Flags().BoolVarP(&ksniffSettings.UserSpecifiedSummary, "summary", "", false,
+		"if specified, a summary of the capture is printed when it ends: top talkers, connections, SYNs without "+
+			"SYN-ACK, dns failures and tls handshakes (optional)")
+	_ = viper.BindEnv("summary", "KUBECTL_PLUGINS_LOCAL_FLAG_SUMMARY")
+	_ = viper.BindPFlag("summary", cmd.Flags().Lookup("summary"))
+
+	cmd.Flags().StringVarP(&ksniffSettings.UserSpecifiedSummaryFile, "summary-file", "", "",
+		"write the summary of the capture to this file when it ends, as csv when its extension is '.csv' and as json "+
+			"otherwise (optional)")
+	_ = viper.BindEnv("summary-file", "KUBECTL_PLUGINS_LOCAL_FLAG_SUMMARY_FILE")
+	_ = viper.BindPFlag("summary-file", cmd.Flags().Lookup("summary-file"))
+
+	cmd.Flags().BoolVarP(&ksniffSettings.UserSpecifiedDNSLog, "dns-log", "", false,
+		"if specified, the dns queries and their responses are printed to stdout instead of wireshark (optional)")
+	_ = viper.BindEnv("dns-log", "KUBECTL_PLUGINS_LOCAL_FLAG_DNS_LOG")
+	_ = viper.BindPFlag("dns-log", cmd.Flags().Lookup("dns-log"))
+
+	cmd.Flags().BoolVarP(&ksniffSettings.UserSpecifiedHTTPLog, "http-log", "", false,
+		"if specified, the plaintext http/1.x, h2c and gRPC requests and their responses are printed to stdout "+
+			"instead of wireshark (optional)")
+	_ = viper.BindEnv("http-log", "KUBECTL_PLUGINS_LOCAL_FLAG_HTTP_LOG")
+	_ = viper.BindPFlag("http-log", cmd.Flags().Lookup("http-log"))
+
+	cmd.Flags().StringVarP(&ksniffSettings.UserSpecifiedLogFormat, "log-format", "", logFormatText,
+		"format of the dns and http log entries, 'text' or 'json' lines (optional)")
+	_ = viper.BindEnv("log-format", "KUBECTL_PLUGINS_LOCAL_FLAG_LOG_FORMAT")
+	_ = viper.BindPFlag("log-format", cmd.Flags().Lookup("log-format"))
+
+	cmd.Flags().StringVarP(&ksniffSettings.UserSpecifiedViewer, "viewer", "", "",
+		fmt.Sprintf("the local viewer reading the capture from its stdin, one of: %v, or a command template "+
+			"using {{.Title}}, {{.Namespace}}, {{.Pod}}, {{.Container}}, {{.Filter}} and {{.DecodeAs}}, e.g. "+
+			"'tshark -i - -Y http' (optional, the first installed viewer by default)", viewer.BuiltinViewers))
+	_ = viper.BindEnv("viewer", "KUBECTL_PLUGINS_LOCAL_FLAG_VIEWER")
+	_ = viper.BindPFlag("viewer", cmd.Flags().Lookup("viewer"))
+
+	cmd.Flags().StringArrayVarP(&ksniffSettings.UserSpecifiedDecodeAs, "decode-as", "", nil,
+		"wireshark decode as rule passed to the viewer, e.g. 'tcp.port==8080,http', may be repeated (optional)")
+	_ = viper.BindEnv("decode-as", "KUBECTL_PLUGINS_LOCAL_FLAG_DECODE_AS")
+	_ = viper.BindPFlag("decode-as", cmd.Flags().Lookup("decode-as"))
+
 	cmd.Flags().StringVarP(&ksniffSettings.UserSpecifiedLocalTcpdumpPath, "local-tcpdump-path", "l", "",
 		"local static tcpdump binary path (optional)")
 	_ = viper.BindEnv("local-tcpdump-path", "KUBECTL_PLUGINS_LOCAL_FLAG_LOCAL_TCPDUMP_PATH")
@@ -117,6 +228,11 @@ func NewCmdSniff(streams genericclioptions.IOStreams) *cobra.Command {
 	_ = viper.BindEnv("verbose", "KUBECTL_PLUGINS_LOCAL_FLAG_VERBOSE")
 	_ = viper.BindPFlag("verbose", cmd.Flags().Lookup("verbose"))
 
+	cmd.Flags().BoolVarP(&ksniffSettings.UserSpecifiedKeepBinary, "keep-binary", "", false,
+		"if specified, the uploaded static tcpdump binary isn't removed from the container when the capture ends (optional)")
+	_ = viper.BindEnv("keep-binary", "KUBECTL_PLUGINS_LOCAL_FLAG_KEEP_BINARY")
+	_ = viper.BindPFlag("keep-binary", cmd.Flags().Lookup("keep-binary"))
+
 	cmd.Flags().BoolVarP(&ksniffSettings.UserSpecifiedPrivilegedMode, "privileged", "p", false,
 		"if specified, ksniff will deploy another pod that have privileges to attach target pod network namespace")
 	_ = viper.BindEnv("privileged", "KUBECTL_PLUGINS_LOCAL_FLAG_PRIVILEGED")
@@ -131,10 +247,9 @@ func NewCmdSniff(streams genericclioptions.IOStreams) *cobra.Command {
 	_ = viper.BindEnv("image", "KUBECTL_PLUGINS_LOCAL_FLAG_IMAGE")
 	_ = viper.BindPFlag("image", cmd.Flags().Lookup("image"))
 
-	cmd.Flags().StringVarP(&ksniffSettings.TCPDumpImage, "tcpdump-image", "", "",
-		"the tcpdump container image (optional)")
-	_ = viper.BindEnv("tcpdump-image", "KUBECTL_PLUGINS_LOCAL_FLAG_TCPDUMP_IMAGE")
-	_ = viper.BindPFlag("tcpdump-image", cmd.Flags().Lookup("tcpdump-image"))
+	cmd.Flags().String("tcpdump-image", "", "the tcpdump container image (optional)")
+	_ = cmd.Flags().MarkDeprecated("tcpdump-image",
+		"tcpdump now runs from the privileged container image using nsenter, use --image instead")
 
 	cmd.Flags().StringVarP(&ksniffSettings.UserSpecifiedKubeContext, "context", "x", "",
 		"kubectl context to work on (optional)")
@@ -146,19 +261,46 @@ func NewCmdSniff(streams genericclioptions.IOStreams) *cobra.Command {
 	_ = viper.BindEnv("socket", "KUBECTL_PLUGINS_SOCKET_PATH")
 	_ = viper.BindPFlag("socket", cmd.Flags().Lookup("socket"))
 
+	cmd.Flags().StringVarP(&ksniffSettings.UserSpecifiedContainerRuntime, "container-runtime", "", "",
+		fmt.Sprintf("the node container runtime, detected from the node if omitted, one of: %v (optional)",
+			runtime.SupportedContainerRuntimes))
+	_ = viper.BindEnv("container-runtime", "KUBECTL_PLUGINS_LOCAL_FLAG_CONTAINER_RUNTIME")
+	_ = viper.BindPFlag("container-runtime", cmd.Flags().Lookup("container-runtime"))
+
+	addExtcapFlags(cmd, &ksniff.extcap)
+
 	return cmd
 }
 
+// getStringArray reads a repeatable flag, whose rules may contain commas, or its whitespace separated
+// environment variable. viper returns the value of a string array flag as a single '[a,"b,c"]' csv string.
+func getStringArray(key string) []string {
+	value, ok := viper.Get(key).(string)
+	if !ok || !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return viper.GetStringSlice(key)
+	}
+
+	values, err := csv.NewReader(strings.NewReader(value[1 : len(value)-1])).Read()
+	if err != nil {
+		return nil
+	}
+
+	return values
+}
+
 func (o *Ksniff) Complete(cmd *cobra.Command, args []string) error {
 
-	if len(args) < minimumNumberOfArguments {
+	// wireshark passes the pod as a flag when capturing as an extcap interface
+	if len(args) < minimumNumberOfArguments && !o.extcap.capture {
 		_ = cmd.Usage()
 		return errors.New("not enough arguments")
 	}
 
-	o.settings.UserSpecifiedPodName = args[0]
-	if o.settings.UserSpecifiedPodName == "" {
-		return errors.New("pod name is empty")
+	if len(args) > 0 {
+		o.settings.UserSpecifiedPodName = args[0]
+		if o.settings.UserSpecifiedPodName == "" {
+			return errors.New("pod name is empty")
+		}
 	}
 
 	o.settings.UserSpecifiedNamespace = viper.GetString("namespace")
@@ -166,14 +308,37 @@ func (o *Ksniff) Complete(cmd *cobra.Command, args []string) error {
 	o.settings.UserSpecifiedInterface = viper.GetString("interface")
 	o.settings.UserSpecifiedFilter = viper.GetString("filter")
 	o.settings.UserSpecifiedOutputFile = viper.GetString("output-file")
+	o.settings.UserSpecifiedListenAddress = viper.GetString("listen")
+	o.settings.UserSpecifiedFifoPath = viper.GetString("fifo")
+	o.settings.UserSpecifiedViewer = viper.GetString("viewer")
+	o.settings.UserSpecifiedDecodeAs = getStringArray("decode-as")
+	o.settings.UserSpecifiedTui = viper.GetBool("tui")
+	o.settings.UserSpecifiedSummary = viper.GetBool("summary")
+	o.settings.UserSpecifiedSummaryFile = viper.GetString("summary-file")
+	o.settings.UserSpecifiedDNSLog = viper.GetBool("dns-log")
+	o.settings.UserSpecifiedHTTPLog = viper.GetBool("http-log")
+	o.settings.UserSpecifiedLogFormat = viper.GetString("log-format")
 	o.settings.UserSpecifiedLocalTcpdumpPath = viper.GetString("local-tcpdump-path")
 	o.settings.UserSpecifiedRemoteTcpdumpPath = viper.GetString("remote-tcpdump-path")
 	o.settings.UserSpecifiedVerboseMode = viper.GetBool("verbose")
 	o.settings.UserSpecifiedPrivilegedMode = viper.GetBool("privileged")
+	o.settings.UserSpecifiedKeepBinary = viper.GetBool("keep-binary")
+	o.settings.UserSpecifiedSkipFilterValidation = viper.GetBool("skip-filter-validation")
+	o.settings.UserSpecifiedNoFilterRefresh = viper.GetBool("no-filter-refresh")
+	o.settings.UserSpecifiedTLSKeyLog = viper.GetString("tls-keylog")
+	o.settings.UserSpecifiedTLSKeyLogRemote = viper.GetString("tls-keylog-remote")
 	o.settings.UserSpecifiedKubeContext = viper.GetString("context")
+	o.settings.UserSpecifiedContainerRuntime = viper.GetString("container-runtime")
 	o.settings.UseDefaultImage = !cmd.Flag("image").Changed
-	o.settings.UseDefaultTCPDumpImage = !cmd.Flag("tcpdump-image").Changed
 	o.settings.UseDefaultSocketPath = !cmd.Flag("socket").Changed
+	o.settings.UseDefaultRemoteTcpdumpPath = !cmd.Flag("remote-tcpdump-path").Changed &&
+		o.settings.UserSpecifiedRemoteTcpdumpPath == tcpdumpRemotePath
+
+	if o.extcap.capture {
+		if err := o.completeExtcapCapture(); err != nil {
+			return err
+		}
+	}
 
 	var err error
 
@@ -182,11 +347,18 @@ func (o *Ksniff) Complete(cmd *cobra.Command, args []string) error {
 		log.SetLevel(log.DebugLevel)
 	}
 
-	tcpdumpLocalBinaryPathLookupList, err = o.buildTcpdumpBinaryPathLookupList()
+	tcpdumpLocalBinaryDirLookupList, err = buildTcpdumpBinaryDirLookupList()
 	if err != nil {
 		return err
 	}
 
+	return o.loadKubeConfig()
+}
+
+// loadKubeConfig creates the api client of the selected kubeconfig context.
+func (o *Ksniff) loadKubeConfig() error {
+	var err error
+
 	o.rawConfig, err = o.configFlags.ToRawKubeConfigLoader().RawConfig()
 	if err != nil {
 		return err
@@ -230,7 +402,7 @@ func (o *Ksniff) Complete(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func (o *Ksniff) buildTcpdumpBinaryPathLookupList() ([]string, error) {
+func buildTcpdumpBinaryDirLookupList() ([]string, error) {
 	userHomeDir, err := homedir.Dir()
 	if err != nil {
 		return nil, err
@@ -242,12 +414,22 @@ func (o *Ksniff) buildTcpdumpBinaryPathLookupList() ([]string, error) {
 	}
 
 	ksniffBinaryDir := filepath.Dir(ksniffBinaryPath)
-	ksniffBinaryPath = filepath.Join(ksniffBinaryDir, tcpdumpBinaryName)
 
-	kubeKsniffPluginFolder := filepath.Join(userHomeDir, filepath.FromSlash("/.kube/plugin/sniff/"), tcpdumpBinaryName)
+	kubeKsniffPluginFolder := filepath.Join(userHomeDir, filepath.FromSlash("/.kube/plugin/sniff/"))
 
-	return append([]string{o.settings.UserSpecifiedLocalTcpdumpPath, ksniffBinaryPath},
-		filepath.Join("/usr/local/bin/", tcpdumpBinaryName), kubeKsniffPluginFolder), nil
+	return []string{ksniffBinaryDir, "/usr/local/bin/", kubeKsniffPluginFolder}, nil
+}
+
+// tcpdumpBinaryNames lists the static tcpdump binary names matching the node platform, by order of preference.
+func tcpdumpBinaryNames(nodeOperatingSystem string, nodeArchitecture string) []string {
+	names := []string{tcpdump.PlatformBinaryName(nodeOperatingSystem, nodeArchitecture)}
+
+	// the unqualified static tcpdump binary shipped by older releases is built for linux/amd64
+	if nodeOperatingSystem == defaultNodeOperatingSystem && nodeArchitecture == defaultNodeArchitecture {
+		names = append(names, tcpdump.BinaryName)
+	}
+
+	return names
 }
 
 func (o *Ksniff) Validate() error {
@@ -259,15 +441,47 @@ func (o *Ksniff) Validate() error {
 		return errors.New("namespace value is empty should be custom or default")
 	}
 
-	var err error
-
-	if !o.settings.UserSpecifiedPrivilegedMode {
-		o.settings.UserSpecifiedLocalTcpdumpPath, err = findLocalTcpdumpBinaryPath()
-		if err != nil {
+	if o.settings.UserSpecifiedListenAddress != "" {
+		if _, _, err := output.ParseListenAddress(o.settings.UserSpecifiedListenAddress); err != nil {
 			return err
 		}
+	}
+
+	if o.settings.UserSpecifiedTui {
+		if o.settings.UserSpecifiedOutputFile == "-" {
+			return errors.New("the terminal view can't be combined with the stdout output")
+		}
 
-		log.Infof("using tcpdump path at: '%s'", o.settings.UserSpecifiedLocalTcpdumpPath)
+		if !tui.IsTerminal(os.Stdout) {
+			return errors.New("the terminal view requires a terminal")
+		}
+	}
+
+	if o.settings.UserSpecifiedTLSKeyLog != "" && o.settings.UserSpecifiedTLSKeyLogRemote != "" {
+		return errors.New("only one of the local and remote tls key logs can be specified")
+	}
+
+	if o.settings.UserSpecifiedDNSLog || o.settings.UserSpecifiedHTTPLog {
+		if o.settings.UserSpecifiedOutputFile == "-" {
+			return errors.New("the dns and http logs can't be combined with the stdout output")
+		}
+
+		if o.settings.UserSpecifiedTui {
+			return errors.New("the dns and http logs can't be combined with the terminal view")
+		}
+	}
+
+	if o.settings.UserSpecifiedLogFormat != logFormatText && o.settings.UserSpecifiedLogFormat != logFormatJSON {
+		return errors.Errorf("invalid log format: '%s', expected '%s' or '%s'", o.settings.UserSpecifiedLogFormat,
+			logFormatText, logFormatJSON)
+	}
+
+	if o.settings.UserSpecifiedOutputFile == "" && o.settings.UserSpecifiedListenAddress == "" &&
+		o.settings.UserSpecifiedFifoPath == "" && !o.settings.UserSpecifiedTui && !o.settings.UserSpecifiedDNSLog &&
+		!o.settings.UserSpecifiedHTTPLog {
+		if err := o.selectViewer(); err != nil {
+			return err
+		}
 	}
 
 	pod, err := o.clientset.CoreV1().Pods(o.resultingContext.Namespace).Get(context.TODO(), o.settings.UserSpecifiedPodName, v1.GetOptions{})
@@ -279,10 +493,42 @@ func (o *Ksniff) Validate() error {
 		return errors.Errorf("cannot sniff on a container in a completed pod; current phase is %s", pod.Status.Phase)
 	}
 
+	if err := o.expandFilter(pod); err != nil {
+		return err
+	}
+
+	if err := o.validateFilter(); err != nil {
+		return err
+	}
+
 	o.settings.DetectedPodNodeName = pod.Spec.NodeName
 
 	log.Debugf("pod '%s' status: '%s'", o.settings.UserSpecifiedPodName, pod.Status.Phase)
 
+	node, err := o.clientset.CoreV1().Nodes().Get(context.TODO(), o.settings.DetectedPodNodeName, v1.GetOptions{})
+	if err != nil {
+		if o.settings.UserSpecifiedPrivilegedMode {
+			return err
+		}
+
+		log.WithError(err).Warnf("failed to get node: '%s', assuming platform: '%s/%s'",
+			o.settings.DetectedPodNodeName, defaultNodeOperatingSystem, defaultNodeArchitecture)
+		node = &corev1.Node{}
+	}
+
+	o.settings.DetectedNodeOperatingSystem = node.Status.NodeInfo.OperatingSystem
+	if o.settings.DetectedNodeOperatingSystem == "" {
+		o.settings.DetectedNodeOperatingSystem = defaultNodeOperatingSystem
+	}
+
+	o.settings.DetectedNodeArchitecture = node.Status.NodeInfo.Architecture
+	if o.settings.DetectedNodeArchitecture == "" {
+		o.settings.DetectedNodeArchitecture = defaultNodeArchitecture
+	}
+
+	log.Debugf("node '%s' platform: '%s/%s'", o.settings.DetectedPodNodeName,
+		o.settings.DetectedNodeOperatingSystem, o.settings.DetectedNodeArchitecture)
+
 	if len(pod.Spec.Containers) < 1 {
 		return errors.New("no containers in specified pod")
 	}
@@ -297,20 +543,108 @@ func (o *Ksniff) Validate() error {
 		return err
 	}
 
+	sandboxErr := o.checkSandboxedRuntime(pod)
+
+	if err := o.checkReadOnlyRootFilesystem(pod); err != nil {
+		return err
+	}
+
+	if !o.settings.UserSpecifiedPrivilegedMode {
+		if err := sniffer.CheckCaptureCapabilities(pod, o.settings.UserSpecifiedContainer); err != nil {
+			return err
+		}
+
+		if err := o.findLocalTcpdumpBinary(); err != nil {
+			if sandboxErr != nil {
+				return errors.Wrapf(sandboxErr, "static tcpdump fallback unavailable: %s", err)
+			}
+			return err
+		}
+	}
+
 	kubernetesApiService := kube.NewKubernetesApiService(o.clientset, o.restConfig, o.resultingContext.Namespace)
 
 	if o.settings.UserSpecifiedPrivilegedMode {
 		log.Info("sniffing method: privileged pod")
-		bridge := runtime.NewContainerRuntimeBridge(o.settings.DetectedContainerRuntime)
+
+		if err := o.detectContainerRuntime(node); err != nil {
+			return err
+		}
+
+		bridge, err := runtime.NewContainerRuntimeBridge(o.settings.DetectedContainerRuntime)
+		if err != nil {
+			return err
+		}
+
 		o.snifferService = sniffer.NewPrivilegedPodRemoteSniffingService(o.settings, kubernetesApiService, bridge)
 	} else {
 		log.Info("sniffing method: upload static tcpdump")
 		o.snifferService = sniffer.NewUploadTcpdumpRemoteSniffingService(o.settings, kubernetesApiService)
 	}
 
+	if filter.IsRefreshable(o.settings.KubernetesAwareFilter) && !o.settings.UserSpecifiedNoFilterRefresh {
+		log.Info("capture filter will be refreshed when the referenced pods change")
+
+		watcher := kube.NewFilterWatcher(o.clientset, o.resultingContext.Namespace, o.filterResolver,
+			o.settings.KubernetesAwareFilter, o.settings.UserSpecifiedFilter)
+		o.snifferService = sniffer.NewFilterRefreshSnifferService(o.snifferService, o.settings, watcher)
+	}
+
+	var keyLog keylog.Source
+	if o.settings.UserSpecifiedTLSKeyLog != "" {
+		keyLog = keylog.LocalFile(o.settings.UserSpecifiedTLSKeyLog)
+	} else if o.settings.UserSpecifiedTLSKeyLogRemote != "" {
+		keyLog = kube.NewRemoteFile(o.clientset, o.restConfig, o.resultingContext.Namespace,
+			o.settings.UserSpecifiedPodName, o.settings.UserSpecifiedContainer, o.settings.UserSpecifiedTLSKeyLogRemote)
+	}
+
+	if keyLog != nil {
+		log.Infof("tls key log: '%s' will be embedded in the capture", keyLog)
+		o.snifferService = sniffer.NewKeyLogSnifferService(o.snifferService, keylog.NewWatcher(keyLog, keyLogReadInterval))
+	}
+
+	return nil
+}
+
+// expandFilter replaces the kubernetes references of the capture filter (e.g. 'svc:payments') with the
+// matching host and port primitives.
+func (o *Ksniff) expandFilter(pod *corev1.Pod) error {
+	if !filter.HasReferences(o.settings.UserSpecifiedFilter) {
+		return nil
+	}
+
+	o.filterResolver = kube.NewFilterResolver(o.clientset, o.resultingContext.Namespace, pod)
+
+	expandedFilter, err := filter.Expand(o.settings.UserSpecifiedFilter, o.filterResolver)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("expanded capture filter: '%s' to: '%s'", o.settings.UserSpecifiedFilter, expandedFilter)
+
+	o.settings.KubernetesAwareFilter = o.settings.UserSpecifiedFilter
+	o.settings.UserSpecifiedFilter = expandedFilter
+
 	return nil
 }
 
+// validateFilter parses the capture filter locally, failing before the remote tcpdump setup which may take a while.
+func (o *Ksniff) validateFilter() error {
+	if o.settings.UserSpecifiedSkipFilterValidation || o.settings.UserSpecifiedFilter == "" {
+		return nil
+	}
+
+	linkType := filter.LinkTypeForInterface(o.settings.UserSpecifiedInterface)
+
+	err := filter.Validate(o.settings.UserSpecifiedFilter, linkType)
+	if syntaxErr, ok := err.(*filter.SyntaxError); ok {
+		log.Errorf("invalid capture filter:\n%s", syntaxErr.Pointer())
+		log.Info("use --skip-filter-validation if the filter is valid and only rejected by ksniff")
+	}
+
+	return err
+}
+
 func (o *Ksniff) findContainerId(pod *corev1.Pod) error {
 	for _, containerStatus := range pod.Status.ContainerStatuses {
 		if o.settings.UserSpecifiedContainer == containerStatus.Name {
@@ -327,10 +661,204 @@ func (o *Ksniff) findContainerId(pod *corev1.Pod) error {
 	return errors.Errorf("couldn't find container: '%s' in pod: '%s'", o.settings.UserSpecifiedContainer, o.settings.UserSpecifiedPodName)
 }
 
-func findLocalTcpdumpBinaryPath() (string, error) {
-	log.Debugf("searching for tcpdump binary using lookup list: '%v'", tcpdumpLocalBinaryPathLookupList)
+// checkSandboxedRuntime switches sandboxed pods (gVisor, Kata Containers) to the static tcpdump mode,
+// as the privileged pod can't reach their network stack from the node. The returned error explains the switch,
+// it's reported when the static tcpdump binary isn't found.
+func (o *Ksniff) checkSandboxedRuntime(pod *corev1.Pod) *runtime.SandboxedRuntimeError {
+	if pod.Spec.RuntimeClassName == nil || *pod.Spec.RuntimeClassName == "" {
+		return nil
+	}
+
+	runtimeClassName := *pod.Spec.RuntimeClassName
+	handler := runtimeClassName
+
+	runtimeClass, err := o.clientset.NodeV1().RuntimeClasses().Get(context.TODO(), runtimeClassName, v1.GetOptions{})
+	if err != nil {
+		log.WithError(err).Debugf("failed to get runtime class: '%s', using its name as handler", runtimeClassName)
+	} else {
+		handler = runtimeClass.Handler
+	}
+
+	sandbox, isSandboxed := runtime.DetectSandboxedRuntime(handler)
+	if !isSandboxed {
+		return nil
+	}
+
+	log.Infof("pod runs in a %s sandbox (runtime class: '%s', handler: '%s')", sandbox, runtimeClassName, handler)
+	o.settings.DetectedSandboxedRuntime = sandbox
+
+	if sandbox == "gVisor" {
+		log.Info("capturing inside gVisor requires raw sockets, make sure runsc is configured with '--net-raw'")
+	}
+
+	if !o.settings.UserSpecifiedPrivilegedMode {
+		return nil
+	}
+
+	sandboxErr := &runtime.SandboxedRuntimeError{RuntimeClassName: runtimeClassName, Handler: handler, Sandbox: sandbox}
+
+	log.Warnf("%s, switching to static tcpdump mode", sandboxErr.Error())
+	o.settings.UserSpecifiedPrivilegedMode = false
+
+	return sandboxErr
+}
+
+// checkReadOnlyRootFilesystem uploads static tcpdump to a writable volume of containers with a read-only root
+// filesystem, and switches to the privileged mode when the container has no such volume.
+func (o *Ksniff) checkReadOnlyRootFilesystem(pod *corev1.Pod) error {
+	if o.settings.UserSpecifiedPrivilegedMode || !o.settings.UseDefaultRemoteTcpdumpPath {
+		return nil
+	}
+
+	container := findContainerSpec(pod, o.settings.UserSpecifiedContainer)
+	if container == nil || !isReadOnlyRootFilesystem(container) {
+		return nil
+	}
+
+	log.Infof("container: '%s' has a read-only root filesystem", container.Name)
+
+	writableDir, ok := findWritableTcpdumpDir(pod, container)
+	if ok {
+		o.settings.UserSpecifiedRemoteTcpdumpPath = path.Join(writableDir, tcpdump.BinaryName)
+		log.Infof("uploading static tcpdump to writable volume: '%s'", writableDir)
+
+		return nil
+	}
+
+	if o.settings.DetectedSandboxedRuntime != "" {
+		return errors.Errorf("container: '%s' has a read-only root filesystem and no writable emptyDir volume, "+
+			"static tcpdump can't be uploaded and the privileged mode can't capture inside a %s sandbox",
+			container.Name, o.settings.DetectedSandboxedRuntime)
+	}
+
+	log.Warnf("container: '%s' has a read-only root filesystem and no writable emptyDir volume, "+
+		"switching to privileged mode", container.Name)
+	o.settings.UserSpecifiedPrivilegedMode = true
+
+	return nil
+}
+
+func findContainerSpec(pod *corev1.Pod, containerName string) *corev1.Container {
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == containerName {
+			return &pod.Spec.Containers[i]
+		}
+	}
+
+	return nil
+}
+
+func isReadOnlyRootFilesystem(container *corev1.Container) bool {
+	return container.SecurityContext != nil && container.SecurityContext.ReadOnlyRootFilesystem != nil &&
+		*container.SecurityContext.ReadOnlyRootFilesystem
+}
+
+// findWritableTcpdumpDir returns the mount path of a writable emptyDir volume of the container, preferring /tmp
+// then /dev/shm. The /dev/shm mounted by the container runtime itself isn't used as it's usually mounted noexec.
+func findWritableTcpdumpDir(pod *corev1.Pod, container *corev1.Container) (string, bool) {
+	emptyDirVolumes := map[string]bool{}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.EmptyDir != nil {
+			emptyDirVolumes[volume.Name] = true
+		}
+	}
+
+	var writableDirs []string
+	for _, mount := range container.VolumeMounts {
+		if mount.ReadOnly || !emptyDirVolumes[mount.Name] {
+			continue
+		}
+
+		writableDirs = append(writableDirs, mount.MountPath)
+	}
+
+	if len(writableDirs) == 0 {
+		return "", false
+	}
+
+	for _, preferredDir := range []string{"/tmp", "/dev/shm"} {
+		for _, writableDir := range writableDirs {
+			if path.Clean(writableDir) == preferredDir {
+				return preferredDir, true
+			}
+		}
+	}
+
+	return path.Clean(writableDirs[0]), true
+}
+
+func (o *Ksniff) detectContainerRuntime(node *corev1.Node) error {
+	if o.settings.UserSpecifiedContainerRuntime != "" {
+		specifiedRuntime, ok := runtime.NormalizeContainerRuntimeName(o.settings.UserSpecifiedContainerRuntime)
+		if !ok {
+			return &runtime.UnsupportedContainerRuntimeError{ContainerIdScheme: o.settings.UserSpecifiedContainerRuntime}
+		}
 
-	for _, possibleTcpdumpPath := range tcpdumpLocalBinaryPathLookupList {
+		log.Infof("using specified container runtime: '%s'", specifiedRuntime)
+		o.settings.DetectedContainerRuntime = specifiedRuntime
+
+		return nil
+	}
+
+	nodeRuntimeVersion := node.Status.NodeInfo.ContainerRuntimeVersion
+	log.Debugf("node '%s' container runtime version: '%s'", node.Name, nodeRuntimeVersion)
+
+	detectedRuntime, err := runtime.DetectContainerRuntime(o.settings.DetectedContainerRuntime, nodeRuntimeVersion)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("detected container runtime: '%s'", detectedRuntime)
+	o.settings.DetectedContainerRuntime = detectedRuntime
+
+	return nil
+}
+
+// findLocalTcpdumpBinary prefers the user specified tcpdump path, then the static tcpdump binary embedded
+// in the ksniff executable, and finally searches the lookup directories.
+func (o *Ksniff) findLocalTcpdumpBinary() error {
+	if o.settings.UserSpecifiedLocalTcpdumpPath == "" {
+		embeddedBinary, ok := tcpdump.Embedded(o.settings.DetectedNodeOperatingSystem, o.settings.DetectedNodeArchitecture)
+		if ok {
+			log.Infof("using embedded tcpdump binary for node platform: '%s/%s'",
+				o.settings.DetectedNodeOperatingSystem, o.settings.DetectedNodeArchitecture)
+			o.settings.EmbeddedTcpdumpBinary = embeddedBinary
+
+			return nil
+		}
+	}
+
+	tcpdumpPath, err := findLocalTcpdumpBinaryPath(o.settings.UserSpecifiedLocalTcpdumpPath,
+		o.settings.DetectedNodeOperatingSystem, o.settings.DetectedNodeArchitecture)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("using tcpdump path at: '%s'", tcpdumpPath)
+	o.settings.UserSpecifiedLocalTcpdumpPath = tcpdumpPath
+
+	return nil
+}
+
+// findLocalTcpdumpBinaryPath returns the user specified tcpdump path if it exists, otherwise the first static
+// tcpdump binary matching the node platform found in the lookup directories.
+func findLocalTcpdumpBinaryPath(userSpecifiedPath string, nodeOperatingSystem string, nodeArchitecture string) (string, error) {
+	var lookupList []string
+
+	if userSpecifiedPath != "" {
+		lookupList = append(lookupList, userSpecifiedPath)
+	}
+
+	binaryNames := tcpdumpBinaryNames(nodeOperatingSystem, nodeArchitecture)
+	for _, lookupDir := range tcpdumpLocalBinaryDirLookupList {
+		for _, binaryName := range binaryNames {
+			lookupList = append(lookupList, filepath.Join(lookupDir, binaryName))
+		}
+	}
+
+	log.Debugf("searching for tcpdump binary using lookup list: '%v'", lookupList)
+
+	for _, possibleTcpdumpPath := range lookupList {
 		if _, err := os.Stat(possibleTcpdumpPath); err == nil {
 			log.Debugf("tcpdump binary found at: '%s'", possibleTcpdumpPath)
 
@@ -340,19 +868,296 @@ func findLocalTcpdumpBinaryPath() (string, error) {
 		log.Debugf("tcpdump binary was not found at: '%s'", possibleTcpdumpPath)
 	}
 
-	return "", errors.Errorf("couldn't find static tcpdump binary on any of: '%v'", tcpdumpLocalBinaryPathLookupList)
+	return "", errors.Errorf("couldn't find static tcpdump binary for node platform '%s/%s' (expected '%s') on any of: '%v'",
+		nodeOperatingSystem, nodeArchitecture, binaryNames[0], lookupList)
+}
+
+// selectViewer selects the viewer before starting the capture, so a missing viewer is reported right away.
+func (o *Ksniff) selectViewer() error {
+	var err error
+
+	if o.settings.UserSpecifiedViewer == "" {
+		o.viewer, err = viewer.Detect()
+		if err != nil {
+			return err
+		}
+
+		log.Debugf("detected viewer: '%s'", o.viewer.Name)
+		return nil
+	}
+
+	o.viewer, err = viewer.New(o.settings.UserSpecifiedViewer)
+	if err != nil {
+		return err
+	}
+
+	_, err = o.viewer.Path()
+	return err
+}
+
+func (o *Ksniff) createOutputFile() (io.Writer, error) {
+	log.Infof("output file option specified, storing output in: '%s'", o.settings.UserSpecifiedOutputFile)
+
+	if o.settings.UserSpecifiedOutputFile == "-" {
+		return os.Stdout, nil
+	}
+
+	return os.Create(o.settings.UserSpecifiedOutputFile)
+}
+
+// startOutputs starts the outputs of the capture: the output file, the listener and named pipe fanning out the
+// capture to their consumers, the terminal view, the dns and http logs and the summary. The writer is nil when none is
+// specified, the capture shown by the viewer is also written to the writer when it's not nil.
+func (o *Ksniff) startOutputs() (io.Writer, func(), error) {
+	var writers []io.Writer
+	var closers []io.Closer
+
+	closeOutputs := func() {
+		// the outputs stop accepting consumers before the broadcaster disconnects them
+		for i := len(closers) - 1; i >= 0; i-- {
+			if err := closers[i].Close(); err != nil {
+				log.WithError(err).Warn("failed to close capture output")
+			}
+		}
+	}
+
+	if o.settings.UserSpecifiedOutputFile != "" {
+		fileWriter, err := o.createOutputFile()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		writers = append(writers, fileWriter)
+	}
+
+	if o.settings.UserSpecifiedListenAddress != "" || o.settings.UserSpecifiedFifoPath != "" {
+		broadcaster := output.NewBroadcaster()
+		writers = append(writers, broadcaster)
+		closers = append(closers, broadcaster)
+
+		if o.settings.UserSpecifiedListenAddress != "" {
+			listener, err := output.Listen(o.settings.UserSpecifiedListenAddress, broadcaster)
+			if err != nil {
+				closeOutputs()
+				return nil, nil, err
+			}
+
+			closers = append(closers, listener)
+			log.Infof("streaming capture to the consumers of: '%s'", o.settings.UserSpecifiedListenAddress)
+		}
+
+		if o.settings.UserSpecifiedFifoPath != "" {
+			fifo, err := output.ServeFifo(o.settings.UserSpecifiedFifoPath, broadcaster)
+			if err != nil {
+				closeOutputs()
+				return nil, nil, err
+			}
+
+			closers = append(closers, fifo)
+			log.Infof("streaming capture to the readers of named pipe: '%s'", o.settings.UserSpecifiedFifoPath)
+		}
+	}
+
+	var podNames *kube.PodNames
+	if o.settings.UserSpecifiedTui || o.settings.UserSpecifiedDNSLog || o.settings.UserSpecifiedHTTPLog ||
+		o.settings.UserSpecifiedSummary || o.settings.UserSpecifiedSummaryFile != "" {
+		podNames = o.startPodNames(&closers)
+	}
+
+	// the dns and http logs share stdout
+	entries := &entryWriter{out: os.Stdout, json: o.settings.UserSpecifiedLogFormat == logFormatJSON}
+
+	if o.settings.UserSpecifiedDNSLog {
+		writers = append(writers, o.startDNSLog(podNames, entries, &closers))
+	}
+
+	if o.settings.UserSpecifiedHTTPLog {
+		writers = append(writers, o.startHTTPLog(podNames, entries, &closers))
+	}
+
+	// the summary is added before the terminal view, so it's printed once the view is closed
+	if o.settings.UserSpecifiedSummary || o.settings.UserSpecifiedSummaryFile != "" {
+		writers = append(writers, o.startSummary(podNames, &closers))
+	}
+
+	if o.settings.UserSpecifiedTui {
+		writers = append(writers, o.startTui(podNames, &closers))
+	}
+
+	if len(writers) == 0 {
+		return nil, closeOutputs, nil
+	}
+
+	return io.MultiWriter(writers...), closeOutputs, nil
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// startPodNames resolves the captured addresses to pod names until the outputs are closed.
+func (o *Ksniff) startPodNames(closers *[]io.Closer) *kube.PodNames {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	podNames := kube.NewPodNames(o.clientset, o.resultingContext.Namespace)
+	go podNames.Watch(ctx, podNamesRefreshInterval)
+
+	*closers = append(*closers, closerFunc(func() error {
+		cancel()
+		return nil
+	}))
+
+	return podNames
+}
+
+// startConsumer pipes the capture to the consumer. Once the consumer fails the capture is discarded, so the other
+// outputs aren't blocked. The channel is closed once the consumer returns.
+func startConsumer(consume func(io.Reader) error, failure string) (*io.PipeWriter, <-chan struct{}) {
+	reader, writer := io.Pipe()
+	consumed := make(chan struct{})
+
+	go func() {
+		defer close(consumed)
+
+		if err := consume(reader); err != nil {
+			log.WithError(err).Error(failure)
+			_, _ = io.Copy(ioutil.Discard, reader)
+		}
+	}()
+
+	return writer, consumed
+}
+
+// entryWriter writes the entries of the protocol logs to stdout, as text or json lines.
+type entryWriter struct {
+	mutex sync.Mutex
+	out   io.Writer
+	json  bool
+}
+
+func (w *entryWriter) write(entry fmt.Stringer) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.json {
+		_, _ = fmt.Fprintln(w.out, entry)
+		return
+	}
+
+	if err := json.NewEncoder(w.out).Encode(entry); err != nil {
+		log.WithError(err).Warn("failed to write log entry")
+	}
+}
+
+// startDNSLog prints the dns queries of the capture, the pending queries are printed as unanswered once it's closed.
+func (o *Ksniff) startDNSLog(podNames *kube.PodNames, entries *entryWriter, closers *[]io.Closer) io.Writer {
+	tracker := dns.NewTracker(podNames, func(entry *dns.Entry) {
+		entries.write(entry)
+	})
+
+	writer, consumed := startConsumer(tracker.Consume, "failed to parse the capture, the dns log is stopped")
+
+	*closers = append(*closers, closerFunc(func() error {
+		_ = writer.Close()
+		<-consumed
+		return nil
+	}))
+
+	return writer
+}
+
+// startHTTPLog prints the http requests of the capture, the pending requests are printed without response once it's
+// closed.
+func (o *Ksniff) startHTTPLog(podNames *kube.PodNames, entries *entryWriter, closers *[]io.Closer) io.Writer {
+	tracker := httplog.NewTracker(podNames, func(entry *httplog.Entry) {
+		entries.write(entry)
+	})
+
+	writer, consumed := startConsumer(tracker.Consume, "failed to parse the capture, the http log is stopped")
+
+	*closers = append(*closers, closerFunc(func() error {
+		_ = writer.Close()
+		<-consumed
+		return nil
+	}))
+
+	return writer
+}
+
+// startSummary collects the summary of the capture, printed or written to the summary file once it's closed.
+func (o *Ksniff) startSummary(podNames *kube.PodNames, closers *[]io.Closer) io.Writer {
+	collector := report.NewCollector(podNames)
+	writer, consumed := startConsumer(collector.Consume, "failed to parse the capture, the summary is incomplete")
+
+	*closers = append(*closers, closerFunc(func() error {
+		_ = writer.Close()
+		<-consumed
+
+		summary := collector.Summary()
+
+		if o.settings.UserSpecifiedSummary {
+			if err := report.WriteText(os.Stderr, summary); err != nil {
+				return err
+			}
+		}
+
+		if o.settings.UserSpecifiedSummaryFile != "" {
+			if err := report.WriteFile(o.settings.UserSpecifiedSummaryFile, summary); err != nil {
+				return err
+			}
+
+			log.Infof("capture summary written to: '%s'", o.settings.UserSpecifiedSummaryFile)
+		}
+
+		return nil
+	}))
+
+	return writer
+}
+
+// startTui shows the terminal view of the capture, the logs are shown by the view until it's closed.
+func (o *Ksniff) startTui(podNames *kube.PodNames, closers *[]io.Closer) io.Writer {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	title := fmt.Sprintf("%s/%s/%s", o.resultingContext.Namespace, o.settings.UserSpecifiedPodName, o.settings.UserSpecifiedContainer)
+	view := tui.NewView(os.Stdout, title, podNames)
+	writer, _ := startConsumer(view.Consume, "failed to parse the capture, the view isn't updated anymore")
+
+	go view.Run(ctx)
+	log.SetOutput(view)
+
+	*closers = append(*closers, closerFunc(func() error {
+		cancel()
+		_ = writer.Close()
+		log.SetOutput(os.Stderr)
+		return view.Close()
+	}))
+
+	return writer
 }
 
 func (o *Ksniff) Run() error {
 	log.Infof("sniffing on pod: '%s' [namespace: '%s', container: '%s', filter: '%s', interface: '%s']",
 		o.settings.UserSpecifiedPodName, o.resultingContext.Namespace, o.settings.UserSpecifiedContainer, o.settings.UserSpecifiedFilter, o.settings.UserSpecifiedInterface)
 
-	err := o.snifferService.Setup()
+	// the outputs are started first, e.g. the listen address may be in use
+	writer, closeOutputs, err := o.startOutputs()
+	if err != nil {
+		return err
+	}
+
+	err = o.snifferService.Setup()
 	if err != nil {
+		closeOutputs()
 		return err
 	}
 
 	defer func() {
+		// the terminal view is closed first, so the cleanup logs are shown
+		closeOutputs()
+
 		log.Info("starting sniffer cleanup")
 
 		err := o.snifferService.Cleanup()
@@ -364,47 +1169,108 @@ func (o *Ksniff) Run() error {
 		log.Info("sniffer cleanup completed successfully")
 	}()
 
-	if o.settings.UserSpecifiedOutputFile != "" {
-		log.Infof("output file option specified, storing output in: '%s'", o.settings.UserSpecifiedOutputFile)
+	// captures are usually stopped using ctrl+c, or a SIGTERM e.g. by wireshark in the extcap mode: the remote
+	// sniffing is stopped so Run returns normally and the cleanup runs before exiting
+	interrupted := make(chan struct{})
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupts)
 
-		var err error
-		var fileWriter io.Writer
+	done := make(chan struct{})
+	defer close(done)
 
-		if o.settings.UserSpecifiedOutputFile == "-" {
-			fileWriter = os.Stdout
-		} else {
-			fileWriter, err = os.Create(o.settings.UserSpecifiedOutputFile)
-			if err != nil {
-				return err
+	go func() {
+		select {
+		case <-interrupts:
+			log.Info("stopping the capture")
+			close(interrupted)
+
+			if err := o.snifferService.Stop(); err != nil {
+				log.WithError(err).Warn("failed to stop remote sniffing")
 			}
+		case <-done:
 		}
+	}()
 
-		err = o.snifferService.Start(fileWriter)
-		if err != nil {
+	// the remote sniffing fails once stopped, its failure is expected when interrupted
+	isInterrupted := func() bool {
+		select {
+		case <-interrupted:
+			return true
+		default:
+			return false
+		}
+	}
+
+	if o.viewer == nil {
+		err = o.snifferService.Start(writer)
+		if err != nil && !isInterrupted() {
 			return err
 		}
 
 	} else {
-		log.Info("spawning wireshark!")
-
-		title := fmt.Sprintf("gui.window_title:%s/%s/%s", o.resultingContext.Namespace, o.settings.UserSpecifiedPodName, o.settings.UserSpecifiedContainer)
-		cmd := exec.Command("wireshark", "-k", "-i", "-", "-o", title)
+		log.Infof("spawning %s!", o.viewer.Name)
+
+		cmd, err := o.viewer.Command(&viewer.Data{
+			Title:     fmt.Sprintf("%s/%s/%s", o.resultingContext.Namespace, o.settings.UserSpecifiedPodName, o.settings.UserSpecifiedContainer),
+			Namespace: o.resultingContext.Namespace,
+			Pod:       o.settings.UserSpecifiedPodName,
+			Container: o.settings.UserSpecifiedContainer,
+			Filter:    o.settings.UserSpecifiedFilter,
+			DecodeAs:  o.settings.UserSpecifiedDecodeAs,
+		})
+		if err != nil {
+			return err
+		}
 
 		stdinWriter, err := cmd.StdinPipe()
 		if err != nil {
 			return err
 		}
 
+		// the capture is also written to the outputs not replacing the viewer, e.g. the summary
+		var captureWriter io.Writer = stdinWriter
+		if writer != nil {
+			captureWriter = io.MultiWriter(stdinWriter, writer)
+		}
+
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+
+		startErrors := make(chan error, 1)
+
 		go func() {
-			err := o.snifferService.Start(stdinWriter)
-			if err != nil {
-				log.WithError(err).Errorf("failed to start remote sniffing, stopping wireshark")
+			err := o.snifferService.Start(captureWriter)
+			if err != nil && !isInterrupted() {
+				log.WithError(err).Errorf("failed to start remote sniffing, stopping %s", o.viewer.Name)
+				startErrors <- err
 				_ = cmd.Process.Kill()
 			}
 		}()
 
-		err = cmd.Run()
-		if err != nil {
+		// the viewer is stopped with the capture, e.g. when ksniff alone received the SIGTERM
+		viewerDone := make(chan struct{})
+		defer close(viewerDone)
+
+		go func() {
+			select {
+			case <-interrupted:
+				_ = cmd.Process.Kill()
+			case <-viewerDone:
+			}
+		}()
+
+		err = cmd.Wait()
+
+		// the remote sniffing failure is the actual reason the viewer was stopped
+		select {
+		case startErr := <-startErrors:
+			return startErr
+		default:
+		}
+
+		if err != nil && !isInterrupted() {
 			return err
 		}
 	}
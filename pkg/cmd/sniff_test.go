@@ -1,11 +1,15 @@
 package cmd
 
 import (
+	"io/ioutil"
 	"ksniff/pkg/config"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 
 	"testing"
@@ -55,3 +59,162 @@ func TestComplete_PodNameSpecified(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, "pod-name", settings.UserSpecifiedPodName)
 }
+
+func TestComplete_DecodeAsFlag(t *testing.T) {
+	// given
+	settings := config.NewKsniffSettings(genericclioptions.IOStreams{})
+	sniff := NewKsniff(settings)
+	cmd := NewCmdSniff(genericclioptions.IOStreams{})
+	assert.Nil(t, cmd.Flags().Parse([]string{"--decode-as", "tcp.port==8080,http", "--decode-as", "udp.port==5353,dns"}))
+
+	// when
+	err := sniff.Complete(cmd, []string{"pod-name"})
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"tcp.port==8080,http", "udp.port==5353,dns"}, settings.UserSpecifiedDecodeAs)
+}
+
+func TestComplete_DecodeAsEnv(t *testing.T) {
+	// given
+	defer os.Unsetenv("KUBECTL_PLUGINS_LOCAL_FLAG_DECODE_AS")
+	assert.Nil(t, os.Setenv("KUBECTL_PLUGINS_LOCAL_FLAG_DECODE_AS", "tcp.port==8080,http udp.port==5353,dns"))
+	settings := config.NewKsniffSettings(genericclioptions.IOStreams{})
+	sniff := NewKsniff(settings)
+	cmd := NewCmdSniff(genericclioptions.IOStreams{})
+
+	// when
+	err := sniff.Complete(cmd, []string{"pod-name"})
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"tcp.port==8080,http", "udp.port==5353,dns"}, settings.UserSpecifiedDecodeAs)
+}
+
+func TestTcpdumpBinaryNames_LinuxAmd64(t *testing.T) {
+	// when
+	result := tcpdumpBinaryNames("linux", "amd64")
+
+	// then
+	assert.Equal(t, []string{"static-tcpdump-linux-amd64", "static-tcpdump"}, result)
+}
+
+func TestTcpdumpBinaryNames_LinuxArm64(t *testing.T) {
+	// when
+	result := tcpdumpBinaryNames("linux", "arm64")
+
+	// then
+	assert.Equal(t, []string{"static-tcpdump-linux-arm64"}, result)
+}
+
+func TestFindLocalTcpdumpBinaryPath_MatchingArchitecture(t *testing.T) {
+	// given
+	dir, err := ioutil.TempDir("", "ksniff")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"static-tcpdump", "static-tcpdump-linux-arm64"} {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte{}, 0755))
+	}
+	defer func(lookupList []string) { tcpdumpLocalBinaryDirLookupList = lookupList }(tcpdumpLocalBinaryDirLookupList)
+	tcpdumpLocalBinaryDirLookupList = []string{dir}
+
+	// when
+	result, err := findLocalTcpdumpBinaryPath("", "linux", "arm64")
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "static-tcpdump-linux-arm64"), result)
+}
+
+func TestFindLocalTcpdumpBinaryPath_MissingArchitecture(t *testing.T) {
+	// given
+	dir, err := ioutil.TempDir("", "ksniff")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "static-tcpdump"), []byte{}, 0755))
+	defer func(lookupList []string) { tcpdumpLocalBinaryDirLookupList = lookupList }(tcpdumpLocalBinaryDirLookupList)
+	tcpdumpLocalBinaryDirLookupList = []string{dir}
+
+	// when
+	result, err := findLocalTcpdumpBinaryPath("", "linux", "arm64")
+
+	// then
+	assert.Equal(t, "", result)
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "static-tcpdump-linux-arm64"))
+}
+
+func buildReadOnlyPod(volumes []corev1.Volume, mounts []corev1.VolumeMount) *corev1.Pod {
+	readOnly := true
+
+	return &corev1.Pod{Spec: corev1.PodSpec{
+		Volumes: volumes,
+		Containers: []corev1.Container{{
+			Name:            "app",
+			VolumeMounts:    mounts,
+			SecurityContext: &corev1.SecurityContext{ReadOnlyRootFilesystem: &readOnly},
+		}},
+	}}
+}
+
+func TestIsReadOnlyRootFilesystem(t *testing.T) {
+	// given
+	pod := buildReadOnlyPod(nil, nil)
+
+	// when
+	container := findContainerSpec(pod, "app")
+
+	// then
+	assert.True(t, isReadOnlyRootFilesystem(container))
+	assert.False(t, isReadOnlyRootFilesystem(&corev1.Container{Name: "app"}))
+}
+
+func TestFindWritableTcpdumpDir_PrefersTmp(t *testing.T) {
+	// given
+	emptyDir := corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}
+	pod := buildReadOnlyPod(
+		[]corev1.Volume{{Name: "cache", VolumeSource: emptyDir}, {Name: "tmp", VolumeSource: emptyDir}},
+		[]corev1.VolumeMount{{Name: "cache", MountPath: "/var/cache"}, {Name: "tmp", MountPath: "/tmp/"}})
+
+	// when
+	dir, ok := findWritableTcpdumpDir(pod, findContainerSpec(pod, "app"))
+
+	// then
+	assert.True(t, ok)
+	assert.Equal(t, "/tmp", dir)
+}
+
+func TestFindWritableTcpdumpDir_SkipsReadOnlyAndNonEmptyDirMounts(t *testing.T) {
+	// given
+	pod := buildReadOnlyPod(
+		[]corev1.Volume{
+			{Name: "config", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{}}},
+			{Name: "readonly", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+			{Name: "shm", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory}}},
+		},
+		[]corev1.VolumeMount{
+			{Name: "config", MountPath: "/tmp"},
+			{Name: "readonly", MountPath: "/data", ReadOnly: true},
+			{Name: "shm", MountPath: "/dev/shm"},
+		})
+
+	// when
+	dir, ok := findWritableTcpdumpDir(pod, findContainerSpec(pod, "app"))
+
+	// then
+	assert.True(t, ok)
+	assert.Equal(t, "/dev/shm", dir)
+}
+
+func TestFindWritableTcpdumpDir_NoWritableVolume(t *testing.T) {
+	// given
+	pod := buildReadOnlyPod(nil, nil)
+
+	// when
+	_, ok := findWritableTcpdumpDir(pod, findContainerSpec(pod, "app"))
+
+	// then
+	assert.False(t, ok)
+}
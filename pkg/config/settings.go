@@ -9,25 +9,46 @@ type KsniffSettings struct {
 	UserSpecifiedPodName           string
 	UserSpecifiedInterface         string
 	UserSpecifiedFilter            string
+	KubernetesAwareFilter          string
 	UserSpecifiedPodCreateTimeout  time.Duration
 	UserSpecifiedContainer         string
 	UserSpecifiedNamespace         string
 	UserSpecifiedOutputFile        string
+	UserSpecifiedListenAddress     string
+	UserSpecifiedFifoPath          string
+	UserSpecifiedViewer            string
+	UserSpecifiedDecodeAs          []string
+	UserSpecifiedTui               bool
+	UserSpecifiedSummary           bool
+	UserSpecifiedSummaryFile       string
+	UserSpecifiedDNSLog            bool
+	UserSpecifiedHTTPLog           bool
+	UserSpecifiedLogFormat         string
 	UserSpecifiedLocalTcpdumpPath  string
 	UserSpecifiedRemoteTcpdumpPath string
+	EmbeddedTcpdumpBinary          []byte
 	UserSpecifiedVerboseMode       bool
 	UserSpecifiedPrivilegedMode    bool
+	UserSpecifiedKeepBinary        bool
+	UserSpecifiedNoFilterRefresh   bool
+	UserSpecifiedTLSKeyLog         string
+	UserSpecifiedTLSKeyLogRemote   string
 	UserSpecifiedImage             string
+	UserSpecifiedContainerRuntime  string
 	DetectedPodNodeName            string
+	DetectedNodeOperatingSystem    string
+	DetectedNodeArchitecture       string
 	DetectedContainerId            string
 	DetectedContainerRuntime       string
+	DetectedSandboxedRuntime       string
 	Image                          string
-	TCPDumpImage                   string
 	UseDefaultImage                bool
-	UseDefaultTCPDumpImage         bool
 	UserSpecifiedKubeContext       string
 	SocketPath                     string
 	UseDefaultSocketPath           bool
+	UseDefaultRemoteTcpdumpPath    bool
+
+	UserSpecifiedSkipFilterValidation bool
 }
 
 func NewKsniffSettings(streams genericclioptions.IOStreams) *KsniffSettings {
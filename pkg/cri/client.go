@@ -0,0 +1,134 @@
+package cri
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	criv1 "k8s.io/cri-api/pkg/apis/runtime/v1"
+	criv1alpha2 "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+const unixSocketScheme = "unix://"
+
+// runtimeService is the subset of the CRI RuntimeService used by ksniff,
+// implemented for both the v1 and v1alpha2 CRI api versions.
+type runtimeService interface {
+	version(ctx context.Context) (string, error)
+	containerStatus(ctx context.Context, containerId string) (map[string]string, error)
+}
+
+type Client struct {
+	conn    *grpc.ClientConn
+	service runtimeService
+}
+
+// NewClient connects to the CRI socket at the given endpoint (e.g. 'unix:///run/containerd/containerd.sock')
+// and negotiates the CRI api version supported by the runtime.
+func NewClient(ctx context.Context, endpoint string, timeout time.Duration) (*Client, error) {
+	socketPath := strings.TrimPrefix(endpoint, unixSocketScheme)
+
+	dialCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := grpc.DialContext(dialCtx, socketPath,
+		grpc.WithInsecure(),
+		grpc.WithBlock(),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", addr)
+		}))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed connecting to container runtime endpoint: '%s'", endpoint)
+	}
+
+	client := &Client{conn: conn}
+
+	v1Service := &v1RuntimeService{runtime: criv1.NewRuntimeServiceClient(conn)}
+	runtimeVersion, err := v1Service.version(ctx)
+	if err == nil {
+		log.Debugf("connected to '%s' using CRI v1", runtimeVersion)
+		client.service = v1Service
+		return client, nil
+	}
+
+	if status.Code(err) != codes.Unimplemented {
+		_ = conn.Close()
+		return nil, errors.Wrap(err, "failed getting container runtime version")
+	}
+
+	v1alpha2Service := &v1alpha2RuntimeService{runtime: criv1alpha2.NewRuntimeServiceClient(conn)}
+	runtimeVersion, err = v1alpha2Service.version(ctx)
+	if err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrap(err, "failed getting container runtime version")
+	}
+
+	log.Debugf("connected to '%s' using CRI v1alpha2", runtimeVersion)
+	client.service = v1alpha2Service
+
+	return client, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// InspectContainer resolves the process id and network namespace path of the given container.
+func (c *Client) InspectContainer(ctx context.Context, containerId string) (*ContainerInfo, error) {
+	info, err := c.service.containerStatus(ctx, containerId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed getting status of container: '%s'", containerId)
+	}
+
+	return ParseContainerInfo(info)
+}
+
+type v1RuntimeService struct {
+	runtime criv1.RuntimeServiceClient
+}
+
+func (s *v1RuntimeService) version(ctx context.Context) (string, error) {
+	response, err := s.runtime.Version(ctx, &criv1.VersionRequest{})
+	if err != nil {
+		return "", err
+	}
+
+	return response.RuntimeName + " " + response.RuntimeVersion, nil
+}
+
+func (s *v1RuntimeService) containerStatus(ctx context.Context, containerId string) (map[string]string, error) {
+	response, err := s.runtime.ContainerStatus(ctx, &criv1.ContainerStatusRequest{ContainerId: containerId, Verbose: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Info, nil
+}
+
+type v1alpha2RuntimeService struct {
+	runtime criv1alpha2.RuntimeServiceClient
+}
+
+func (s *v1alpha2RuntimeService) version(ctx context.Context) (string, error) {
+	response, err := s.runtime.Version(ctx, &criv1alpha2.VersionRequest{})
+	if err != nil {
+		return "", err
+	}
+
+	return response.RuntimeName + " " + response.RuntimeVersion, nil
+}
+
+func (s *v1alpha2RuntimeService) containerStatus(ctx context.Context, containerId string) (map[string]string, error) {
+	response, err := s.runtime.ContainerStatus(ctx, &criv1alpha2.ContainerStatusRequest{ContainerId: containerId, Verbose: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Info, nil
+}
@@ -0,0 +1,71 @@
+package cri
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+type ContainerInfo struct {
+	Pid                  int    `json:"pid"`
+	NetworkNamespacePath string `json:"netns"`
+}
+
+type verboseInfo struct {
+	Pid         int `json:"pid"`
+	RuntimeSpec struct {
+		Linux struct {
+			Namespaces []struct {
+				Type string `json:"type"`
+				Path string `json:"path"`
+			} `json:"namespaces"`
+		} `json:"linux"`
+	} `json:"runtimeSpec"`
+}
+
+// ParseContainerInfo extracts the container process id and network namespace path from the verbose
+// info returned by ContainerStatus.
+// containerd and CRI-O 1.18 and later nest the details as json under the 'info' key,
+// CRI-O 1.17 and older report the pid as a first-level key.
+func ParseContainerInfo(info map[string]string) (*ContainerInfo, error) {
+	if rawInfo, ok := info["info"]; ok {
+		var parsed verboseInfo
+		if err := json.Unmarshal([]byte(rawInfo), &parsed); err != nil {
+			return nil, errors.Wrap(err, "failed parsing container verbose info")
+		}
+
+		if parsed.Pid <= 0 {
+			return nil, errors.New("container verbose info doesn't contain a pid")
+		}
+
+		result := &ContainerInfo{Pid: parsed.Pid}
+		for _, namespace := range parsed.RuntimeSpec.Linux.Namespaces {
+			if namespace.Type == "network" {
+				result.NetworkNamespacePath = namespace.Path
+			}
+		}
+
+		if result.NetworkNamespacePath == "" {
+			result.NetworkNamespacePath = procNetworkNamespacePath(result.Pid)
+		}
+
+		return result, nil
+	}
+
+	if rawPid, ok := info["pid"]; ok {
+		pid, err := strconv.Atoi(rawPid)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed parsing container pid: '%s'", rawPid)
+		}
+
+		return &ContainerInfo{Pid: pid, NetworkNamespacePath: procNetworkNamespacePath(pid)}, nil
+	}
+
+	return nil, errors.New("container verbose info doesn't contain a pid, unable to identify container runtime version")
+}
+
+func procNetworkNamespacePath(pid int) string {
+	return fmt.Sprintf("/proc/%d/ns/net", pid)
+}
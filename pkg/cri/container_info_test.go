@@ -0,0 +1,99 @@
+package cri
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	CONTAINERD_VERBOSE_INFO = `
+{
+  "sandboxID": "549eb241ba685900fc152501be3c2c31b19e9d649c01f00496b58375e570da52",
+  "pid": 827137,
+  "runtimeSpec": {
+    "linux": {
+      "namespaces": [
+        {"type": "pid"},
+        {"type": "ipc", "path": "/proc/827100/ns/ipc"},
+        {"type": "network", "path": "/var/run/netns/cni-1b0a6c0e-5bc2-2ec5-1c52-3a0f1b5ed0a1"}
+      ]
+    }
+  }
+}
+`
+
+	CRIO_VERBOSE_INFO_NO_NETNS_PATH = `
+{
+  "sandboxID": "549eb241ba685900fc152501be3c2c31b19e9d649c01f00496b58375e570da52",
+  "pid": 69417,
+  "runtimeSpec": {
+    "linux": {
+      "namespaces": [
+        {"type": "network"}
+      ]
+    }
+  }
+}
+`
+
+	VERBOSE_INFO_NO_PID = `
+{
+  "sandboxID": "549eb241ba685900fc152501be3c2c31b19e9d649c01f00496b58375e570da52"
+}
+`
+)
+
+func TestParseContainerInfo_Empty(t *testing.T) {
+	// when
+	result, err := ParseContainerInfo(map[string]string{})
+
+	// then
+	assert.Nil(t, result)
+	assert.NotNil(t, err)
+}
+
+func TestParseContainerInfo_InvalidJson(t *testing.T) {
+	// when
+	result, err := ParseContainerInfo(map[string]string{"info": "{"})
+
+	// then
+	assert.Nil(t, result)
+	assert.NotNil(t, err)
+}
+
+func TestParseContainerInfo_NoPid(t *testing.T) {
+	// when
+	result, err := ParseContainerInfo(map[string]string{"info": VERBOSE_INFO_NO_PID})
+
+	// then
+	assert.Nil(t, result)
+	assert.NotNil(t, err)
+}
+
+func TestParseContainerInfo_Containerd(t *testing.T) {
+	// when
+	result, err := ParseContainerInfo(map[string]string{"info": CONTAINERD_VERBOSE_INFO})
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, &ContainerInfo{Pid: 827137, NetworkNamespacePath: "/var/run/netns/cni-1b0a6c0e-5bc2-2ec5-1c52-3a0f1b5ed0a1"}, result)
+}
+
+func TestParseContainerInfo_NoNetworkNamespacePath(t *testing.T) {
+	// when
+	result, err := ParseContainerInfo(map[string]string{"info": CRIO_VERBOSE_INFO_NO_NETNS_PATH})
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, &ContainerInfo{Pid: 69417, NetworkNamespacePath: "/proc/69417/ns/net"}, result)
+}
+
+func TestParseContainerInfo_Crio117(t *testing.T) {
+	// when
+	result, err := ParseContainerInfo(map[string]string{"pid": "69417"})
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, &ContainerInfo{Pid: 69417, NetworkNamespacePath: "/proc/69417/ns/net"}, result)
+}
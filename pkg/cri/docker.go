@@ -0,0 +1,88 @@
+package cri
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DockerClient is a minimal Docker Engine api client, used for nodes running docker without a CRI socket.
+type DockerClient struct {
+	httpClient *http.Client
+}
+
+type dockerInspection struct {
+	State struct {
+		Pid int `json:"Pid"`
+	} `json:"State"`
+	NetworkSettings struct {
+		SandboxKey string `json:"SandboxKey"`
+	} `json:"NetworkSettings"`
+}
+
+func NewDockerClient(endpoint string, timeout time.Duration) *DockerClient {
+	socketPath := strings.TrimPrefix(endpoint, unixSocketScheme)
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+		},
+	}
+
+	return &DockerClient{httpClient: &http.Client{Transport: transport, Timeout: timeout}}
+}
+
+func (d *DockerClient) InspectContainer(ctx context.Context, containerId string) (*ContainerInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/containers/"+containerId+"/json", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed inspecting container: '%s'", containerId)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed inspecting container: '%s', status: '%d', response: '%s'",
+			containerId, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return ParseDockerInspection(body)
+}
+
+func (d *DockerClient) Close() error {
+	d.httpClient.CloseIdleConnections()
+	return nil
+}
+
+// ParseDockerInspection extracts the container process id and network namespace path from a docker inspect response.
+func ParseDockerInspection(inspection []byte) (*ContainerInfo, error) {
+	var parsed dockerInspection
+	if err := json.Unmarshal(inspection, &parsed); err != nil {
+		return nil, errors.Wrap(err, "failed parsing docker inspection")
+	}
+
+	if parsed.State.Pid <= 0 {
+		return nil, errors.New("container isn't running, docker inspection doesn't contain a pid")
+	}
+
+	result := &ContainerInfo{Pid: parsed.State.Pid, NetworkNamespacePath: parsed.NetworkSettings.SandboxKey}
+	if result.NetworkNamespacePath == "" {
+		result.NetworkNamespacePath = procNetworkNamespacePath(result.Pid)
+	}
+
+	return result, nil
+}
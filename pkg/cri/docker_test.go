@@ -0,0 +1,90 @@
+package cri
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	DOCKER_INSPECT = `
+{
+  "Id": "1c52d2d6e0f0b77ae0e7c1b0a9c3fa3a3e8a3a64f1f8a2a09e0b8f5ea2f7c9e1",
+  "State": {"Status": "running", "Running": true, "Pid": 4242},
+  "NetworkSettings": {"SandboxKey": "/var/run/docker/netns/1f5b4e8a7d21"}
+}
+`
+
+	// podman system service docker compatible api
+	PODMAN_COMPAT_INSPECT = `
+{
+  "Id": "9f3c1b2a7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f2a1b0c9d8e7f6a5b4c3d2e1f0a",
+  "State": {"Status": "running", "Running": true, "Pid": 3187, "ConmonPid": 3175},
+  "NetworkSettings": {"SandboxKey": "/run/netns/netns-3b5f6a0e-9c1d-4f7e-8a2b-6d4c1e0f9a87"}
+}
+`
+
+	// the containers of a cri-dockerd pod join the network of the sandbox container, without a sandbox key
+	CRI_DOCKERD_INSPECT = `
+{
+  "Id": "5e8d2c1b0a9f8e7d6c5b4a3f2e1d0c9b8a7f6e5d4c3b2a1f0e9d8c7b6a5f4e3d",
+  "State": {"Status": "running", "Running": true, "Pid": 5120},
+  "HostConfig": {"NetworkMode": "container:0b4f7c2e9a1d"},
+  "NetworkSettings": {"SandboxKey": ""}
+}
+`
+
+	DOCKER_INSPECT_NOT_RUNNING = `
+{
+  "Id": "1c52d2d6e0f0b77ae0e7c1b0a9c3fa3a3e8a3a64f1f8a2a09e0b8f5ea2f7c9e1",
+  "State": {"Status": "exited", "Running": false, "Pid": 0},
+  "NetworkSettings": {"SandboxKey": ""}
+}
+`
+)
+
+func TestParseDockerInspection_Empty(t *testing.T) {
+	// when
+	result, err := ParseDockerInspection([]byte(""))
+
+	// then
+	assert.Nil(t, result)
+	assert.NotNil(t, err)
+}
+
+func TestParseDockerInspection_NotRunning(t *testing.T) {
+	// when
+	result, err := ParseDockerInspection([]byte(DOCKER_INSPECT_NOT_RUNNING))
+
+	// then
+	assert.Nil(t, result)
+	assert.NotNil(t, err)
+}
+
+func TestParseDockerInspection_Valid(t *testing.T) {
+	// when
+	result, err := ParseDockerInspection([]byte(DOCKER_INSPECT))
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, &ContainerInfo{Pid: 4242, NetworkNamespacePath: "/var/run/docker/netns/1f5b4e8a7d21"}, result)
+}
+
+func TestParseDockerInspection_PodmanCompat(t *testing.T) {
+	// when
+	result, err := ParseDockerInspection([]byte(PODMAN_COMPAT_INSPECT))
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, &ContainerInfo{Pid: 3187,
+		NetworkNamespacePath: "/run/netns/netns-3b5f6a0e-9c1d-4f7e-8a2b-6d4c1e0f9a87"}, result)
+}
+
+func TestParseDockerInspection_CriDockerd(t *testing.T) {
+	// when
+	result, err := ParseDockerInspection([]byte(CRI_DOCKERD_INSPECT))
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, &ContainerInfo{Pid: 5120, NetworkNamespacePath: "/proc/5120/ns/net"}, result)
+}
@@ -0,0 +1,259 @@
+package dns
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ksniff/pkg/flow"
+	"ksniff/pkg/packet"
+	"ksniff/pkg/pcap"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// queryTimeout is the default timeout of the resolvers, the queries not answered within it are logged as
+	// unanswered.
+	queryTimeout = 5 * time.Second
+	// searchPathWindow bounds the delay between the failed search path expansions of a name and its own query.
+	searchPathWindow = 10 * time.Second
+)
+
+// Names resolves the captured addresses to pod names.
+type Names interface {
+	Name(ip string) string
+}
+
+// Entry is a query of the dns log, with its response.
+type Entry struct {
+	Time       time.Time `json:"time"`
+	Client     string    `json:"client"`
+	ClientName string    `json:"clientName,omitempty"`
+	Server     string    `json:"server"`
+	ServerName string    `json:"serverName,omitempty"`
+	ID         uint16    `json:"id"`
+	Name       string    `json:"name"`
+	Type       string    `json:"type"`
+	Answered   bool      `json:"answered"`
+	Rcode      string    `json:"rcode,omitempty"`
+	Answers    []string  `json:"answers,omitempty"`
+	LatencyMs  float64   `json:"latencyMs,omitempty"`
+	// SearchPath lists the search domains appended to the name by the resolver and queried before the name itself,
+	// e.g. because of the 'ndots:5' option of the pods.
+	SearchPath []string `json:"searchPath,omitempty"`
+
+	clientIP string
+}
+
+// String formats the entry as a single line.
+func (e *Entry) String() string {
+	client := e.Client
+	if e.ClientName != "" {
+		client = e.ClientName
+	}
+
+	server := e.Server
+	if e.ServerName != "" {
+		server = e.ServerName
+	}
+
+	line := fmt.Sprintf("%s %s > %s %s %s", e.Time.Format("15:04:05.000"), client, server, e.Type, e.Name)
+	if !e.Answered {
+		return line + " unanswered"
+	}
+
+	line += fmt.Sprintf(" %s %.1fms", e.Rcode, e.LatencyMs)
+	if len(e.Answers) > 0 {
+		line += " " + strings.Join(e.Answers, ",")
+	}
+
+	if len(e.SearchPath) > 0 {
+		line += fmt.Sprintf(" (after %d search path expansions: %s)", len(e.SearchPath), strings.Join(e.SearchPath, ","))
+	}
+
+	return line
+}
+
+type queryKey struct {
+	client flow.Endpoint
+	id     uint16
+	name   string
+	qtype  string
+}
+
+// Tracker matches the captured queries and responses, and emits the log entries once answered or timed out.
+type Tracker struct {
+	names   Names
+	emit    func(*Entry)
+	mutex   sync.Mutex
+	pending map[queryKey]*Entry
+	// failed holds the recent failed queries of each client, the search path expansions of the names queried next
+	failed map[string][]*Entry
+}
+
+func NewTracker(names Names, emit func(*Entry)) *Tracker {
+	return &Tracker{names: names, emit: emit, pending: map[queryKey]*Entry{}, failed: map[string][]*Entry{}}
+}
+
+// Consume reads the capture stream until its end, the pending queries are then emitted as unanswered.
+func (t *Tracker) Consume(r io.Reader) error {
+	defer t.Flush()
+
+	reader, err := pcap.OpenStream(r)
+	if err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+
+	for {
+		captured, err := reader.ReadPacket()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		decoded, err := packet.Decode(captured)
+		if err != nil {
+			log.WithError(err).Debug("packet skipped by the dns log")
+			continue
+		}
+
+		t.Add(decoded)
+	}
+}
+
+func (t *Tracker) name(ip string) string {
+	if t.names == nil {
+		return ""
+	}
+
+	return t.names.Name(ip)
+}
+
+// Add matches the dns message carried by the packet, the other packets are ignored.
+func (t *Tracker) Add(p *packet.Packet) {
+	message, ok := Parse(p)
+	if !ok || message.Name == "" {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.expire(p.Timestamp)
+
+	if !message.Response {
+		client := flow.Endpoint{IP: p.SrcIP.String(), Port: p.SrcPort}
+		server := flow.Endpoint{IP: p.DstIP.String(), Port: p.DstPort}
+		key := queryKey{client: client, id: message.ID, name: message.Name, qtype: message.Type}
+
+		// the retransmitted queries are logged once
+		if _, ok := t.pending[key]; ok {
+			return
+		}
+
+		t.pending[key] = &Entry{Time: p.Timestamp, Client: client.String(), ClientName: t.name(client.IP),
+			Server: server.String(), ServerName: t.name(server.IP), ID: message.ID, Name: message.Name,
+			Type: message.Type, clientIP: client.IP}
+		return
+	}
+
+	key := queryKey{client: flow.Endpoint{IP: p.DstIP.String(), Port: p.DstPort}, id: message.ID, name: message.Name,
+		qtype: message.Type}
+
+	entry, ok := t.pending[key]
+	if !ok {
+		return
+	}
+	delete(t.pending, key)
+
+	entry.Answered = true
+	entry.Rcode = message.Rcode
+	entry.Answers = message.Answers
+	entry.LatencyMs = float64(p.Timestamp.Sub(entry.Time).Microseconds()) / 1000
+
+	t.complete(entry)
+}
+
+// complete collects the search path expansions of the entry, and emits it.
+func (t *Tracker) complete(entry *Entry) {
+	base := strings.TrimSuffix(entry.Name, ".") + "."
+
+	// the expansions are kept, as the A and AAAA queries of the name follow the same search path
+	searchPath := map[string]bool{}
+	for _, previous := range t.failed[entry.clientIP] {
+		if !strings.HasPrefix(previous.Name, base) || previous.Name == entry.Name {
+			continue
+		}
+
+		suffix := strings.TrimSuffix(strings.TrimPrefix(previous.Name, base), ".")
+		if !searchPath[suffix] {
+			searchPath[suffix] = true
+			entry.SearchPath = append(entry.SearchPath, suffix)
+		}
+	}
+
+	if entry.Rcode != RcodeSuccess || len(entry.Answers) == 0 {
+		t.failed[entry.clientIP] = append(t.failed[entry.clientIP], entry)
+	}
+
+	t.emit(entry)
+}
+
+// expire emits the queries unanswered within the timeout, and forgets the old failed queries.
+func (t *Tracker) expire(now time.Time) {
+	var expired []*Entry
+	for key, entry := range t.pending {
+		if now.Sub(entry.Time) > queryTimeout {
+			delete(t.pending, key)
+			expired = append(expired, entry)
+		}
+	}
+	t.emitUnanswered(expired)
+
+	for client, failed := range t.failed {
+		recent := failed[:0]
+		for _, entry := range failed {
+			if now.Sub(entry.Time) <= searchPathWindow {
+				recent = append(recent, entry)
+			}
+		}
+
+		if len(recent) == 0 {
+			delete(t.failed, client)
+		} else {
+			t.failed[client] = recent
+		}
+	}
+}
+
+func (t *Tracker) emitUnanswered(entries []*Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+
+	for _, entry := range entries {
+		t.emit(entry)
+	}
+}
+
+// Flush emits the pending queries as unanswered.
+func (t *Tracker) Flush() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var pending []*Entry
+	for key, entry := range t.pending {
+		delete(t.pending, key)
+		pending = append(pending, entry)
+	}
+	t.emitUnanswered(pending)
+}
@@ -0,0 +1,128 @@
+package dns
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"ksniff/pkg/packet"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+type fakeNames map[string]string
+
+func (f fakeNames) Name(ip string) string {
+	return f[ip]
+}
+
+var start = time.Unix(1700000000, 0)
+
+func buildMessage(t *testing.T, id uint16, name string, qtype dnsmessage.Type, rcode dnsmessage.RCode, answers ...string) []byte {
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, Response: answers != nil || rcode != 0, RCode: rcode})
+	require.NoError(t, builder.StartQuestions())
+	require.NoError(t, builder.Question(dnsmessage.Question{Name: dnsmessage.MustNewName(name), Type: qtype,
+		Class: dnsmessage.ClassINET}))
+
+	require.NoError(t, builder.StartAnswers())
+	for _, answer := range answers {
+		var a [4]byte
+		copy(a[:], net.ParseIP(answer).To4())
+		require.NoError(t, builder.AResource(dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name),
+			Class: dnsmessage.ClassINET, TTL: 30}, dnsmessage.AResource{A: a}))
+	}
+
+	message, err := builder.Finish()
+	require.NoError(t, err)
+
+	return message
+}
+
+func query(milliseconds int, port uint16, payload []byte) *packet.Packet {
+	return &packet.Packet{Timestamp: start.Add(time.Duration(milliseconds) * time.Millisecond),
+		SrcIP: net.ParseIP("10.0.0.1"), SrcPort: port, DstIP: net.ParseIP("10.96.0.10"), DstPort: Port,
+		Protocol: packet.ProtocolUDP, Payload: payload}
+}
+
+func response(milliseconds int, port uint16, payload []byte) *packet.Packet {
+	return &packet.Packet{Timestamp: start.Add(time.Duration(milliseconds) * time.Millisecond),
+		SrcIP: net.ParseIP("10.96.0.10"), SrcPort: Port, DstIP: net.ParseIP("10.0.0.1"), DstPort: port,
+		Protocol: packet.ProtocolUDP, Payload: payload}
+}
+
+func TestTracker_SearchPath(t *testing.T) {
+	// given
+	var entries []*Entry
+	tracker := NewTracker(fakeNames{"10.0.0.1": "default/web-0"}, func(entry *Entry) {
+		entries = append(entries, entry)
+	})
+
+	// when
+	tracker.Add(query(0, 40000, buildMessage(t, 1, "api.example.com.default.svc.cluster.local.", dnsmessage.TypeA, 0)))
+	tracker.Add(response(1, 40000, buildMessage(t, 1, "api.example.com.default.svc.cluster.local.", dnsmessage.TypeA,
+		dnsmessage.RCodeNameError)))
+	tracker.Add(query(2, 40001, buildMessage(t, 2, "api.example.com.svc.cluster.local.", dnsmessage.TypeA, 0)))
+	tracker.Add(response(3, 40001, buildMessage(t, 2, "api.example.com.svc.cluster.local.", dnsmessage.TypeA,
+		dnsmessage.RCodeNameError)))
+	tracker.Add(query(4, 40002, buildMessage(t, 3, "api.example.com.", dnsmessage.TypeA, 0)))
+	tracker.Add(response(16, 40002, buildMessage(t, 3, "api.example.com.", dnsmessage.TypeA, 0, "93.184.216.34")))
+
+	// then
+	assert.Equal(t, 3, len(entries))
+	assert.Equal(t, "NXDOMAIN", entries[0].Rcode)
+	assert.Equal(t, "default/web-0", entries[0].ClientName)
+
+	final := entries[2]
+	assert.Equal(t, "api.example.com.", final.Name)
+	assert.Equal(t, "A", final.Type)
+	assert.Equal(t, RcodeSuccess, final.Rcode)
+	assert.Equal(t, []string{"93.184.216.34"}, final.Answers)
+	assert.Equal(t, 12.0, final.LatencyMs)
+	assert.Equal(t, []string{"default.svc.cluster.local", "svc.cluster.local"}, final.SearchPath)
+	assert.Contains(t, final.String(), "default/web-0 > 10.96.0.10:53 A api.example.com. NOERROR 12.0ms 93.184.216.34 "+
+		"(after 2 search path expansions: default.svc.cluster.local,svc.cluster.local)")
+}
+
+func TestTracker_Unanswered(t *testing.T) {
+	// given
+	var entries []*Entry
+	tracker := NewTracker(nil, func(entry *Entry) {
+		entries = append(entries, entry)
+	})
+
+	// when
+	tracker.Add(query(0, 40000, buildMessage(t, 1, "redis.default.svc.cluster.local.", dnsmessage.TypeA, 0)))
+	// the retransmitted query is logged once
+	tracker.Add(query(1000, 40000, buildMessage(t, 1, "redis.default.svc.cluster.local.", dnsmessage.TypeA, 0)))
+	tracker.Add(query(2000, 40001, buildMessage(t, 2, "redis.default.svc.cluster.local.", dnsmessage.TypeAAAA, 0)))
+	// expires the first query
+	tracker.Add(query(6000, 40002, buildMessage(t, 3, "kubernetes.default.svc.cluster.local.", dnsmessage.TypeA, 0)))
+	expired := len(entries)
+	tracker.Flush()
+
+	// then
+	assert.Equal(t, 1, expired)
+	assert.Equal(t, 3, len(entries))
+	assert.False(t, entries[0].Answered)
+	assert.Equal(t, "AAAA", entries[1].Type)
+	assert.Contains(t, entries[0].String(), "unanswered")
+}
+
+func TestParse_TCP(t *testing.T) {
+	// given
+	message := buildMessage(t, 7, "example.com.", dnsmessage.TypeA, 0, "93.184.216.34")
+	payload := make([]byte, 2+len(message))
+	binary.BigEndian.PutUint16(payload[0:2], uint16(len(message)))
+	copy(payload[2:], message)
+
+	// when
+	parsed, ok := Parse(&packet.Packet{SrcPort: Port, DstPort: 40000, Protocol: packet.ProtocolTCP, Payload: payload})
+
+	// then
+	assert.True(t, ok)
+	assert.Equal(t, &Message{ID: 7, Response: true, Rcode: RcodeSuccess, Name: "example.com.", Type: "A",
+		Answers: []string{"93.184.216.34"}}, parsed)
+}
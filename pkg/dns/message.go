@@ -0,0 +1,130 @@
+// Package dns decodes the captured dns messages, and follows the queries of the pods.
+package dns
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+
+	"ksniff/pkg/packet"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Port is the port of the dns servers, e.g. CoreDNS.
+const Port = 53
+
+// RcodeSuccess is the rcode of the successful responses.
+const RcodeSuccess = "NOERROR"
+
+var rcodeNames = map[dnsmessage.RCode]string{
+	dnsmessage.RCodeSuccess:        RcodeSuccess,
+	dnsmessage.RCodeFormatError:    "FORMERR",
+	dnsmessage.RCodeServerFailure:  "SERVFAIL",
+	dnsmessage.RCodeNameError:      "NXDOMAIN",
+	dnsmessage.RCodeNotImplemented: "NOTIMP",
+	dnsmessage.RCodeRefused:        "REFUSED",
+}
+
+func rcodeName(rcode dnsmessage.RCode) string {
+	if name, ok := rcodeNames[rcode]; ok {
+		return name
+	}
+
+	return strings.TrimPrefix(rcode.String(), "RCode")
+}
+
+func typeName(t dnsmessage.Type) string {
+	return strings.TrimPrefix(t.String(), "Type")
+}
+
+// Message is a decoded dns query or response, of its first question.
+type Message struct {
+	ID       uint16
+	Response bool
+	Rcode    string
+	Name     string
+	Type     string
+	// Answers holds the addresses of the A and AAAA records, and the type and value of the other records,
+	// e.g. 'CNAME example.com.'.
+	Answers []string
+}
+
+// Parse decodes the dns message carried by the packet. The tcp messages are only decoded when a segment holds a
+// whole message, which is usually the case.
+func Parse(p *packet.Packet) (*Message, bool) {
+	if p.SrcPort != Port && p.DstPort != Port {
+		return nil, false
+	}
+
+	payload := p.Payload
+	switch p.Protocol {
+	case packet.ProtocolUDP:
+	case packet.ProtocolTCP:
+		if len(payload) < 2 || int(binary.BigEndian.Uint16(payload[0:2])) != len(payload)-2 {
+			return nil, false
+		}
+		payload = payload[2:]
+	default:
+		return nil, false
+	}
+
+	var parser dnsmessage.Parser
+	header, err := parser.Start(payload)
+	if err != nil {
+		return nil, false
+	}
+
+	message := &Message{ID: header.ID, Response: header.Response, Rcode: rcodeName(header.RCode)}
+
+	question, err := parser.Question()
+	if err != nil {
+		return message, true
+	}
+
+	message.Name = question.Name.String()
+	message.Type = typeName(question.Type)
+
+	if !header.Response || parser.SkipAllQuestions() != nil {
+		return message, true
+	}
+
+	for {
+		answer, err := parser.AnswerHeader()
+		if err != nil {
+			break
+		}
+
+		value, err := parseAnswer(&parser, answer)
+		if err != nil {
+			break
+		}
+
+		message.Answers = append(message.Answers, value)
+	}
+
+	return message, true
+}
+
+func parseAnswer(parser *dnsmessage.Parser, header dnsmessage.ResourceHeader) (string, error) {
+	switch header.Type {
+	case dnsmessage.TypeA:
+		resource, err := parser.AResource()
+		return net.IP(resource.A[:]).String(), err
+	case dnsmessage.TypeAAAA:
+		resource, err := parser.AAAAResource()
+		return net.IP(resource.AAAA[:]).String(), err
+	case dnsmessage.TypeCNAME:
+		resource, err := parser.CNAMEResource()
+		return "CNAME " + resource.CNAME.String(), err
+	case dnsmessage.TypeSRV:
+		resource, err := parser.SRVResource()
+		return fmt.Sprintf("SRV %s:%d", resource.Target, resource.Port), err
+	case dnsmessage.TypePTR:
+		resource, err := parser.PTRResource()
+		return "PTR " + resource.PTR.String(), err
+	default:
+		return typeName(header.Type), parser.SkipAnswer()
+	}
+}
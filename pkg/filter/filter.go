@@ -0,0 +1,80 @@
+// Package filter validates pcap capture filters locally, before tcpdump is started on the remote container.
+//
+// The parser supports the commonly used subset of the pcap-filter(7) syntax: primitives with protocol, direction
+// and type qualifiers (e.g. 'tcp src port 80', 'net 10.0.0.0/8'), the boolean operators and relations over
+// packet data (e.g. 'tcp[tcpflags] & tcp-syn != 0'). Filters using unknown constructs are rejected, the
+// validation can be skipped when a valid filter isn't supported by the parser.
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LinkType is the link-layer header type of the captured packets, some primitives depend on it.
+type LinkType int
+
+const (
+	// LinkTypeEthernet is used when capturing on a specific network interface.
+	LinkTypeEthernet LinkType = iota
+	// LinkTypeLinuxSLL is the linux "cooked" header used when capturing on the 'any' interface.
+	LinkTypeLinuxSLL
+)
+
+func (l LinkType) String() string {
+	if l == LinkTypeLinuxSLL {
+		return "linux cooked capture"
+	}
+
+	return "ethernet"
+}
+
+// LinkTypeForInterface returns the link type tcpdump uses when capturing on the given interface.
+func LinkTypeForInterface(netInterface string) LinkType {
+	if netInterface == "any" {
+		return LinkTypeLinuxSLL
+	}
+
+	return LinkTypeEthernet
+}
+
+// SyntaxError describes an invalid capture filter and the position of the error.
+type SyntaxError struct {
+	Filter string
+	// Position is the 0-based offset of the error in the filter.
+	Position int
+	Message  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid capture filter at position %d: %s", e.Position+1, e.Message)
+}
+
+// Pointer returns the filter and a caret under the error position.
+func (e *SyntaxError) Pointer() string {
+	return e.Filter + "\n" + strings.Repeat(" ", e.Position) + "^"
+}
+
+// Validate parses the capture filter and verifies it can be compiled for the given link type,
+// an empty filter is valid and captures all packets.
+func Validate(expression string, linkType LinkType) error {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return err
+	}
+
+	p := &parser{expression: expression, tokens: tokens, linkType: linkType}
+	if p.peek().kind == tokenEOF {
+		return nil
+	}
+
+	if err := p.parseOr(); err != nil {
+		return err
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return p.errorAt(t, fmt.Sprintf("unexpected '%s', expected 'and' or 'or'", t.value))
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate_ValidFilters(t *testing.T) {
+	filters := []string{
+		"",
+		"port 80",
+		"tcp port 80 or 443",
+		"host 10.0.0.1 and not port 22",
+		"src or dst host backend.default.svc.cluster.local",
+		"net 10.0.0.0/8 or net 172.16 mask 255.240.0.0",
+		"ip6 and dst net fe80::/10",
+		"udp portrange 30000-32767",
+		"tcp[tcpflags] & (tcp-syn|tcp-ack) != 0",
+		"icmp[icmptype] == icmp-echo",
+		"tcp port 80 and (((ip[2:2] - ((ip[0]&0xf)<<2)) - ((tcp[12]&0xf0)>>2)) != 0)",
+		"(port 53 || port 5353) && !tcp",
+		"ip proto 47 or arp",
+		"ether proto 0x0806",
+		"ip proto \\tcp or ip6 proto \\udp",
+		"ether proto \\arp and not ether proto \\ip",
+		"vlan 100 and tcp",
+		"less 128 or len >= 1500",
+		"ip broadcast or ip6 multicast",
+		"TCP PORT 80",
+	}
+
+	for _, expression := range filters {
+		t.Run(expression, func(t *testing.T) {
+			// when
+			err := Validate(expression, LinkTypeLinuxSLL)
+
+			// then
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestValidate_InvalidFilters(t *testing.T) {
+	testCases := []struct {
+		expression string
+		position   int
+		message    string
+	}{
+		{"port", 4, "expected a port number or name, found end of filter"},
+		{"port 99999", 5, "port 99999 is out of range"},
+		{"host 10.0.0.1 prot 80", 14, "unexpected 'prot', expected 'and' or 'or'"},
+		{"tcp host 10.0.0.1", 0, "'tcp' modifier applied to host"},
+		{"(port 80", 8, "expected ')'"},
+		{"tcp[13] & 2 !=", 14, "expected an arithmetic expression"},
+		{"tcp[13:3] != 0", 7, "expected a data size of 1, 2 or 4"},
+		{"net 10.0.0.1/8", 4, "non-network bits set in '10.0.0.1/8'"},
+		{"port 80 and", 11, "found end of filter"},
+		{"port 80 $ 1", 8, "unexpected character '$'"},
+		{"ip proto \\ and tcp", 9, "expected a name after '\\'"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expression, func(t *testing.T) {
+			// when
+			err := Validate(tc.expression, LinkTypeEthernet)
+
+			// then
+			syntaxErr, ok := err.(*SyntaxError)
+			if assert.True(t, ok, "expected a syntax error, actual: %v", err) {
+				assert.Equal(t, tc.position, syntaxErr.Position)
+				assert.Contains(t, syntaxErr.Message, tc.message)
+			}
+		})
+	}
+}
+
+func TestValidate_LinkLayerPrimitivesOnLinuxCookedCapture(t *testing.T) {
+	for _, expression := range []string{"ether host aa:bb:cc:dd:ee:ff", "broadcast", "port 80 and ether src aa:bb:cc:dd:ee:ff"} {
+		t.Run(expression, func(t *testing.T) {
+			// when
+			ethernetErr := Validate(expression, LinkTypeEthernet)
+			sllErr := Validate(expression, LinkTypeLinuxSLL)
+
+			// then
+			assert.Nil(t, ethernetErr)
+			assert.IsType(t, &SyntaxError{}, sllErr)
+			assert.Contains(t, sllErr.Error(), "interface 'any'")
+		})
+	}
+}
+
+func TestSyntaxError_Pointer(t *testing.T) {
+	// given
+	err := &SyntaxError{Filter: "port 99999", Position: 5, Message: "port 99999 is out of range (0-65535)"}
+
+	// then
+	assert.Equal(t, "invalid capture filter at position 6: port 99999 is out of range (0-65535)", err.Error())
+	assert.Equal(t, "port 99999\n     ^", err.Pointer())
+}
+
+func TestLinkTypeForInterface(t *testing.T) {
+	assert.Equal(t, LinkTypeLinuxSLL, LinkTypeForInterface("any"))
+	assert.Equal(t, LinkTypeEthernet, LinkTypeForInterface("eth0"))
+}
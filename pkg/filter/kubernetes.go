@@ -0,0 +1,253 @@
+package filter
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ReferenceKind is the kind of kubernetes object referenced by a kubernetes-aware filter.
+type ReferenceKind string
+
+const (
+	PodReference       ReferenceKind = "pod"
+	ServiceReference   ReferenceKind = "svc"
+	NamespaceReference ReferenceKind = "ns"
+	LabelReference     ReferenceKind = "label"
+	PortReference      ReferenceKind = "port"
+)
+
+var referenceKindAliases = map[string]ReferenceKind{
+	"pod": PodReference, "svc": ServiceReference, "service": ServiceReference, "ns": NamespaceReference,
+	"namespace": NamespaceReference, "label": LabelReference, "port": PortReference,
+}
+
+// referencePattern matches the references and their optional protocol and direction qualifiers,
+// e.g. 'tcp dst port:http', 'src pod:billing/checkout-abc' or 'src or dst svc:payments'.
+var referencePattern = regexp.MustCompile(
+	`(?i)(?:\b(ip6?|tcp|udp|sctp)\s+)?(?:\b((?:src|dst)\s+(?:or|and)\s+(?:src|dst)|src|dst)\s+)?` +
+		`\b(pod|svc|service|ns|namespace|label|port):([^\s()]+)`)
+
+// Reference is a kubernetes object referenced by a filter, e.g. 'svc:payments' or 'label:app=redis'.
+type Reference struct {
+	Kind ReferenceKind
+	// Namespace is empty when the reference doesn't specify one (e.g. 'pod:name' rather than 'pod:namespace/name'),
+	// the target pod namespace is used. Label selectors always apply to the target pod namespace, as label keys
+	// may contain a '/'.
+	Namespace string
+	// Name is the object name, the label selector of label references or the port name of port references.
+	Name string
+
+	protocol  string
+	direction string
+	start     int
+	end       int
+}
+
+func (r Reference) String() string {
+	if r.Namespace != "" && r.Kind != NamespaceReference {
+		return fmt.Sprintf("%s:%s/%s", r.Kind, r.Namespace, r.Name)
+	}
+
+	return fmt.Sprintf("%s:%s", r.Kind, r.Name)
+}
+
+// Resolver resolves the kubernetes objects referenced by a filter using the live api.
+type Resolver interface {
+	PodIPs(namespace string, name string) ([]string, error)
+
+	// ServiceIPs returns the cluster ips of the service and the ips of its endpoints.
+	ServiceIPs(namespace string, name string) ([]string, error)
+
+	NamespaceIPs(namespace string) ([]string, error)
+
+	LabelSelectorIPs(namespace string, selector string) ([]string, error)
+
+	// NamedPorts returns the port numbers of the container ports (or service ports) with the given name.
+	NamedPorts(name string) ([]int, error)
+}
+
+// FindReferences returns the kubernetes references of the filter by order of appearance.
+func FindReferences(expression string) []Reference {
+	var references []Reference
+
+	for _, match := range referencePattern.FindAllStringSubmatchIndex(expression, -1) {
+		reference := Reference{
+			Kind:  referenceKindAliases[strings.ToLower(expression[match[6]:match[7]])],
+			Name:  expression[match[8]:match[9]],
+			start: match[0],
+			end:   match[1],
+		}
+
+		if match[2] >= 0 {
+			reference.protocol = strings.ToLower(expression[match[2]:match[3]])
+		}
+
+		if match[4] >= 0 {
+			reference.direction = strings.Join(strings.Fields(strings.ToLower(expression[match[4]:match[5]])), " ")
+		}
+
+		if reference.Kind == NamespaceReference {
+			reference.Namespace = reference.Name
+		} else if reference.Kind == PodReference || reference.Kind == ServiceReference {
+			if parts := strings.SplitN(reference.Name, "/", 2); len(parts) == 2 {
+				reference.Namespace, reference.Name = parts[0], parts[1]
+			}
+		}
+
+		references = append(references, reference)
+	}
+
+	return references
+}
+
+// HasReferences reports whether the filter uses kubernetes references.
+func HasReferences(expression string) bool {
+	return referencePattern.MatchString(expression)
+}
+
+// IsRefreshable reports whether the filter references objects whose ips change, e.g. on rollouts.
+func IsRefreshable(expression string) bool {
+	for _, reference := range FindReferences(expression) {
+		if reference.Kind != PortReference {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Expand replaces the kubernetes references of the filter with the matching 'host' and 'port' primitives,
+// e.g. 'svc:payments and port:http' becomes '(host 10.96.0.10 or host 10.0.1.7) and port 8080'.
+func Expand(expression string, resolver Resolver) (string, error) {
+	var expanded strings.Builder
+	position := 0
+
+	for _, reference := range FindReferences(expression) {
+		primitives, err := resolve(reference, resolver)
+		if err != nil {
+			return "", err
+		}
+
+		expanded.WriteString(expression[position:reference.start])
+		expanded.WriteString(primitives)
+		position = reference.end
+	}
+
+	expanded.WriteString(expression[position:])
+
+	return expanded.String(), nil
+}
+
+func resolve(reference Reference, resolver Resolver) (string, error) {
+	if reference.Name == "" {
+		return "", errors.Errorf("empty filter reference: '%s'", reference)
+	}
+
+	var ips []string
+	var err error
+
+	switch reference.Kind {
+	case PodReference:
+		ips, err = resolver.PodIPs(reference.Namespace, reference.Name)
+	case ServiceReference:
+		ips, err = resolver.ServiceIPs(reference.Namespace, reference.Name)
+	case NamespaceReference:
+		ips, err = resolver.NamespaceIPs(reference.Namespace)
+	case LabelReference:
+		ips, err = resolver.LabelSelectorIPs(reference.Namespace, reference.Name)
+	case PortReference:
+		return resolvePorts(reference, resolver)
+	}
+
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to resolve filter reference: '%s'", reference)
+	}
+
+	if len(ips) == 0 {
+		return "", errors.Errorf("filter reference: '%s' has no ip address", reference)
+	}
+
+	if reference.protocol == "ip" || reference.protocol == "ip6" {
+		ips = filterFamily(ips, reference.protocol == "ip6")
+		if len(ips) == 0 {
+			return "", errors.Errorf("filter reference: '%s' has no %s address", reference, reference.protocol)
+		}
+	}
+
+	switch reference.protocol {
+	case "", "ip", "ip6":
+		return joinPrimitives(reference.protocol+" "+reference.direction, "host", ips), nil
+	}
+
+	// transport protocols can't qualify hosts, e.g. 'tcp pod:name' becomes '(tcp and host 10.0.1.7)'
+	return fmt.Sprintf("(%s and %s)", reference.protocol, joinPrimitives(reference.direction, "host", ips)), nil
+}
+
+// filterFamily keeps the IPv6 addresses when ipv6 is set, otherwise the IPv4 addresses.
+func filterFamily(ips []string, ipv6 bool) []string {
+	filtered := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		parsed := net.ParseIP(ip)
+		if parsed != nil && (parsed.To4() == nil) == ipv6 {
+			filtered = append(filtered, ip)
+		}
+	}
+
+	return filtered
+}
+
+func resolvePorts(reference Reference, resolver Resolver) (string, error) {
+	// numeric ports are accepted for consistency, e.g. 'port:8080'
+	if _, err := strconv.Atoi(reference.Name); err == nil {
+		return joinPrimitives(reference.protocol+" "+reference.direction, "port", []string{reference.Name}), nil
+	}
+
+	ports, err := resolver.NamedPorts(reference.Name)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to resolve filter reference: '%s'", reference)
+	}
+
+	if len(ports) == 0 {
+		return "", errors.Errorf("filter reference: '%s' doesn't match any named container or service port", reference)
+	}
+
+	values := make([]string, 0, len(ports))
+	for _, port := range ports {
+		values = append(values, strconv.Itoa(port))
+	}
+
+	return joinPrimitives(reference.protocol+" "+reference.direction, "port", values), nil
+}
+
+// joinPrimitives builds a primitive per unique value, grouped using 'or' when there are multiple values.
+func joinPrimitives(qualifiers string, kind string, values []string) string {
+	unique := map[string]bool{}
+	for _, value := range values {
+		unique[value] = true
+	}
+
+	sorted := make([]string, 0, len(unique))
+	for value := range unique {
+		sorted = append(sorted, value)
+	}
+	sort.Strings(sorted)
+
+	prefix := strings.Join(append(strings.Fields(qualifiers), kind), " ")
+
+	primitives := make([]string, 0, len(sorted))
+	for _, value := range sorted {
+		primitives = append(primitives, prefix+" "+value)
+	}
+
+	if len(primitives) == 1 {
+		return primitives[0]
+	}
+
+	return "(" + strings.Join(primitives, " or ") + ")"
+}
@@ -0,0 +1,146 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeResolver struct {
+	ips   map[string][]string
+	ports map[string][]int
+}
+
+func (f *fakeResolver) lookup(key string) ([]string, error) {
+	ips, ok := f.ips[key]
+	if !ok {
+		return nil, errors.Errorf("'%s' not found", key)
+	}
+
+	return ips, nil
+}
+
+func (f *fakeResolver) PodIPs(namespace string, name string) ([]string, error) {
+	return f.lookup("pod:" + namespace + "/" + name)
+}
+
+func (f *fakeResolver) ServiceIPs(namespace string, name string) ([]string, error) {
+	return f.lookup("svc:" + namespace + "/" + name)
+}
+
+func (f *fakeResolver) NamespaceIPs(namespace string) ([]string, error) {
+	return f.lookup("ns:" + namespace)
+}
+
+func (f *fakeResolver) LabelSelectorIPs(namespace string, selector string) ([]string, error) {
+	return f.lookup("label:" + namespace + "/" + selector)
+}
+
+func (f *fakeResolver) NamedPorts(name string) ([]int, error) {
+	return f.ports[name], nil
+}
+
+var resolver = &fakeResolver{
+	ips: map[string][]string{
+		"pod:/checkout-abc":       {"10.0.1.7"},
+		"pod:billing/invoice-xyz": {"10.0.2.3"},
+		"svc:/payments":           {"10.96.0.10", "10.0.1.8", "10.0.1.9", "10.0.1.8"},
+		"ns:billing":              {"10.0.2.3", "10.0.2.4"},
+		"label:/app=redis":        {"10.0.3.1"},
+		"pod:/pending":            {},
+		"pod:/dual-stack":         {"10.0.4.2", "fd00::4:2"},
+	},
+	ports: map[string][]int{"http": {8080}, "grpc": {9090, 9091}},
+}
+
+func TestFindReferences(t *testing.T) {
+	// when
+	references := FindReferences("tcp src pod:billing/invoice-xyz and not label:app.kubernetes.io/name=redis or port:http")
+
+	// then
+	assert.Len(t, references, 3)
+	assert.Equal(t, PodReference, references[0].Kind)
+	assert.Equal(t, "billing", references[0].Namespace)
+	assert.Equal(t, "invoice-xyz", references[0].Name)
+	assert.Equal(t, LabelReference, references[1].Kind)
+	assert.Equal(t, "", references[1].Namespace)
+	assert.Equal(t, "app.kubernetes.io/name=redis", references[1].Name)
+	assert.Equal(t, PortReference, references[2].Kind)
+	assert.Equal(t, "http", references[2].Name)
+}
+
+func TestExpand(t *testing.T) {
+	testCases := []struct {
+		expression string
+		expected   string
+	}{
+		{"port 80", "port 80"},
+		{"pod:checkout-abc", "host 10.0.1.7"},
+		{"src pod:billing/invoice-xyz", "src host 10.0.2.3"},
+		{"svc:payments and port:http", "(host 10.0.1.8 or host 10.0.1.9 or host 10.96.0.10) and port 8080"},
+		{"ns:billing or label:app=redis", "(host 10.0.2.3 or host 10.0.2.4) or host 10.0.3.1"},
+		{"tcp dst port:grpc", "(tcp dst port 9090 or tcp dst port 9091)"},
+		{"tcp pod:checkout-abc", "(tcp and host 10.0.1.7)"},
+		{"src or dst pod:checkout-abc", "src or dst host 10.0.1.7"},
+		{"tcp src  and dst svc:payments", "(tcp and (src and dst host 10.0.1.8 or src and dst host 10.0.1.9 or src and dst host 10.96.0.10))"},
+		{"src or dst port:grpc", "(src or dst port 9090 or src or dst port 9091)"},
+		{"ip pod:dual-stack", "ip host 10.0.4.2"},
+		{"ip6 pod:dual-stack", "ip6 host fd00::4:2"},
+		{"pod:dual-stack", "(host 10.0.4.2 or host fd00::4:2)"},
+		{"not (pod:checkout-abc)", "not (host 10.0.1.7)"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expression, func(t *testing.T) {
+			// when
+			expanded, err := Expand(tc.expression, resolver)
+
+			// then
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, expanded)
+			assert.Nil(t, Validate(expanded, LinkTypeLinuxSLL))
+		})
+	}
+}
+
+func TestExpand_Errors(t *testing.T) {
+	testCases := []struct {
+		expression string
+		message    string
+	}{
+		{"pod:missing", "failed to resolve filter reference: 'pod:missing'"},
+		{"pod:pending", "filter reference: 'pod:pending' has no ip address"},
+		{"ip6 pod:checkout-abc", "filter reference: 'pod:checkout-abc' has no ip6 address"},
+		{"port:metrics", "doesn't match any named container or service port"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expression, func(t *testing.T) {
+			// when
+			_, err := Expand(tc.expression, resolver)
+
+			// then
+			assert.NotNil(t, err)
+			assert.Contains(t, err.Error(), tc.message)
+		})
+	}
+}
+
+func TestIsRefreshable(t *testing.T) {
+	// given
+	tests := map[string]bool{
+		"port:http":                 false,
+		"tcp port:http and svc:api": true,
+		"label:app=redis":           true,
+		"host 10.0.0.1":             false,
+	}
+
+	for expression, expected := range tests {
+		// when
+		refreshable := IsRefreshable(expression)
+
+		// then
+		assert.Equal(t, expected, refreshable, expression)
+	}
+}
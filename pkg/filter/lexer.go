@@ -0,0 +1,104 @@
+package filter
+
+import (
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdentifier
+	tokenOperator
+)
+
+type token struct {
+	kind     tokenKind
+	value    string
+	position int
+	// escaped is set for the names escaped by a backslash, e.g. '\tcp' in 'ip proto \tcp', which are never keywords.
+	escaped bool
+}
+
+// operators by descending length, so the longest operator is matched first.
+var operators = []string{"&&", "||", "<<", ">>", "==", "!=", "<=", ">=",
+	"(", ")", "[", "]", "!", "&", "|", "^", "+", "-", "*", "/", "%", "=", "<", ">", ":"}
+
+func isIdentifierChar(c byte, bracketDepth int) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	case c == '.' || c == '_' || c == '-':
+		return true
+	case c == ':' || c == '/':
+		// ipv6 and mac addresses or cidr notation, a slice or a division inside brackets
+		return bracketDepth == 0
+	}
+
+	return false
+}
+
+// tokenize splits a capture filter into identifiers (keywords, numbers, addresses, names) and operators.
+func tokenize(expression string) ([]token, error) {
+	var tokens []token
+	bracketDepth := 0
+
+	for i := 0; i < len(expression); {
+		c := expression[i]
+
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			i++
+			continue
+		}
+
+		// like libpcap, a backslash escapes the name following it up to a space, '!' or a parenthesis
+		if c == '\\' {
+			start := i
+			i++
+			for i < len(expression) && !strings.ContainsRune(" \t\n\r!()", rune(expression[i])) {
+				i++
+			}
+
+			if i == start+1 {
+				return nil, &SyntaxError{Filter: expression, Position: start, Message: "expected a name after '\\'"}
+			}
+
+			tokens = append(tokens, token{kind: tokenIdentifier, value: expression[start+1 : i], position: start, escaped: true})
+			continue
+		}
+
+		// a leading '-' is an operator, e.g. the negation in '- 1'
+		if isIdentifierChar(c, bracketDepth) && c != '-' && c != ':' && c != '/' || c == ':' && i+1 < len(expression) && expression[i+1] == ':' {
+			start := i
+			for i < len(expression) && isIdentifierChar(expression[i], bracketDepth) {
+				i++
+			}
+
+			tokens = append(tokens, token{kind: tokenIdentifier, value: expression[start:i], position: start})
+			continue
+		}
+
+		matched := false
+		for _, operator := range operators {
+			if strings.HasPrefix(expression[i:], operator) {
+				switch operator {
+				case "[":
+					bracketDepth++
+				case "]":
+					bracketDepth--
+				}
+
+				tokens = append(tokens, token{kind: tokenOperator, value: operator, position: i})
+				i += len(operator)
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return nil, &SyntaxError{Filter: expression, Position: i, Message: "unexpected character '" + string(c) + "'"}
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, position: len(expression)}), nil
+}
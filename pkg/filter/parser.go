@@ -0,0 +1,639 @@
+package filter
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var protocolQualifiers = map[string]bool{
+	"ether": true, "fddi": true, "tr": true, "wlan": true, "ip": true, "ip6": true, "arp": true, "rarp": true,
+	"decnet": true, "tcp": true, "udp": true, "sctp": true, "icmp": true, "icmp6": true, "igmp": true, "igrp": true,
+	"pim": true, "vrrp": true, "carp": true, "ah": true, "esp": true, "atalk": true, "aarp": true, "iso": true,
+	"stp": true, "ipx": true, "netbeui": true, "vlan": true, "mpls": true, "pppoed": true, "pppoes": true, "geneve": true,
+}
+
+// linkLayerQualifiers can't be used on the linux cooked capture, which has no link-layer addresses.
+var linkLayerQualifiers = map[string]bool{"ether": true, "fddi": true, "tr": true, "wlan": true}
+
+var directionQualifiers = map[string]bool{"src": true, "dst": true}
+
+var typeQualifiers = map[string]bool{"host": true, "net": true, "port": true, "portrange": true, "gateway": true}
+
+// typeProtocols lists the protocol qualifiers allowed with each type qualifier.
+var typeProtocols = map[string]map[string]bool{
+	"host":      {"ip": true, "ip6": true, "arp": true, "rarp": true, "ether": true, "fddi": true, "tr": true, "wlan": true, "decnet": true},
+	"net":       {"ip": true, "ip6": true, "arp": true, "rarp": true},
+	"port":      {"ip": true, "ip6": true, "tcp": true, "udp": true, "sctp": true},
+	"portrange": {"ip": true, "ip6": true, "tcp": true, "udp": true, "sctp": true},
+	"gateway":   {},
+}
+
+// arithmeticProtocols can be indexed to access packet data, e.g. 'tcp[13]'.
+var arithmeticProtocols = map[string]bool{
+	"ether": true, "fddi": true, "tr": true, "wlan": true, "ppp": true, "slip": true, "link": true, "radio": true,
+	"ip": true, "ip6": true, "arp": true, "rarp": true, "tcp": true, "udp": true, "sctp": true, "icmp": true,
+	"icmp6": true, "igmp": true, "igrp": true, "pim": true, "vrrp": true, "carp": true,
+}
+
+var arithmeticConstants = map[string]bool{
+	"len": true, "tcpflags": true, "tcp-fin": true, "tcp-syn": true, "tcp-rst": true, "tcp-push": true,
+	"tcp-ack": true, "tcp-urg": true, "tcp-ece": true, "tcp-cwr": true, "icmptype": true, "icmpcode": true,
+	"icmp-echoreply": true, "icmp-unreach": true, "icmp-sourcequench": true, "icmp-redirect": true, "icmp-echo": true,
+	"icmp-routeradvert": true, "icmp-routersolicit": true, "icmp-timxceed": true, "icmp-paramprob": true,
+	"icmp-tstamp": true, "icmp-tstampreply": true, "icmp-ireq": true, "icmp-ireqreply": true, "icmp-maskreq": true,
+	"icmp-maskreply": true, "icmp6type": true, "icmp6code": true, "icmp6-echo": true, "icmp6-echoreply": true,
+}
+
+var arithmeticOperators = map[string]bool{"+": true, "-": true, "*": true, "/": true, "%": true, "&": true, "|": true,
+	"^": true, "<<": true, ">>": true}
+
+var relationalOperators = map[string]bool{"=": true, "==": true, "!=": true, "<": true, ">": true, "<=": true, ">=": true}
+
+var (
+	serviceNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+	hostNamePattern    = regexp.MustCompile(`^[A-Za-z0-9_]([A-Za-z0-9_.-]*[A-Za-z0-9_])?$`)
+	partialNetPattern  = regexp.MustCompile(`^[0-9]{1,3}(\.[0-9]{1,3}){0,2}$`)
+)
+
+// qualifiers of a primitive, e.g. 'tcp src port' in 'tcp src port 80'.
+type qualifiers struct {
+	protocol  string
+	direction string
+	kind      string
+}
+
+type parser struct {
+	expression string
+	tokens     []token
+	position   int
+	linkType   LinkType
+	// previous qualifiers are reused by ids without qualifiers, e.g. '443' in 'port 80 or 443'.
+	previous *qualifiers
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.position]
+}
+
+func (p *parser) peekAt(offset int) token {
+	if p.position+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+
+	return p.tokens[p.position+offset]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.position]
+	if t.kind != tokenEOF {
+		p.position++
+	}
+
+	return t
+}
+
+func (p *parser) errorAt(t token, message string) *SyntaxError {
+	return &SyntaxError{Filter: p.expression, Position: t.position, Message: message}
+}
+
+func (p *parser) expectOperator(operator string) error {
+	t := p.peek()
+	if t.kind != tokenOperator || t.value != operator {
+		return p.errorAt(t, fmt.Sprintf("expected '%s', found %s", operator, describe(t)))
+	}
+
+	p.next()
+
+	return nil
+}
+
+func describe(t token) string {
+	if t.kind == tokenEOF {
+		return "end of filter"
+	}
+
+	return fmt.Sprintf("'%s'", t.value)
+}
+
+func isKeyword(t token, keywords ...string) bool {
+	if t.kind != tokenIdentifier || t.escaped {
+		return false
+	}
+
+	for _, keyword := range keywords {
+		if strings.EqualFold(t.value, keyword) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isOperator(t token, operators ...string) bool {
+	if t.kind != tokenOperator {
+		return false
+	}
+
+	for _, operator := range operators {
+		if t.value == operator {
+			return true
+		}
+	}
+
+	return false
+}
+
+// keyword returns the lower-cased identifier, or an empty string for the escaped names which are never keywords.
+func keyword(t token) string {
+	if t.escaped {
+		return ""
+	}
+
+	return strings.ToLower(t.value)
+}
+
+func (p *parser) parseOr() error {
+	if err := p.parseAnd(); err != nil {
+		return err
+	}
+
+	for isKeyword(p.peek(), "or") || isOperator(p.peek(), "||") {
+		p.next()
+
+		if err := p.parseAnd(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *parser) parseAnd() error {
+	if err := p.parseNot(); err != nil {
+		return err
+	}
+
+	for isKeyword(p.peek(), "and") || isOperator(p.peek(), "&&") {
+		p.next()
+
+		if err := p.parseNot(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *parser) parseNot() error {
+	if isKeyword(p.peek(), "not") || isOperator(p.peek(), "!") {
+		p.next()
+		return p.parseNot()
+	}
+
+	return p.parsePrimary()
+}
+
+// furthest returns the error found furthest in the filter, which is the most relevant when alternatives failed.
+func furthest(first error, second error) error {
+	firstErr, ok := first.(*SyntaxError)
+	if !ok {
+		return first
+	}
+
+	secondErr, ok := second.(*SyntaxError)
+	if !ok || firstErr.Position >= secondErr.Position {
+		return first
+	}
+
+	return second
+}
+
+// tryRelation parses a relation, restoring the parser position when the tokens aren't a valid relation.
+func (p *parser) tryRelation() error {
+	start, previous := p.position, p.previous
+
+	err := p.parseRelation()
+	if err != nil {
+		p.position, p.previous = start, previous
+	}
+
+	return err
+}
+
+func (p *parser) startsArithmetic() bool {
+	t := p.peek()
+
+	switch {
+	case isOperator(t, "-"):
+		return true
+	case t.kind != tokenIdentifier:
+		return false
+	case isNumber(t.value), arithmeticConstants[keyword(t)]:
+		return true
+	}
+
+	return arithmeticProtocols[keyword(t)] && isOperator(p.peekAt(1), "[")
+}
+
+func (p *parser) parsePrimary() error {
+	t := p.peek()
+
+	// a parenthesis starts either a group of primitives or an arithmetic expression
+	if isOperator(t, "(") {
+		relationErr := p.tryRelation()
+		if relationErr == nil {
+			return nil
+		}
+
+		p.next()
+
+		groupErr := p.parseOr()
+		if groupErr == nil {
+			groupErr = p.expectOperator(")")
+		}
+
+		if groupErr != nil {
+			return furthest(groupErr, relationErr)
+		}
+
+		return nil
+	}
+
+	if p.startsArithmetic() {
+		relationErr := p.tryRelation()
+		if relationErr == nil {
+			return nil
+		}
+
+		// a number alone is an id using the previous qualifiers, e.g. '443' in 'port 80 or 443'
+		if !isNumber(t.value) || isOperator(p.peekAt(1), "[") {
+			return relationErr
+		}
+
+		if primitiveErr := p.parsePrimitive(); primitiveErr != nil {
+			return furthest(primitiveErr, relationErr)
+		}
+
+		return nil
+	}
+
+	return p.parsePrimitive()
+}
+
+func (p *parser) parseRelation() error {
+	if err := p.parseArithmetic(); err != nil {
+		return err
+	}
+
+	t := p.peek()
+	if t.kind != tokenOperator || !relationalOperators[t.value] {
+		return p.errorAt(t, fmt.Sprintf("expected a relational operator, found %s", describe(t)))
+	}
+
+	p.next()
+
+	return p.parseArithmetic()
+}
+
+func (p *parser) parseArithmetic() error {
+	if err := p.parseArithmeticTerm(); err != nil {
+		return err
+	}
+
+	for p.peek().kind == tokenOperator && arithmeticOperators[p.peek().value] {
+		p.next()
+
+		if err := p.parseArithmeticTerm(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *parser) parseArithmeticTerm() error {
+	t := p.peek()
+
+	switch {
+	case isOperator(t, "("):
+		p.next()
+		if err := p.parseArithmetic(); err != nil {
+			return err
+		}
+		return p.expectOperator(")")
+
+	case isOperator(t, "-"):
+		p.next()
+		return p.parseArithmeticTerm()
+
+	case t.kind == tokenIdentifier && isNumber(t.value):
+		p.next()
+		return nil
+
+	case t.kind == tokenIdentifier && arithmeticConstants[keyword(t)]:
+		p.next()
+		return nil
+
+	case t.kind == tokenIdentifier && arithmeticProtocols[keyword(t)] && isOperator(p.peekAt(1), "["):
+		return p.parsePacketAccess()
+	}
+
+	return p.errorAt(t, fmt.Sprintf("expected an arithmetic expression, found %s", describe(t)))
+}
+
+// parsePacketAccess parses a packet data accessor, e.g. 'ip[2:2]'.
+func (p *parser) parsePacketAccess() error {
+	p.next()
+	p.next()
+
+	if err := p.parseArithmetic(); err != nil {
+		return err
+	}
+
+	if isOperator(p.peek(), ":") {
+		p.next()
+
+		size := p.peek()
+		if size.kind != tokenIdentifier || size.value != "1" && size.value != "2" && size.value != "4" {
+			return p.errorAt(size, fmt.Sprintf("expected a data size of 1, 2 or 4, found %s", describe(size)))
+		}
+
+		p.next()
+	}
+
+	return p.expectOperator("]")
+}
+
+func (p *parser) parsePrimitive() error {
+	start := p.peek()
+	q := qualifiers{}
+
+	if t := p.peek(); t.kind == tokenIdentifier && protocolQualifiers[keyword(t)] {
+		q.protocol = keyword(p.next())
+
+		// the linux cooked header has the protocol type but no link-layer addresses
+		if linkLayerQualifiers[q.protocol] && p.linkType == LinkTypeLinuxSLL && !isKeyword(p.peek(), "proto") {
+			return p.errorAt(t, fmt.Sprintf("'%s' can't be used with the %s of interface 'any', "+
+				"capture on a specific interface (-i) instead", t.value, p.linkType))
+		}
+	}
+
+	if t := p.peek(); t.kind == tokenIdentifier && directionQualifiers[keyword(t)] {
+		q.direction = keyword(p.next())
+
+		// 'src or dst' and 'src and dst'
+		if isKeyword(p.peek(), "or", "and") && p.peekAt(1).kind == tokenIdentifier && directionQualifiers[keyword(p.peekAt(1))] {
+			q.direction += " " + keyword(p.next()) + " " + keyword(p.next())
+		}
+	}
+
+	if t := p.peek(); t.kind == tokenIdentifier && typeQualifiers[keyword(t)] {
+		q.kind = keyword(p.next())
+
+		if q.protocol != "" && !typeProtocols[q.kind][q.protocol] {
+			return p.errorAt(start, fmt.Sprintf("'%s' modifier applied to %s", q.protocol, q.kind))
+		}
+
+		if q.kind == "gateway" && p.linkType == LinkTypeLinuxSLL {
+			return p.errorAt(t, fmt.Sprintf("'gateway' can't be used with the %s of interface 'any', "+
+				"capture on a specific interface (-i) instead", p.linkType))
+		}
+	}
+
+	if q.kind == "" && q.direction == "" {
+		handled, err := p.parseKeywordPrimitive(start, q)
+		if handled {
+			return err
+		}
+	}
+
+	if q.protocol == "" && q.direction == "" && q.kind == "" {
+		if p.previous != nil {
+			q = *p.previous
+		} else {
+			q.kind = "host"
+		}
+	}
+
+	if q.kind == "" {
+		q.kind = "host"
+	}
+
+	value := p.peek()
+	if value.kind != tokenIdentifier || isKeyword(value, "and", "or", "not") {
+		return p.errorAt(value, fmt.Sprintf("expected %s, found %s", describeKind(q.kind), describe(value)))
+	}
+
+	p.next()
+
+	if err := p.validateValue(q, value); err != nil {
+		return err
+	}
+
+	if q.kind == "net" && isKeyword(p.peek(), "mask") {
+		p.next()
+
+		mask := p.next()
+		if mask.kind != tokenIdentifier || net.ParseIP(mask.value) == nil {
+			return p.errorAt(mask, fmt.Sprintf("expected a network mask, found %s", describe(mask)))
+		}
+	}
+
+	p.previous = &q
+
+	return nil
+}
+
+// parseKeywordPrimitive parses the primitives that aren't followed by an id, handled is false when
+// the tokens should be parsed as a qualified id.
+func (p *parser) parseKeywordPrimitive(start token, q qualifiers) (handled bool, err error) {
+	t := p.peek()
+
+	switch {
+	case isKeyword(t, "broadcast", "multicast"):
+		if q.protocol == "" && p.linkType == LinkTypeLinuxSLL {
+			return true, p.errorAt(t, fmt.Sprintf("'%s' matches link-layer addresses which the %s of interface "+
+				"'any' doesn't have, use 'ip %s' or capture on a specific interface (-i) instead", t.value, p.linkType, t.value))
+		}
+
+		if q.protocol != "" && q.protocol != "ether" && q.protocol != "ip" && q.protocol != "ip6" {
+			return true, p.errorAt(start, fmt.Sprintf("'%s' modifier applied to %s", q.protocol, keyword(t)))
+		}
+
+		p.next()
+		return true, nil
+
+	case isKeyword(t, "proto", "protochain"):
+		p.next()
+
+		value := p.next()
+		if value.kind != tokenIdentifier || !isNumber(value.value) && !serviceNamePattern.MatchString(value.value) {
+			return true, p.errorAt(value, fmt.Sprintf("expected a protocol number or name, found %s", describe(value)))
+		}
+
+		// ethernet types are 16 bits, ip protocol numbers are 8 bits
+		maxProtocol := uint64(255)
+		if linkLayerQualifiers[q.protocol] {
+			maxProtocol = 65535
+		}
+
+		if n, ok := parseNumber(value.value); ok && n > maxProtocol {
+			return true, p.errorAt(value, fmt.Sprintf("protocol number %d is out of range (0-%d)", n, maxProtocol))
+		}
+
+		return true, nil
+
+	case q.protocol == "" && isKeyword(t, "less", "greater"):
+		p.next()
+
+		value := p.next()
+		if value.kind != tokenIdentifier || !isNumber(value.value) {
+			return true, p.errorAt(value, fmt.Sprintf("expected a length, found %s", describe(value)))
+		}
+
+		return true, nil
+
+	case q.protocol == "" && isKeyword(t, "inbound", "outbound"):
+		p.next()
+		return true, nil
+
+	case q.protocol == "vlan" || q.protocol == "mpls":
+		// the id is optional, e.g. 'vlan' and 'vlan 100'
+		if t.kind == tokenIdentifier && isNumber(t.value) {
+			p.next()
+		}
+
+		return true, nil
+
+	case q.protocol != "":
+		// a protocol alone, e.g. 'tcp' in 'tcp and port 80'
+		if t.kind != tokenIdentifier || isKeyword(t, "and", "or", "not") {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func describeKind(kind string) string {
+	switch kind {
+	case "port":
+		return "a port number or name"
+	case "portrange":
+		return "a port range"
+	case "net":
+		return "a network"
+	}
+
+	return "a host"
+}
+
+func (p *parser) validateValue(q qualifiers, value token) error {
+	switch q.kind {
+	case "port":
+		return p.validatePort(value, value.value)
+
+	case "portrange":
+		bounds := strings.SplitN(value.value, "-", 2)
+		if len(bounds) != 2 {
+			return p.errorAt(value, fmt.Sprintf("expected a port range (e.g. 1000-2000), found '%s'", value.value))
+		}
+
+		for _, bound := range bounds {
+			if err := p.validatePort(value, bound); err != nil {
+				return err
+			}
+		}
+
+	case "net":
+		return p.validateNet(value)
+
+	case "host", "gateway":
+		if linkLayerQualifiers[q.protocol] {
+			if _, err := net.ParseMAC(value.value); err != nil && !hostNamePattern.MatchString(value.value) {
+				return p.errorAt(value, fmt.Sprintf("invalid link-layer address '%s'", value.value))
+			}
+
+			return nil
+		}
+
+		if net.ParseIP(value.value) == nil && !hostNamePattern.MatchString(value.value) {
+			return p.errorAt(value, fmt.Sprintf("invalid host '%s'", value.value))
+		}
+
+		if strings.Contains(value.value, "/") {
+			return p.errorAt(value, fmt.Sprintf("'%s' is a network, use 'net' instead of 'host'", value.value))
+		}
+	}
+
+	return nil
+}
+
+func (p *parser) validatePort(t token, port string) error {
+	if isNumber(port) {
+		if n, _ := parseNumber(port); n > 65535 {
+			return p.errorAt(t, fmt.Sprintf("port %d is out of range (0-65535)", n))
+		}
+
+		return nil
+	}
+
+	if !serviceNamePattern.MatchString(port) {
+		return p.errorAt(t, fmt.Sprintf("invalid port '%s'", port))
+	}
+
+	return nil
+}
+
+func (p *parser) validateNet(t token) error {
+	if partialNetPattern.MatchString(t.value) {
+		for _, octet := range strings.Split(t.value, ".") {
+			if n, _ := strconv.Atoi(octet); n > 255 {
+				return p.errorAt(t, fmt.Sprintf("invalid network '%s'", t.value))
+			}
+		}
+
+		return nil
+	}
+
+	if !strings.Contains(t.value, "/") {
+		if net.ParseIP(t.value) == nil && !hostNamePattern.MatchString(t.value) {
+			return p.errorAt(t, fmt.Sprintf("invalid network '%s'", t.value))
+		}
+
+		return nil
+	}
+
+	ip, network, err := net.ParseCIDR(t.value)
+	if err != nil {
+		return p.errorAt(t, fmt.Sprintf("invalid network '%s'", t.value))
+	}
+
+	if !ip.Equal(network.IP) {
+		return p.errorAt(t, fmt.Sprintf("non-network bits set in '%s', use '%s'", t.value, network.String()))
+	}
+
+	return nil
+}
+
+func isNumber(value string) bool {
+	_, ok := parseNumber(value)
+	return ok
+}
+
+// parseNumber parses decimal, hexadecimal (0x) and octal (leading 0) numbers like libpcap.
+func parseNumber(value string) (uint64, bool) {
+	if value == "" || value[0] < '0' || value[0] > '9' {
+		return 0, false
+	}
+
+	n, err := strconv.ParseUint(value, 0, 32)
+	if err != nil {
+		return 0, false
+	}
+
+	return n, true
+}
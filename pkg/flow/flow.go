@@ -0,0 +1,257 @@
+// Package flow follows the connections of the captured packets.
+package flow
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"ksniff/pkg/packet"
+)
+
+// Connection states of the tcp flows.
+const (
+	StateOpening     = "opening"
+	StateEstablished = "established"
+	StateClosed      = "closed"
+	StateReset       = "reset"
+)
+
+// Endpoint is one end of a flow.
+type Endpoint struct {
+	IP   string
+	Port uint16
+}
+
+func (e Endpoint) String() string {
+	return packet.Endpoint(net.ParseIP(e.IP), e.Port)
+}
+
+// Key identifies a flow regardless of the packets direction.
+type Key struct {
+	Protocol uint8
+	A        Endpoint
+	B        Endpoint
+}
+
+// NewKey returns the key of the flow of the packet.
+func NewKey(p *packet.Packet) Key {
+	src := Endpoint{IP: p.SrcIP.String(), Port: p.SrcPort}
+	dst := Endpoint{IP: p.DstIP.String(), Port: p.DstPort}
+
+	if src.IP < dst.IP || (src.IP == dst.IP && src.Port <= dst.Port) {
+		return Key{Protocol: p.Protocol, A: src, B: dst}
+	}
+
+	return Key{Protocol: p.Protocol, A: dst, B: src}
+}
+
+// Direction indexes the flow counters, the client is the endpoint which sent the first packet, or the tcp SYN.
+const (
+	ClientToServer = 0
+	ServerToClient = 1
+)
+
+// Flow holds the statistics of a connection, or of the packets exchanged by two endpoints for connectionless
+// protocols.
+type Flow struct {
+	Protocol        uint8
+	Client          Endpoint
+	Server          Endpoint
+	FirstSeen       time.Time
+	LastSeen        time.Time
+	Packets         [2]uint64
+	Bytes           [2]uint64
+	Resets          int
+	Retransmissions int
+	State           string
+	// Opened reports whether the tcp SYN opening the connection was captured, Accepted whether its SYN-ACK was.
+	Opened   bool
+	Accepted bool
+	// RTT is the round trip time estimated from the tcp handshake and the acknowledged segments, zero when unknown.
+	RTT time.Duration
+
+	tracking [2]*sequenceTracking
+}
+
+// sequenceTracking follows the tcp sequence numbers sent in one direction.
+type sequenceTracking struct {
+	started bool
+	nextSeq uint32
+	// pendingSeq is the end of a segment waiting for its acknowledgement, used to sample the round trip time
+	pendingSeq  uint32
+	pendingTime time.Time
+	pending     bool
+	srtt        time.Duration
+}
+
+// after reports whether the sequence number a is after b, handling the sequence numbers wrap around.
+func after(a uint32, b uint32) bool {
+	return int32(a-b) > 0
+}
+
+func (f *Flow) Duration() time.Duration {
+	return f.LastSeen.Sub(f.FirstSeen)
+}
+
+func (f *Flow) TotalPackets() uint64 {
+	return f.Packets[ClientToServer] + f.Packets[ServerToClient]
+}
+
+func (f *Flow) TotalBytes() uint64 {
+	return f.Bytes[ClientToServer] + f.Bytes[ServerToClient]
+}
+
+func (f *Flow) add(p *packet.Packet, direction int) {
+	f.LastSeen = p.Timestamp
+	f.Packets[direction]++
+	f.Bytes[direction] += uint64(p.Length)
+
+	if p.TCP != nil {
+		f.addSegment(p, direction)
+	}
+}
+
+func (f *Flow) addSegment(p *packet.Packet, direction int) {
+	flags := p.TCP.Flags
+
+	sent := f.tracking[direction]
+	if sent == nil {
+		sent = &sequenceTracking{}
+		f.tracking[direction] = sent
+	}
+
+	// SYN and FIN consume a sequence number
+	length := uint32(p.PayloadLength)
+	if flags&(packet.FlagSYN|packet.FlagFIN) != 0 {
+		length++
+	}
+	end := p.TCP.Seq + length
+
+	switch {
+	case !sent.started:
+		sent.started = true
+		sent.nextSeq = end
+	case length > 0 && !after(end, sent.nextSeq):
+		// keep alive probes resend the last byte, they aren't retransmissions
+		keepAlive := flags&(packet.FlagSYN|packet.FlagFIN) == 0 && p.PayloadLength <= 1 && p.TCP.Seq == sent.nextSeq-1
+		if !keepAlive {
+			f.Retransmissions++
+		}
+		// Karn's algorithm: the acknowledgement of a retransmitted segment is ambiguous
+		if sent.pending && !after(sent.pendingSeq, end) {
+			sent.pending = false
+		}
+	case after(end, sent.nextSeq):
+		sent.nextSeq = end
+	}
+
+	if length > 0 && !sent.pending && end == sent.nextSeq {
+		sent.pending, sent.pendingSeq, sent.pendingTime = true, end, p.Timestamp
+	}
+
+	received := f.tracking[1-direction]
+	if flags&packet.FlagACK != 0 && received != nil && received.pending && !after(received.pendingSeq, p.TCP.Ack) {
+		received.pending = false
+		sample := p.Timestamp.Sub(received.pendingTime)
+
+		if received.srtt == 0 {
+			received.srtt = sample
+		} else {
+			received.srtt = (7*received.srtt + sample) / 8
+		}
+
+		f.updateRTT()
+	}
+
+	switch {
+	case flags&packet.FlagRST != 0:
+		f.Resets++
+		f.State = StateReset
+	case flags&packet.FlagFIN != 0:
+		f.State = StateClosed
+	case flags&packet.FlagSYN != 0:
+		if flags&packet.FlagACK == 0 {
+			f.Opened = true
+		} else {
+			f.Accepted = true
+		}
+
+		if f.State == "" {
+			f.State = StateOpening
+		}
+	case f.State == StateOpening || f.State == "":
+		f.State = StateEstablished
+	}
+}
+
+// updateRTT sums the delays measured in both directions: a capture close to one endpoint measures most of the
+// round trip time in a single direction, while a capture in between splits it.
+func (f *Flow) updateRTT() {
+	f.RTT = 0
+	for _, tracking := range f.tracking {
+		if tracking != nil {
+			f.RTT += tracking.srtt
+		}
+	}
+}
+
+// Table follows the flows of the captured packets.
+type Table struct {
+	mutex sync.Mutex
+	flows map[Key]*Flow
+}
+
+func NewTable() *Table {
+	return &Table{flows: map[Key]*Flow{}}
+}
+
+// Add accounts the packet to its flow, and returns the flow direction of the packet.
+func (t *Table) Add(p *packet.Packet) (Key, int) {
+	key := NewKey(p)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	f, ok := t.flows[key]
+	if !ok {
+		f = &Flow{Protocol: p.Protocol, FirstSeen: p.Timestamp,
+			Client: Endpoint{IP: p.SrcIP.String(), Port: p.SrcPort}, Server: Endpoint{IP: p.DstIP.String(), Port: p.DstPort}}
+
+		// the SYN-ACK sender is the server, when the SYN wasn't captured
+		if p.TCP != nil && p.TCP.Flags&(packet.FlagSYN|packet.FlagACK) == packet.FlagSYN|packet.FlagACK {
+			f.Client, f.Server = f.Server, f.Client
+		}
+
+		t.flows[key] = f
+	}
+
+	direction := ServerToClient
+	if f.Client.IP == p.SrcIP.String() && f.Client.Port == p.SrcPort {
+		direction = ClientToServer
+	}
+
+	f.add(p, direction)
+
+	return key, direction
+}
+
+// Flows returns a copy of the flows, sorted by the order they were first seen.
+func (t *Table) Flows() []Flow {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	flows := make([]Flow, 0, len(t.flows))
+	for _, f := range t.flows {
+		copied := *f
+		copied.tracking = [2]*sequenceTracking{}
+		flows = append(flows, copied)
+	}
+
+	sort.Slice(flows, func(i, j int) bool {
+		return flows[i].FirstSeen.Before(flows[j].FirstSeen)
+	})
+
+	return flows
+}
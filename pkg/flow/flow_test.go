@@ -0,0 +1,98 @@
+package flow
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"ksniff/pkg/packet"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var start = time.Unix(1700000000, 0)
+
+func segment(milliseconds int, src string, srcPort uint16, dst string, dstPort uint16, seq uint32, ack uint32,
+	flags uint8, length int) *packet.Packet {
+
+	return &packet.Packet{
+		Timestamp: start.Add(time.Duration(milliseconds) * time.Millisecond),
+		Length:    54 + length, SrcIP: net.ParseIP(src), DstIP: net.ParseIP(dst), Protocol: packet.ProtocolTCP,
+		SrcPort: srcPort, DstPort: dstPort, PayloadLength: length,
+		TCP: &packet.TCP{Seq: seq, Ack: ack, Flags: flags},
+	}
+}
+
+func TestTable_TCPConnection(t *testing.T) {
+	// given
+	table := NewTable()
+
+	// when
+	table.Add(segment(0, "10.0.0.1", 40000, "10.0.0.2", 80, 100, 0, packet.FlagSYN, 0))
+	table.Add(segment(10, "10.0.0.2", 80, "10.0.0.1", 40000, 500, 101, packet.FlagSYN|packet.FlagACK, 0))
+	table.Add(segment(12, "10.0.0.1", 40000, "10.0.0.2", 80, 101, 501, packet.FlagACK, 0))
+	table.Add(segment(13, "10.0.0.1", 40000, "10.0.0.2", 80, 101, 501, packet.FlagPSH|packet.FlagACK, 100))
+	// the request is retransmitted
+	table.Add(segment(300, "10.0.0.1", 40000, "10.0.0.2", 80, 101, 501, packet.FlagPSH|packet.FlagACK, 100))
+	table.Add(segment(310, "10.0.0.2", 80, "10.0.0.1", 40000, 501, 201, packet.FlagPSH|packet.FlagACK, 50))
+	table.Add(segment(320, "10.0.0.1", 40000, "10.0.0.2", 80, 201, 551, packet.FlagRST, 0))
+
+	// then
+	flows := table.Flows()
+	assert.Equal(t, 1, len(flows))
+
+	f := flows[0]
+	assert.Equal(t, "10.0.0.1:40000", f.Client.String())
+	assert.Equal(t, "10.0.0.2:80", f.Server.String())
+	assert.Equal(t, uint64(5), f.Packets[ClientToServer])
+	assert.Equal(t, uint64(2), f.Packets[ServerToClient])
+	assert.Equal(t, 1, f.Retransmissions)
+	assert.Equal(t, 1, f.Resets)
+	assert.Equal(t, StateReset, f.State)
+	// the handshake measured 10ms towards the server and 2ms towards the client
+	assert.Equal(t, 12*time.Millisecond, f.RTT)
+	assert.Equal(t, 320*time.Millisecond, f.Duration())
+	assert.True(t, f.Opened)
+	assert.True(t, f.Accepted)
+}
+
+func TestTable_ServerDetectedFromSynAck(t *testing.T) {
+	// given
+	table := NewTable()
+
+	// when
+	table.Add(segment(0, "10.0.0.2", 80, "10.0.0.1", 40000, 500, 101, packet.FlagSYN|packet.FlagACK, 0))
+
+	// then
+	f := table.Flows()[0]
+	assert.Equal(t, "10.0.0.2:80", f.Server.String())
+	assert.Equal(t, uint64(1), f.Packets[ServerToClient])
+}
+
+func TestTable_KeepAliveIsNotRetransmission(t *testing.T) {
+	// given
+	table := NewTable()
+
+	// when
+	table.Add(segment(0, "10.0.0.1", 40000, "10.0.0.2", 80, 100, 1, packet.FlagACK, 10))
+	table.Add(segment(1000, "10.0.0.1", 40000, "10.0.0.2", 80, 109, 1, packet.FlagACK, 1))
+
+	// then
+	assert.Equal(t, 0, table.Flows()[0].Retransmissions)
+}
+
+func TestTable_UnansweredSyn(t *testing.T) {
+	// given
+	table := NewTable()
+
+	// when
+	table.Add(segment(0, "10.0.0.1", 40000, "10.0.0.2", 80, 100, 0, packet.FlagSYN, 0))
+	table.Add(segment(1000, "10.0.0.1", 40000, "10.0.0.2", 80, 100, 0, packet.FlagSYN, 0))
+
+	// then
+	f := table.Flows()[0]
+	assert.True(t, f.Opened)
+	assert.False(t, f.Accepted)
+	assert.Equal(t, StateOpening, f.State)
+	assert.Equal(t, 1, f.Retransmissions)
+}
@@ -0,0 +1,285 @@
+package httplog
+
+import (
+	"bufio"
+	"bytes"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxHeaderLength bounds the buffered headers of a message, the direction is no longer parsed above it.
+const maxHeaderLength = 64 * 1024
+
+// States of the http/1.x parser.
+const (
+	stateHeaders = iota
+	stateBody
+	stateChunkSize
+	stateChunkData
+	stateChunkEnd
+	stateTrailers
+	stateUntilClose
+	stateUpgraded
+)
+
+var headersEnd = []byte("\r\n\r\n")
+var lineEnd = []byte("\r\n")
+
+// http1Message holds the start line and headers of a request or a response.
+type http1Message struct {
+	proto   string
+	method  string
+	target  string
+	status  int
+	headers textproto.MIMEHeader
+}
+
+// http1Handler receives the parsed messages of a direction. Headers returns whether the message has no body, e.g.
+// a response to a HEAD request, and whether the connection protocol is switched after it.
+type http1Handler interface {
+	headers(m *http1Message, timestamp time.Time) (noBody bool, upgrade bool)
+	end(bodyLength int64, timestamp time.Time)
+}
+
+// http1Parser parses the http/1.x messages sent in one direction of a connection.
+type http1Parser struct {
+	response   bool
+	handler    http1Handler
+	buffer     []byte
+	state      int
+	remaining  int64
+	bodyLength int64
+}
+
+func newHTTP1Parser(response bool, handler http1Handler) *http1Parser {
+	return &http1Parser{response: response, handler: handler}
+}
+
+// isHTTP1Request reports whether the data starts with a request line, e.g. 'GET / HTTP/1.1'.
+func isHTTP1Request(data []byte) bool {
+	space := bytes.IndexByte(data, ' ')
+	if space < 3 || space > 16 {
+		return false
+	}
+
+	for _, c := range data[:space] {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// feed parses the data, and returns the data following the message which switched the protocol of the connection.
+func (p *http1Parser) feed(data []byte, timestamp time.Time) ([]byte, error) {
+	p.buffer = append(p.buffer, data...)
+
+	for len(p.buffer) > 0 {
+		var consumed int
+		var err error
+
+		switch p.state {
+		case stateUpgraded:
+			upgraded := p.buffer
+			p.buffer = nil
+			return upgraded, nil
+
+		case stateHeaders:
+			consumed, err = p.parseHeaders(timestamp)
+
+		case stateBody, stateChunkData:
+			consumed = len(p.buffer)
+			if int64(consumed) > p.remaining {
+				consumed = int(p.remaining)
+			}
+			p.remaining -= int64(consumed)
+			p.bodyLength += int64(consumed)
+
+			if p.remaining == 0 {
+				if p.state == stateBody {
+					p.end(timestamp)
+				} else {
+					p.state = stateChunkEnd
+				}
+			}
+
+		case stateChunkSize:
+			consumed, err = p.parseChunkSize()
+
+		case stateChunkEnd:
+			if len(p.buffer) < 2 {
+				return nil, nil
+			}
+			if !bytes.HasPrefix(p.buffer, lineEnd) {
+				return nil, errors.New("invalid chunk end")
+			}
+			consumed = 2
+			p.state = stateChunkSize
+
+		case stateTrailers:
+			if bytes.HasPrefix(p.buffer, lineEnd) {
+				consumed = 2
+			} else if index := bytes.Index(p.buffer, headersEnd); index >= 0 {
+				consumed = index + len(headersEnd)
+			}
+
+			if consumed > 0 {
+				p.end(timestamp)
+			}
+
+		case stateUntilClose:
+			consumed = len(p.buffer)
+			p.bodyLength += int64(consumed)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if consumed == 0 {
+			if p.state == stateHeaders && len(p.buffer) > maxHeaderLength {
+				return nil, errors.New("http headers too long")
+			}
+			return nil, nil
+		}
+
+		p.buffer = p.buffer[consumed:]
+	}
+
+	p.buffer = nil
+
+	return nil, nil
+}
+
+// close ends the message delimited by the connection closing.
+func (p *http1Parser) close(timestamp time.Time) {
+	if p.state == stateUntilClose {
+		p.end(timestamp)
+	}
+}
+
+func (p *http1Parser) end(timestamp time.Time) {
+	p.handler.end(p.bodyLength, timestamp)
+	p.state = stateHeaders
+	p.bodyLength = 0
+}
+
+func (p *http1Parser) parseHeaders(timestamp time.Time) (int, error) {
+	index := bytes.Index(p.buffer, headersEnd)
+	if index < 0 {
+		return 0, nil
+	}
+
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(p.buffer[:index+len(headersEnd)])))
+	line, err := reader.ReadLine()
+	if err != nil {
+		return 0, err
+	}
+
+	m := &http1Message{}
+	if err := m.parseStartLine(line, p.response); err != nil {
+		return 0, err
+	}
+
+	m.headers, err = reader.ReadMIMEHeader()
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid http headers")
+	}
+
+	noBody, upgrade := p.handler.headers(m, timestamp)
+	if upgrade {
+		p.state = stateUpgraded
+		return index + len(headersEnd), nil
+	}
+
+	// the informational responses, e.g. '100 Continue', are followed by the actual response
+	informational := p.response && m.status >= 100 && m.status < 200
+
+	switch {
+	case noBody || informational || (p.response && (m.status == 204 || m.status == 304)):
+		p.remaining = 0
+	case strings.Contains(strings.ToLower(m.headers.Get("Transfer-Encoding")), "chunked"):
+		p.state = stateChunkSize
+		return index + len(headersEnd), nil
+	case m.headers.Get("Content-Length") != "":
+		p.remaining, err = strconv.ParseInt(strings.TrimSpace(m.headers.Get("Content-Length")), 10, 64)
+		if err != nil || p.remaining < 0 {
+			return 0, errors.Errorf("invalid content length: '%s'", m.headers.Get("Content-Length"))
+		}
+	case p.response:
+		p.state = stateUntilClose
+		return index + len(headersEnd), nil
+	default:
+		p.remaining = 0
+	}
+
+	if p.remaining > 0 {
+		p.state = stateBody
+	} else if !informational {
+		p.end(timestamp)
+	}
+
+	return index + len(headersEnd), nil
+}
+
+func (m *http1Message) parseStartLine(line string, response bool) error {
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) < 2 {
+		return errors.Errorf("invalid http start line: '%s'", line)
+	}
+
+	if response {
+		if !strings.HasPrefix(parts[0], "HTTP/1.") {
+			return errors.Errorf("invalid http status line: '%s'", line)
+		}
+
+		status, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return errors.Errorf("invalid http status line: '%s'", line)
+		}
+
+		m.proto, m.status = parts[0], status
+		return nil
+	}
+
+	if len(parts) != 3 || !strings.HasPrefix(parts[2], "HTTP/1.") {
+		return errors.Errorf("invalid http request line: '%s'", line)
+	}
+
+	m.method, m.target, m.proto = parts[0], parts[1], parts[2]
+	return nil
+}
+
+func (p *http1Parser) parseChunkSize() (int, error) {
+	index := bytes.Index(p.buffer, lineEnd)
+	if index < 0 {
+		if len(p.buffer) > maxHeaderLength {
+			return 0, errors.New("invalid chunk size")
+		}
+		return 0, nil
+	}
+
+	size := string(p.buffer[:index])
+	if extension := strings.IndexByte(size, ';'); extension >= 0 {
+		size = size[:extension]
+	}
+
+	length, err := strconv.ParseInt(strings.TrimSpace(size), 16, 64)
+	if err != nil || length < 0 {
+		return 0, errors.Errorf("invalid chunk size: '%s'", size)
+	}
+
+	if length == 0 {
+		p.state = stateTrailers
+	} else {
+		p.state = stateChunkData
+		p.remaining = length
+	}
+
+	return index + len(lineEnd), nil
+}
@@ -0,0 +1,203 @@
+package httplog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/http2/hpack"
+)
+
+const (
+	frameHeaderLength = 9
+	// maxFrameLength is the largest frame length allowed by the http/2 settings.
+	maxFrameLength = 1<<24 - 1
+	// maxDynamicTableSize allows the header table sizes advertised by the peers, which aren't followed.
+	maxDynamicTableSize = 1 << 20
+
+	frameData         = 0x0
+	frameHeaders      = 0x1
+	frameRSTStream    = 0x3
+	framePushPromise  = 0x5
+	frameContinuation = 0x9
+
+	flagEndStream  = 0x1
+	flagEndHeaders = 0x4
+	flagPadded     = 0x8
+	flagPriority   = 0x20
+)
+
+// clientPreface starts the client data of the http/2 connections.
+var clientPreface = []byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n")
+
+// http2Handler receives the frames of the streams sent in one direction of a connection.
+type http2Handler interface {
+	streamHeaders(streamID uint32, fields []hpack.HeaderField, endStream bool, timestamp time.Time)
+	streamData(streamID uint32, length int, endStream bool, timestamp time.Time)
+	streamReset(streamID uint32, timestamp time.Time)
+}
+
+// http2Parser parses the http/2 frames sent in one direction of a connection, its header blocks are decoded in
+// order as they share the header compression state.
+type http2Parser struct {
+	handler http2Handler
+	decoder *hpack.Decoder
+	buffer  []byte
+	// preface is the part of the client preface not received yet.
+	preface []byte
+	// headerBlock holds the header block fragments of a stream until their end.
+	headerBlock     []byte
+	headerStreamID  uint32
+	headerEndStream bool
+	headerIgnored   bool
+}
+
+func newHTTP2Parser(client bool, handler http2Handler) *http2Parser {
+	decoder := hpack.NewDecoder(4096, nil)
+	decoder.SetAllowedMaxDynamicTableSize(maxDynamicTableSize)
+
+	p := &http2Parser{handler: handler, decoder: decoder}
+	if client {
+		p.preface = clientPreface
+	}
+
+	return p
+}
+
+// isHTTP2Preface reports whether the data starts the http/2 client preface.
+func isHTTP2Preface(data []byte) bool {
+	if len(data) > len(clientPreface) {
+		data = data[:len(clientPreface)]
+	}
+
+	return bytes.HasPrefix(clientPreface, data)
+}
+
+func (p *http2Parser) feed(data []byte, timestamp time.Time) error {
+	if len(p.preface) > 0 {
+		length := len(data)
+		if length > len(p.preface) {
+			length = len(p.preface)
+		}
+
+		if !bytes.Equal(data[:length], p.preface[:length]) {
+			return errors.New("invalid http/2 client preface")
+		}
+
+		p.preface = p.preface[length:]
+		data = data[length:]
+	}
+
+	p.buffer = append(p.buffer, data...)
+
+	for len(p.buffer) >= frameHeaderLength {
+		length := int(p.buffer[0])<<16 | int(p.buffer[1])<<8 | int(p.buffer[2])
+		if length > maxFrameLength {
+			return errors.Errorf("invalid http/2 frame length: '%d'", length)
+		}
+
+		if len(p.buffer) < frameHeaderLength+length {
+			break
+		}
+
+		frameType, flags := p.buffer[3], p.buffer[4]
+		streamID := binary.BigEndian.Uint32(p.buffer[5:9]) & 0x7fffffff
+		payload := p.buffer[frameHeaderLength : frameHeaderLength+length]
+
+		if err := p.frame(frameType, flags, streamID, payload, timestamp); err != nil {
+			return err
+		}
+
+		p.buffer = p.buffer[frameHeaderLength+length:]
+	}
+
+	if len(p.buffer) == 0 {
+		p.buffer = nil
+	}
+
+	return nil
+}
+
+// unpad removes the padding of the DATA, HEADERS and PUSH_PROMISE frames.
+func unpad(flags uint8, payload []byte) ([]byte, error) {
+	if flags&flagPadded == 0 {
+		return payload, nil
+	}
+
+	if len(payload) < 1 || int(payload[0]) > len(payload)-1 {
+		return nil, errors.New("invalid http/2 frame padding")
+	}
+
+	return payload[1 : len(payload)-int(payload[0])], nil
+}
+
+func (p *http2Parser) frame(frameType uint8, flags uint8, streamID uint32, payload []byte, timestamp time.Time) error {
+	var err error
+
+	switch frameType {
+	case frameData:
+		if payload, err = unpad(flags, payload); err != nil {
+			return err
+		}
+		p.handler.streamData(streamID, len(payload), flags&flagEndStream != 0, timestamp)
+
+	case frameHeaders:
+		if payload, err = unpad(flags, payload); err != nil {
+			return err
+		}
+
+		if flags&flagPriority != 0 {
+			if len(payload) < 5 {
+				return errors.New("invalid http/2 headers frame")
+			}
+			payload = payload[5:]
+		}
+
+		p.headerStreamID, p.headerEndStream, p.headerIgnored = streamID, flags&flagEndStream != 0, false
+		return p.headerFragment(payload, flags, timestamp)
+
+	case framePushPromise:
+		if payload, err = unpad(flags, payload); err != nil {
+			return err
+		}
+
+		if len(payload) < 4 {
+			return errors.New("invalid http/2 push promise frame")
+		}
+
+		// the promised requests aren't logged, their headers are only decoded to follow the compression state
+		p.headerStreamID, p.headerEndStream, p.headerIgnored = streamID, false, true
+		return p.headerFragment(payload[4:], flags, timestamp)
+
+	case frameContinuation:
+		if streamID != p.headerStreamID {
+			return errors.New("unexpected http/2 continuation frame")
+		}
+		return p.headerFragment(payload, flags, timestamp)
+
+	case frameRSTStream:
+		p.handler.streamReset(streamID, timestamp)
+	}
+
+	return nil
+}
+
+func (p *http2Parser) headerFragment(fragment []byte, flags uint8, timestamp time.Time) error {
+	p.headerBlock = append(p.headerBlock, fragment...)
+	if flags&flagEndHeaders == 0 {
+		return nil
+	}
+
+	fields, err := p.decoder.DecodeFull(p.headerBlock)
+	p.headerBlock = nil
+	if err != nil {
+		return errors.Wrap(err, "failed to decode http/2 headers")
+	}
+
+	if !p.headerIgnored {
+		p.handler.streamHeaders(p.headerStreamID, fields, p.headerEndStream, timestamp)
+	}
+
+	return nil
+}
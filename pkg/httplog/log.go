@@ -0,0 +1,463 @@
+// Package httplog follows the plaintext http/1.x and http/2 (h2c and gRPC) requests of the captured connections.
+package httplog
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"ksniff/pkg/flow"
+	"ksniff/pkg/packet"
+	"ksniff/pkg/pcap"
+	"ksniff/pkg/stream"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/http2/hpack"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	protocolHTTP2 = "HTTP/2"
+	grpcPrefix    = "application/grpc"
+)
+
+// Names resolves the captured addresses to pod names.
+type Names interface {
+	Name(ip string) string
+}
+
+// Entry is a request of the http log, with its response.
+type Entry struct {
+	Time       time.Time `json:"time"`
+	Client     string    `json:"client"`
+	ClientName string    `json:"clientName,omitempty"`
+	Server     string    `json:"server"`
+	ServerName string    `json:"serverName,omitempty"`
+	Protocol   string    `json:"protocol"`
+	Method     string    `json:"method"`
+	Host       string    `json:"host,omitempty"`
+	Path       string    `json:"path"`
+	// Status is zero when the response wasn't captured, e.g. when the connection was closed before.
+	Status int `json:"status,omitempty"`
+	// GRPC reports whether the request is a gRPC call, whose method is the path.
+	GRPC       bool   `json:"grpc,omitempty"`
+	GRPCStatus string `json:"grpcStatus,omitempty"`
+	// LatencyMs is the delay between the start of the request and the start of its response.
+	LatencyMs     float64 `json:"latencyMs,omitempty"`
+	RequestBytes  int64   `json:"requestBytes"`
+	ResponseBytes int64   `json:"responseBytes"`
+	// Reset reports whether the http/2 stream was reset before its end.
+	Reset bool `json:"reset,omitempty"`
+}
+
+// String formats the entry as a single access log line.
+func (e *Entry) String() string {
+	client := e.Client
+	if e.ClientName != "" {
+		client = e.ClientName
+	}
+
+	server := e.Server
+	if e.ServerName != "" {
+		server = e.ServerName
+	}
+
+	line := fmt.Sprintf("%s %s > %s %s %s %s%s", e.Time.Format("15:04:05.000"), client, server, e.Protocol,
+		e.Method, e.Host, e.Path)
+
+	if e.Status == 0 {
+		line += " -"
+	} else {
+		line += fmt.Sprintf(" %d", e.Status)
+	}
+
+	if e.GRPC {
+		line += fmt.Sprintf(" grpc-status %s", orDash(e.GRPCStatus))
+	}
+
+	if e.Reset {
+		line += " reset"
+	}
+
+	if e.Status == 0 {
+		return line + fmt.Sprintf(" no response %dB", e.RequestBytes)
+	}
+
+	return line + fmt.Sprintf(" %.1fms %dB/%dB", e.LatencyMs, e.RequestBytes, e.ResponseBytes)
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
+
+func latency(start time.Time, end time.Time) float64 {
+	return float64(end.Sub(start).Microseconds()) / 1000
+}
+
+// grpcStatusName returns the name of the gRPC status code, e.g. 'Unavailable' for '14'.
+func grpcStatusName(value string) string {
+	code, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return value
+	}
+
+	return codes.Code(code).String()
+}
+
+// Tracker reassembles the captured connections, and emits the log entries once their response ends.
+type Tracker struct {
+	names     Names
+	emit      func(*Entry)
+	assembler *stream.Assembler
+}
+
+func NewTracker(names Names, emit func(*Entry)) *Tracker {
+	t := &Tracker{names: names, emit: emit}
+	t.assembler = stream.NewAssembler(t.newConnection)
+
+	return t
+}
+
+func (t *Tracker) name(ip string) string {
+	if t.names == nil {
+		return ""
+	}
+
+	return t.names.Name(ip)
+}
+
+// Consume reads the capture stream until its end, the pending requests are then emitted without response.
+func (t *Tracker) Consume(r io.Reader) error {
+	defer t.Flush()
+
+	reader, err := pcap.OpenStream(r)
+	if err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+
+	for {
+		captured, err := reader.ReadPacket()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		decoded, err := packet.Decode(captured)
+		if err != nil {
+			log.WithError(err).Debug("packet skipped by the http log")
+			continue
+		}
+
+		t.Add(decoded)
+	}
+}
+
+// Add reassembles the tcp segments, the other packets are ignored.
+func (t *Tracker) Add(p *packet.Packet) {
+	t.assembler.Add(p)
+}
+
+// Flush ends the connections, their pending requests are emitted without response.
+func (t *Tracker) Flush() {
+	t.assembler.Flush()
+}
+
+func (t *Tracker) newConnection(client flow.Endpoint, server flow.Endpoint) stream.Handler {
+	return &connection{tracker: t, client: client, server: server, streams: map[uint32]*http2Stream{}}
+}
+
+// http2Stream is a request of a http/2 connection.
+type http2Stream struct {
+	entry *Entry
+	// responded reports whether the final response headers were received.
+	responded bool
+}
+
+// connection detects the protocol of a connection from its first client data, and matches its requests and
+// responses.
+type connection struct {
+	tracker  *Tracker
+	client   flow.Endpoint
+	server   flow.Endpoint
+	detected bool
+	// ignored is set once the connection protocol isn't http or its parsing failed.
+	ignored bool
+	// http/1.x requests, answered in order
+	http1   [2]*http1Parser
+	pending []*Entry
+	// http/2 streams, and the request of a h2c upgrade which is answered by the stream 1
+	http2   [2]*http2Parser
+	streams map[uint32]*http2Stream
+	upgrade *Entry
+}
+
+func (c *connection) newEntry(protocol string, timestamp time.Time) *Entry {
+	return &Entry{Time: timestamp, Client: c.client.String(), ClientName: c.tracker.name(c.client.IP),
+		Server: c.server.String(), ServerName: c.tracker.name(c.server.IP), Protocol: protocol}
+}
+
+func (c *connection) ignore(err error) {
+	if err != nil {
+		log.WithError(err).Debugf("http log stopped for connection: '%s' > '%s'", c.client, c.server)
+	}
+
+	c.ignored = true
+	c.http1 = [2]*http1Parser{}
+	c.http2 = [2]*http2Parser{}
+}
+
+func (c *connection) Data(direction int, data []byte, timestamp time.Time) {
+	if c.ignored {
+		return
+	}
+
+	if !c.detected {
+		// the server data is only parsed once the protocol is detected from a request
+		if direction != flow.ClientToServer {
+			return
+		}
+
+		c.detected = true
+
+		switch {
+		case isHTTP2Preface(data):
+			c.http2 = [2]*http2Parser{newHTTP2Parser(true, c.side(flow.ClientToServer)),
+				newHTTP2Parser(false, c.side(flow.ServerToClient))}
+		case isHTTP1Request(data):
+			c.http1 = [2]*http1Parser{newHTTP1Parser(false, c.side(flow.ClientToServer)),
+				newHTTP1Parser(true, c.side(flow.ServerToClient))}
+		default:
+			c.ignore(nil)
+			return
+		}
+	}
+
+	if c.http2[direction] != nil {
+		if err := c.http2[direction].feed(data, timestamp); err != nil {
+			c.ignore(err)
+		}
+		return
+	}
+
+	if c.http1[direction] != nil {
+		upgraded, err := c.http1[direction].feed(data, timestamp)
+		if err != nil {
+			c.ignore(err)
+			return
+		}
+
+		// the server data following the protocol switch is http/2, or isn't parsed
+		if len(upgraded) > 0 && c.http2[direction] != nil {
+			if err := c.http2[direction].feed(upgraded, timestamp); err != nil {
+				c.ignore(err)
+			}
+		}
+	}
+}
+
+// Close emits the requests without response, and the responses delimited by the connection closing.
+func (c *connection) Close() {
+	if parser := c.http1[flow.ServerToClient]; parser != nil {
+		parser.close(time.Time{})
+	}
+
+	for _, entry := range c.pending {
+		c.tracker.emit(entry)
+	}
+	c.pending = nil
+
+	for id, s := range c.streams {
+		delete(c.streams, id)
+		c.tracker.emit(s.entry)
+	}
+}
+
+// side handles the messages sent by one side of the connection.
+type side struct {
+	c        *connection
+	response bool
+}
+
+func (c *connection) side(direction int) *side {
+	return &side{c: c, response: direction == flow.ServerToClient}
+}
+
+func (d *side) headers(m *http1Message, timestamp time.Time) (bool, bool) {
+	c := d.c
+
+	if !d.response {
+		entry := c.newEntry(m.proto, timestamp)
+		entry.Method, entry.Host, entry.Path = m.method, m.headers.Get("Host"), m.target
+		c.pending = append(c.pending, entry)
+
+		if strings.EqualFold(m.headers.Get("Upgrade"), "h2c") {
+			c.upgrade = entry
+		}
+
+		return false, false
+	}
+
+	if len(c.pending) == 0 {
+		return false, false
+	}
+
+	entry := c.pending[0]
+
+	if m.status == 101 {
+		c.pending = c.pending[1:]
+		c.http1 = [2]*http1Parser{}
+
+		// the upgraded request is answered by the http/2 stream 1
+		if c.upgrade == entry {
+			entry.Protocol = protocolHTTP2
+			c.streams[1] = &http2Stream{entry: entry}
+			c.http2 = [2]*http2Parser{newHTTP2Parser(true, c.side(flow.ClientToServer)),
+				newHTTP2Parser(false, c.side(flow.ServerToClient))}
+		} else {
+			c.emitFirst(entry, m.status, timestamp)
+			c.ignore(nil)
+		}
+
+		return false, true
+	}
+
+	if m.status < 200 {
+		return false, false
+	}
+
+	entry.Status = m.status
+	entry.LatencyMs = latency(entry.Time, timestamp)
+	c.upgrade = nil
+
+	return entry.Method == "HEAD" || entry.Method == "CONNECT", false
+}
+
+func (c *connection) emitFirst(entry *Entry, status int, timestamp time.Time) {
+	entry.Status = status
+	entry.LatencyMs = latency(entry.Time, timestamp)
+	c.tracker.emit(entry)
+}
+
+func (d *side) end(bodyLength int64, _ time.Time) {
+	c := d.c
+
+	if !d.response {
+		// the request was queued by its headers, the requests of a http/1.x connection are answered in order
+		if len(c.pending) > 0 {
+			c.pending[len(c.pending)-1].RequestBytes = bodyLength
+		}
+		return
+	}
+
+	if len(c.pending) == 0 || c.pending[0].Status == 0 {
+		return
+	}
+
+	entry := c.pending[0]
+	c.pending = c.pending[1:]
+	entry.ResponseBytes = bodyLength
+	c.tracker.emit(entry)
+}
+
+func (d *side) streamHeaders(streamID uint32, fields []hpack.HeaderField, endStream bool, timestamp time.Time) {
+	c := d.c
+	s, ok := c.streams[streamID]
+
+	if !d.response {
+		if ok {
+			// request trailers
+			return
+		}
+
+		entry := c.newEntry(protocolHTTP2, timestamp)
+		for _, field := range fields {
+			switch field.Name {
+			case ":method":
+				entry.Method = field.Value
+			case ":authority":
+				entry.Host = field.Value
+			case ":path":
+				entry.Path = field.Value
+			case "content-type":
+				entry.GRPC = strings.HasPrefix(field.Value, grpcPrefix)
+			}
+		}
+
+		c.streams[streamID] = &http2Stream{entry: entry}
+		return
+	}
+
+	if !ok {
+		return
+	}
+
+	for _, field := range fields {
+		switch field.Name {
+		case ":status":
+			status, err := strconv.Atoi(field.Value)
+			if err != nil || status < 200 {
+				continue
+			}
+
+			if !s.responded {
+				s.responded = true
+				s.entry.Status = status
+				s.entry.LatencyMs = latency(s.entry.Time, timestamp)
+			}
+		case "content-type":
+			s.entry.GRPC = s.entry.GRPC || strings.HasPrefix(field.Value, grpcPrefix)
+		case "grpc-status":
+			s.entry.GRPCStatus = grpcStatusName(field.Value)
+		}
+	}
+
+	if endStream {
+		c.endStream(streamID)
+	}
+}
+
+func (d *side) streamData(streamID uint32, length int, endStream bool, _ time.Time) {
+	c := d.c
+	s, ok := c.streams[streamID]
+	if !ok {
+		return
+	}
+
+	if !d.response {
+		s.entry.RequestBytes += int64(length)
+		return
+	}
+
+	s.entry.ResponseBytes += int64(length)
+	if endStream {
+		c.endStream(streamID)
+	}
+}
+
+func (d *side) streamReset(streamID uint32, _ time.Time) {
+	c := d.c
+	s, ok := c.streams[streamID]
+	if !ok {
+		return
+	}
+
+	s.entry.Reset = true
+	c.endStream(streamID)
+}
+
+func (c *connection) endStream(streamID uint32) {
+	s := c.streams[streamID]
+	delete(c.streams, streamID)
+	c.tracker.emit(s.entry)
+}
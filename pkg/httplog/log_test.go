@@ -0,0 +1,227 @@
+package httplog
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"ksniff/pkg/flow"
+	"ksniff/pkg/packet"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+type fakeNames map[string]string
+
+func (f fakeNames) Name(ip string) string {
+	return f[ip]
+}
+
+var start = time.Unix(1700000000, 0)
+
+// conversation sends the segments of a connection between 10.0.0.1:40000 and 10.0.0.2:8080.
+type conversation struct {
+	t       *testing.T
+	tracker *Tracker
+	entries []*Entry
+	seq     [2]uint32
+}
+
+func newConversation(t *testing.T) *conversation {
+	c := &conversation{t: t, seq: [2]uint32{100, 500}}
+	c.tracker = NewTracker(fakeNames{"10.0.0.1": "default/web-0", "10.0.0.2": "default/api-0"}, func(entry *Entry) {
+		c.entries = append(c.entries, entry)
+	})
+
+	c.segment(flow.ClientToServer, 0, packet.FlagSYN, nil)
+	c.segment(flow.ServerToClient, 0, packet.FlagSYN|packet.FlagACK, nil)
+
+	return c
+}
+
+func (c *conversation) segment(direction int, milliseconds int, flags uint8, data []byte) {
+	src, srcPort, dst, dstPort := "10.0.0.1", uint16(40000), "10.0.0.2", uint16(8080)
+	if direction == flow.ServerToClient {
+		src, srcPort, dst, dstPort = dst, dstPort, src, srcPort
+	}
+
+	c.tracker.Add(&packet.Packet{Timestamp: start.Add(time.Duration(milliseconds) * time.Millisecond),
+		SrcIP: net.ParseIP(src), SrcPort: srcPort, DstIP: net.ParseIP(dst), DstPort: dstPort,
+		Protocol: packet.ProtocolTCP, TCP: &packet.TCP{Seq: c.seq[direction], Flags: flags},
+		Payload: data, PayloadLength: len(data)})
+
+	c.seq[direction] += uint32(len(data))
+	if flags&(packet.FlagSYN|packet.FlagFIN) != 0 {
+		c.seq[direction]++
+	}
+}
+
+func (c *conversation) send(direction int, milliseconds int, data string) {
+	c.segment(direction, milliseconds, packet.FlagACK, []byte(data))
+}
+
+func (c *conversation) close(milliseconds int) {
+	c.segment(flow.ClientToServer, milliseconds, packet.FlagFIN|packet.FlagACK, nil)
+	c.segment(flow.ServerToClient, milliseconds, packet.FlagFIN|packet.FlagACK, nil)
+}
+
+// frames builds http/2 frames, the headers are encoded by the same encoder as they share its compression state.
+type frames struct {
+	t       *testing.T
+	buffer  bytes.Buffer
+	framer  *http2.Framer
+	encoder *hpack.Encoder
+	block   bytes.Buffer
+}
+
+func newFrames(t *testing.T) *frames {
+	f := &frames{t: t}
+	f.framer = http2.NewFramer(&f.buffer, nil)
+	f.encoder = hpack.NewEncoder(&f.block)
+
+	return f
+}
+
+func (f *frames) headers(streamID uint32, endStream bool, fields ...string) *frames {
+	f.block.Reset()
+	for i := 0; i < len(fields); i += 2 {
+		require.NoError(f.t, f.encoder.WriteField(hpack.HeaderField{Name: fields[i], Value: fields[i+1]}))
+	}
+
+	require.NoError(f.t, f.framer.WriteHeaders(http2.HeadersFrameParam{StreamID: streamID,
+		BlockFragment: f.block.Bytes(), EndStream: endStream, EndHeaders: true}))
+	return f
+}
+
+func (f *frames) data(streamID uint32, endStream bool, length int) *frames {
+	require.NoError(f.t, f.framer.WriteData(streamID, endStream, make([]byte, length)))
+	return f
+}
+
+func (f *frames) settings() *frames {
+	require.NoError(f.t, f.framer.WriteSettings())
+	return f
+}
+
+func (f *frames) reset(streamID uint32) *frames {
+	require.NoError(f.t, f.framer.WriteRSTStream(streamID, http2.ErrCodeCancel))
+	return f
+}
+
+func (f *frames) flush() string {
+	data := f.buffer.String()
+	f.buffer.Reset()
+	return data
+}
+
+func TestTracker_HTTP1(t *testing.T) {
+	// given
+	c := newConversation(t)
+
+	// when
+	c.send(flow.ClientToServer, 1, "GET /users HTTP/1.1\r\nHost: api:8080\r\n\r\n"+
+		"POST /orders HTTP/1.1\r\nHost: api:8080\r\nContent-Length: 3\r\n\r\nab")
+	c.send(flow.ClientToServer, 2, "c")
+	c.send(flow.ServerToClient, 13, "HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nhello")
+	c.send(flow.ServerToClient, 20, "HTTP/1.1 201 Created\r\nTransfer-Encoding: chunked\r\n\r\n4\r\nab")
+	c.send(flow.ServerToClient, 21, "cd\r\n2;name=value\r\nef\r\n0\r\n\r\n")
+	c.send(flow.ClientToServer, 30, "HEAD /health HTTP/1.1\r\nHost: api:8080\r\n\r\n")
+	c.send(flow.ServerToClient, 31, "HTTP/1.1 200 OK\r\nContent-Length: 100\r\n\r\n")
+	c.send(flow.ClientToServer, 40, "GET /slow HTTP/1.1\r\nHost: api:8080\r\n\r\n")
+	c.close(50)
+
+	// then
+	assert.Equal(t, 4, len(c.entries))
+
+	assert.Equal(t, &Entry{Time: start.Add(time.Millisecond), Client: "10.0.0.1:40000", ClientName: "default/web-0",
+		Server: "10.0.0.2:8080", ServerName: "default/api-0", Protocol: "HTTP/1.1", Method: "GET", Host: "api:8080",
+		Path: "/users", Status: 200, LatencyMs: 12, ResponseBytes: 5}, c.entries[0])
+
+	assert.Equal(t, "POST", c.entries[1].Method)
+	assert.Equal(t, 201, c.entries[1].Status)
+	assert.Equal(t, int64(3), c.entries[1].RequestBytes)
+	assert.Equal(t, int64(6), c.entries[1].ResponseBytes)
+	assert.Equal(t, 19.0, c.entries[1].LatencyMs)
+
+	assert.Equal(t, "HEAD", c.entries[2].Method)
+	assert.Equal(t, int64(0), c.entries[2].ResponseBytes)
+
+	assert.Equal(t, "/slow", c.entries[3].Path)
+	assert.Equal(t, 0, c.entries[3].Status)
+	assert.Contains(t, c.entries[3].String(), "default/web-0 > default/api-0 HTTP/1.1 GET api:8080/slow - no response 0B")
+}
+
+func TestTracker_GRPC(t *testing.T) {
+	// given
+	c := newConversation(t)
+	client, server := newFrames(t), newFrames(t)
+
+	// when
+	c.send(flow.ClientToServer, 1, string(clientPreface)+client.settings().
+		headers(1, false, ":method", "POST", ":scheme", "http", ":authority", "greeter:50051",
+			":path", "/helloworld.Greeter/SayHello", "content-type", "application/grpc").
+		data(1, true, 10).flush())
+	c.send(flow.ServerToClient, 5, server.settings().
+		headers(1, false, ":status", "200", "content-type", "application/grpc").
+		data(1, false, 7).
+		headers(1, true, "grpc-status", "14", "grpc-message", "unavailable").flush())
+	c.send(flow.ClientToServer, 10, client.
+		headers(3, false, ":method", "POST", ":scheme", "http", ":authority", "greeter:50051",
+			":path", "/helloworld.Greeter/SayHello", "content-type", "application/grpc").
+		reset(3).flush())
+
+	// then
+	assert.Equal(t, 2, len(c.entries))
+
+	call := c.entries[0]
+	assert.Equal(t, "HTTP/2", call.Protocol)
+	assert.Equal(t, "/helloworld.Greeter/SayHello", call.Path)
+	assert.Equal(t, "greeter:50051", call.Host)
+	assert.Equal(t, 200, call.Status)
+	assert.True(t, call.GRPC)
+	assert.Equal(t, "Unavailable", call.GRPCStatus)
+	assert.Equal(t, 4.0, call.LatencyMs)
+	assert.Equal(t, int64(10), call.RequestBytes)
+	assert.Equal(t, int64(7), call.ResponseBytes)
+	assert.Contains(t, call.String(), "HTTP/2 POST greeter:50051/helloworld.Greeter/SayHello 200 grpc-status Unavailable 4.0ms 10B/7B")
+
+	assert.True(t, c.entries[1].Reset)
+	assert.Equal(t, 0, c.entries[1].Status)
+}
+
+func TestTracker_H2CUpgrade(t *testing.T) {
+	// given
+	c := newConversation(t)
+	client, server := newFrames(t), newFrames(t)
+
+	// when
+	c.send(flow.ClientToServer, 1, "GET /items HTTP/1.1\r\nHost: api:8080\r\nConnection: Upgrade, HTTP2-Settings\r\n"+
+		"Upgrade: h2c\r\nHTTP2-Settings: AAMAAABkAARAAAAAAAIAAAAA\r\n\r\n")
+	c.send(flow.ServerToClient, 3, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: h2c\r\n\r\n"+
+		server.settings().headers(1, false, ":status", "200").data(1, true, 42).flush())
+	c.send(flow.ClientToServer, 4, string(clientPreface)+client.settings().flush())
+
+	// then
+	assert.Equal(t, 1, len(c.entries))
+	assert.Equal(t, "HTTP/2", c.entries[0].Protocol)
+	assert.Equal(t, "/items", c.entries[0].Path)
+	assert.Equal(t, 200, c.entries[0].Status)
+	assert.Equal(t, int64(42), c.entries[0].ResponseBytes)
+}
+
+func TestTracker_IgnoresOtherProtocols(t *testing.T) {
+	// given
+	c := newConversation(t)
+
+	// when
+	c.send(flow.ClientToServer, 1, "\x16\x03\x01\x00\x05hello")
+	c.send(flow.ServerToClient, 2, "HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n")
+	c.close(3)
+
+	// then
+	assert.Equal(t, 0, len(c.entries))
+}
@@ -0,0 +1,115 @@
+// Package keylog follows the tls key log files written by the applications, e.g. using the SSLKEYLOGFILE
+// environment variable, so wireshark decrypts their captured tls connections.
+package keylog
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxNotificationLength keeps the notified lines well below the largest pcapng block accepted by the consumers.
+const maxNotificationLength = 1 << 20
+
+// Source reads the whole content of a key log file.
+type Source interface {
+	Read() ([]byte, error)
+	String() string
+}
+
+// LocalFile is a key log file written on the local machine, e.g. by a port-forwarded client.
+type LocalFile string
+
+func (f LocalFile) Read() ([]byte, error) {
+	return os.ReadFile(string(f))
+}
+
+func (f LocalFile) String() string {
+	return string(f)
+}
+
+// Watcher reads the key log file periodically, and notifies the lines appended since the previous read.
+type Watcher struct {
+	source   Source
+	interval time.Duration
+	// offset is the length of the lines already notified, the file is read again from its start when truncated.
+	offset  int
+	failing bool
+}
+
+func NewWatcher(source Source, interval time.Duration) *Watcher {
+	return &Watcher{source: source, interval: interval}
+}
+
+// Watch notifies the complete lines appended to the key log file until the context is done.
+func (w *Watcher) Watch(ctx context.Context) <-chan []byte {
+	updates := make(chan []byte)
+
+	go func() {
+		defer close(updates)
+
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			for _, lines := range w.read() {
+				select {
+				case updates <- lines:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return updates
+}
+
+// read returns the lines appended since the previous read, split at line ends to bound their length.
+func (w *Watcher) read() [][]byte {
+	data, err := w.source.Read()
+	if err != nil {
+		// the file may not be written yet, e.g. before the first tls connection
+		if !w.failing {
+			log.WithError(err).Warnf("failed to read the tls key log: '%s', retrying", w.source)
+		}
+		w.failing = true
+		return nil
+	}
+
+	if w.failing {
+		log.Infof("reading the tls key log: '%s'", w.source)
+		w.failing = false
+	}
+
+	if len(data) < w.offset {
+		log.Infof("tls key log truncated: '%s', reading it from its start", w.source)
+		w.offset = 0
+	}
+
+	var notifications [][]byte
+
+	for {
+		appended := data[w.offset:]
+		if len(appended) > maxNotificationLength {
+			appended = appended[:maxNotificationLength]
+		}
+
+		end := bytes.LastIndexByte(appended, '\n')
+		if end < 0 {
+			return notifications
+		}
+
+		notifications = append(notifications, appended[:end+1])
+		w.offset += end + 1
+	}
+}
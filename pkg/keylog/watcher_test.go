@@ -0,0 +1,103 @@
+package keylog
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSource returns its contents in order, the last one is kept.
+type fakeSource struct {
+	contents []string
+	err      error
+}
+
+func (f *fakeSource) Read() ([]byte, error) {
+	content := f.contents[0]
+	if len(f.contents) > 1 {
+		f.contents = f.contents[1:]
+	}
+
+	return []byte(content), f.err
+}
+
+func (f *fakeSource) String() string {
+	return "fake"
+}
+
+func TestWatcher_Read(t *testing.T) {
+	// given
+	source := &fakeSource{contents: []string{
+		"CLIENT_RANDOM 01 02\nCLIENT_RA",
+		"CLIENT_RANDOM 01 02\nCLIENT_RANDOM 03 04\n",
+		"CLIENT_RANDOM 01 02\nCLIENT_RANDOM 03 04\n",
+		"CLIENT_RANDOM 05 06\n",
+	}}
+	watcher := NewWatcher(source, time.Second)
+
+	// when
+	var reads [][][]byte
+	for range source.contents {
+		reads = append(reads, watcher.read())
+	}
+
+	// then
+	assert.Equal(t, [][][]byte{
+		{[]byte("CLIENT_RANDOM 01 02\n")},
+		{[]byte("CLIENT_RANDOM 03 04\n")},
+		nil,
+		// truncated, e.g. the application was restarted
+		{[]byte("CLIENT_RANDOM 05 06\n")},
+	}, reads)
+}
+
+func TestWatcher_SplitsLongContent(t *testing.T) {
+	// given
+	line := "CLIENT_RANDOM 01 02\n"
+	content := strings.Repeat(line, maxNotificationLength/len(line)+1)
+	watcher := NewWatcher(&fakeSource{contents: []string{content}}, time.Second)
+
+	// when
+	notifications := watcher.read()
+
+	// then
+	assert.Equal(t, 2, len(notifications))
+	assert.True(t, len(notifications[0]) <= maxNotificationLength)
+	assert.Equal(t, content, string(notifications[0])+string(notifications[1]))
+}
+
+func TestWatcher_ReadFailure(t *testing.T) {
+	// given
+	source := &fakeSource{contents: []string{"CLIENT_RANDOM 01 02\n"}, err: errors.New("no such file")}
+	watcher := NewWatcher(source, time.Second)
+
+	// when
+	failed := watcher.read()
+	source.err = nil
+	recovered := watcher.read()
+
+	// then
+	assert.Nil(t, failed)
+	assert.Equal(t, [][]byte{[]byte("CLIENT_RANDOM 01 02\n")}, recovered)
+}
+
+func TestWatcher_Watch(t *testing.T) {
+	// given
+	source := &fakeSource{contents: []string{"", "CLIENT_RANDOM 01 02\n"}}
+	watcher := NewWatcher(source, time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// when
+	updates := watcher.Watch(ctx)
+
+	// then
+	assert.Equal(t, "CLIENT_RANDOM 01 02\n", string(<-updates))
+	cancel()
+	for range updates {
+	}
+}
@@ -0,0 +1,144 @@
+// Package output streams the capture to consumers connecting while the capture runs, e.g. a remote wireshark.
+package output
+
+import (
+	"io"
+	"sync"
+
+	"ksniff/pkg/pcap"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// consumerQueueLength is the number of frames buffered for a consumer before its packets are dropped.
+const consumerQueueLength = 4096
+
+type consumer struct {
+	name    string
+	writer  io.WriteCloser
+	frames  chan []byte
+	done    chan struct{}
+	dropped int
+}
+
+// Broadcaster fans out a single pcap or pcapng stream to any number of consumers. Consumers joining the stream
+// receive its header first, and consumers that can't keep up lose packets rather than slowing down the others.
+type Broadcaster struct {
+	framer    *pcap.Framer
+	mutex     sync.Mutex
+	header    [][]byte
+	consumers map[*consumer]bool
+	closed    bool
+}
+
+func NewBroadcaster() *Broadcaster {
+	b := &Broadcaster{consumers: map[*consumer]bool{}}
+	b.framer = pcap.NewFramer(b.broadcast)
+
+	return b
+}
+
+// Write never fails because of consumers, only an invalid capture stream stops the capture.
+func (b *Broadcaster) Write(p []byte) (int, error) {
+	return b.framer.Write(p)
+}
+
+// AddConsumer streams the capture to the writer until writing fails or the broadcaster is closed, the returned
+// channel is closed once the consumer is removed.
+func (b *Broadcaster) AddConsumer(name string, writer io.WriteCloser) <-chan struct{} {
+	c := &consumer{name: name, writer: writer, frames: make(chan []byte, consumerQueueLength), done: make(chan struct{})}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.closed {
+		_ = writer.Close()
+		close(c.done)
+		return c.done
+	}
+
+	log.Infof("consumer connected: '%s'", name)
+
+	for _, frame := range b.header {
+		c.frames <- frame
+	}
+
+	b.consumers[c] = true
+
+	go b.serve(c)
+
+	return c.done
+}
+
+func (b *Broadcaster) serve(c *consumer) {
+	defer close(c.done)
+
+	for frame := range c.frames {
+		if _, err := c.writer.Write(frame); err != nil {
+			log.WithError(err).Infof("consumer disconnected: '%s'", c.name)
+			b.removeConsumer(c)
+			break
+		}
+	}
+
+	_ = c.writer.Close()
+}
+
+func (b *Broadcaster) removeConsumer(c *consumer) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.consumers[c] {
+		delete(b.consumers, c)
+		close(c.frames)
+	}
+}
+
+func (b *Broadcaster) broadcast(frame pcap.Frame) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if frame.Header {
+		// a new pcap stream or pcapng section invalidates the previous header
+		if frame.Start {
+			b.header = nil
+		}
+		b.header = append(b.header, frame.Data)
+	}
+
+	for c := range b.consumers {
+		select {
+		case c.frames <- frame.Data:
+			continue
+		default:
+		}
+
+		// without its header the consumer can't read the following packets
+		if frame.Header {
+			log.Warnf("consumer too slow, disconnecting: '%s'", c.name)
+			delete(b.consumers, c)
+			close(c.frames)
+			continue
+		}
+
+		if c.dropped == 0 {
+			log.Warnf("consumer too slow, dropping packets: '%s'", c.name)
+		}
+		c.dropped++
+	}
+}
+
+// Close disconnects the consumers once they received the buffered frames.
+func (b *Broadcaster) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.closed = true
+
+	for c := range b.consumers {
+		delete(b.consumers, c)
+		close(c.frames)
+	}
+
+	return nil
+}
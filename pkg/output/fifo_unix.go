@@ -0,0 +1,86 @@
+//go:build !windows
+// +build !windows
+
+package output
+
+import (
+	"io"
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+type fifo struct {
+	path    string
+	created bool
+	closed  chan struct{}
+}
+
+// ServeFifo streams the capture to the readers of a named pipe, which is created unless it already exists. A single
+// reader is served at a time, the next reader receives the capture from its header once the previous one exits.
+func ServeFifo(path string, broadcaster *Broadcaster) (io.Closer, error) {
+	f := &fifo{path: path, closed: make(chan struct{})}
+
+	info, err := os.Stat(path)
+	switch {
+	case os.IsNotExist(err):
+		if err := syscall.Mkfifo(path, 0600); err != nil {
+			return nil, errors.Wrapf(err, "failed to create named pipe: '%s'", path)
+		}
+		f.created = true
+	case err != nil:
+		return nil, err
+	case info.Mode()&os.ModeNamedPipe == 0:
+		return nil, errors.Errorf("output path isn't a named pipe: '%s'", path)
+	}
+
+	go f.serve(broadcaster)
+
+	return f, nil
+}
+
+func (f *fifo) serve(broadcaster *Broadcaster) {
+	for {
+		select {
+		case <-f.closed:
+			return
+		default:
+		}
+
+		// blocks until a reader opens the named pipe
+		writer, err := os.OpenFile(f.path, os.O_WRONLY, 0)
+
+		select {
+		case <-f.closed:
+			if err == nil {
+				_ = writer.Close()
+			}
+			return
+		default:
+		}
+
+		if err != nil {
+			log.WithError(err).Errorf("failed to open named pipe: '%s'", f.path)
+			return
+		}
+
+		<-broadcaster.AddConsumer("fifo://"+f.path, writer)
+	}
+}
+
+func (f *fifo) Close() error {
+	close(f.closed)
+
+	// unblocks the pending open of the named pipe
+	if reader, err := os.OpenFile(f.path, os.O_RDONLY|syscall.O_NONBLOCK, 0); err == nil {
+		_ = reader.Close()
+	}
+
+	if f.created {
+		return os.Remove(f.path)
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+//go:build !windows
+// +build !windows
+
+package output
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeFifo(t *testing.T) {
+	// given
+	path := filepath.Join(t.TempDir(), "capture")
+	broadcaster := NewBroadcaster()
+	_, _ = broadcaster.Write(buildPcapHeader())
+
+	fifo, err := ServeFifo(path, broadcaster)
+	assert.Nil(t, err)
+
+	// when
+	reader, err := os.Open(path)
+	assert.Nil(t, err)
+
+	received := make([]byte, 24)
+	_, err = io.ReadFull(reader, received)
+	_ = reader.Close()
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, buildPcapHeader(), received)
+
+	assert.Nil(t, fifo.Close())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,15 @@
+//go:build windows
+// +build windows
+
+package output
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ServeFifo isn't supported on windows, whose named pipes can't be created at arbitrary paths.
+func ServeFifo(path string, broadcaster *Broadcaster) (io.Closer, error) {
+	return nil, errors.Errorf("named pipe output isn't supported on windows: '%s'", path)
+}
@@ -0,0 +1,149 @@
+package output
+
+import (
+	"io"
+	"net"
+	"net/url"
+	"sync"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxDatagramLength is the largest udp payload, larger frames can't be sent to udp consumers.
+const maxDatagramLength = 65507
+
+// ParseListenAddress parses a 'tcp://host:port' or 'udp://host:port' address.
+func ParseListenAddress(address string) (network string, hostPort string, err error) {
+	parsed, err := url.Parse(address)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "invalid listen address: '%s'", address)
+	}
+
+	if parsed.Scheme != "tcp" && parsed.Scheme != "udp" {
+		return "", "", errors.Errorf("invalid listen address: '%s', expected 'tcp://host:port' or 'udp://host:port'", address)
+	}
+
+	if parsed.Port() == "" || parsed.Path != "" {
+		return "", "", errors.Errorf("invalid listen address: '%s', expected '%s://host:port'", address, parsed.Scheme)
+	}
+
+	return parsed.Scheme, parsed.Host, nil
+}
+
+// Listen streams the capture to the consumers connecting to a 'tcp://host:port' address, or sending any
+// datagram to a 'udp://host:port' address. Udp consumers then receive a datagram per pcap record or pcapng block.
+func Listen(address string, broadcaster *Broadcaster) (io.Closer, error) {
+	network, hostPort, err := ParseListenAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	if network == "udp" {
+		connection, err := net.ListenPacket(network, hostPort)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to listen on: '%s'", address)
+		}
+
+		warnIfNotLoopback(connection.LocalAddr())
+
+		go acceptDatagramConsumers(connection, broadcaster)
+
+		return connection, nil
+	}
+
+	listener, err := net.Listen(network, hostPort)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to listen on: '%s'", address)
+	}
+
+	warnIfNotLoopback(listener.Addr())
+
+	go acceptStreamConsumers(listener, broadcaster)
+
+	return listener, nil
+}
+
+// warnIfNotLoopback warns that the unauthenticated and unencrypted capture can be read from other machines.
+func warnIfNotLoopback(address net.Addr) {
+	var ip net.IP
+	switch a := address.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+	case *net.UDPAddr:
+		ip = a.IP
+	}
+
+	if ip != nil && ip.IsLoopback() {
+		return
+	}
+
+	log.Warnf("streaming the capture on: '%s', any machine reaching this address can read it without "+
+		"authentication, prefer a loopback address with a ssh tunnel", address)
+}
+
+func acceptStreamConsumers(listener net.Listener, broadcaster *Broadcaster) {
+	for {
+		connection, err := listener.Accept()
+		if err != nil {
+			log.WithError(err).Debugf("stopped accepting consumers on: '%s'", listener.Addr())
+			return
+		}
+
+		broadcaster.AddConsumer("tcp://"+connection.RemoteAddr().String(), connection)
+	}
+}
+
+// datagramWriter sends each frame written by the broadcaster to the consumer address as a single datagram.
+type datagramWriter struct {
+	connection net.PacketConn
+	address    net.Addr
+	onClose    func()
+}
+
+func (d *datagramWriter) Write(p []byte) (int, error) {
+	if len(p) > maxDatagramLength {
+		log.Debugf("dropping frame larger than a datagram: '%d' bytes", len(p))
+		return len(p), nil
+	}
+
+	return d.connection.WriteTo(p, d.address)
+}
+
+func (d *datagramWriter) Close() error {
+	d.onClose()
+	return nil
+}
+
+func acceptDatagramConsumers(connection net.PacketConn, broadcaster *Broadcaster) {
+	var mutex sync.Mutex
+	consumers := map[string]bool{}
+
+	buffer := make([]byte, maxDatagramLength)
+	for {
+		_, address, err := connection.ReadFrom(buffer)
+		if err != nil {
+			log.WithError(err).Debugf("stopped accepting consumers on: '%s'", connection.LocalAddr())
+			return
+		}
+
+		// consumers may send datagrams periodically, e.g. to keep a nat mapping
+		key := address.String()
+
+		mutex.Lock()
+		if consumers[key] {
+			mutex.Unlock()
+			continue
+		}
+		consumers[key] = true
+		mutex.Unlock()
+
+		writer := &datagramWriter{connection: connection, address: address, onClose: func() {
+			mutex.Lock()
+			defer mutex.Unlock()
+			delete(consumers, key)
+		}}
+
+		broadcaster.AddConsumer("udp://"+key, writer)
+	}
+}
@@ -0,0 +1,137 @@
+package output
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildPcapHeader() []byte {
+	buffer := new(bytes.Buffer)
+	_ = binary.Write(buffer, binary.LittleEndian, []uint32{0xa1b2c3d4, 0x00040002, 0, 0, 262144, 276})
+	return buffer.Bytes()
+}
+
+func buildPcapRecord(data ...byte) []byte {
+	buffer := new(bytes.Buffer)
+	_ = binary.Write(buffer, binary.LittleEndian, []uint32{1700000000, 0, uint32(len(data)), uint32(len(data))})
+	buffer.Write(data)
+	return buffer.Bytes()
+}
+
+type bufferConsumer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *bufferConsumer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *bufferConsumer) Close() error {
+	return nil
+}
+
+func (b *bufferConsumer) Bytes() []byte {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]byte{}, b.buffer.Bytes()...)
+}
+
+func TestParseListenAddress(t *testing.T) {
+	// given
+	tests := map[string]bool{
+		"tcp://127.0.0.1:19000": true,
+		"udp://0.0.0.0:19000":   true,
+		"tcp://127.0.0.1":       false,
+		"http://127.0.0.1:80":   false,
+		"127.0.0.1:19000":       false,
+	}
+
+	for address, valid := range tests {
+		// when
+		_, _, err := ParseListenAddress(address)
+
+		// then
+		assert.Equal(t, valid, err == nil, address)
+	}
+}
+
+func TestBroadcaster_LateConsumerReceivesHeader(t *testing.T) {
+	// given
+	broadcaster := NewBroadcaster()
+	early := &bufferConsumer{}
+	late := &bufferConsumer{}
+
+	earlyDone := broadcaster.AddConsumer("early", early)
+	_, _ = broadcaster.Write(buildPcapHeader())
+	_, _ = broadcaster.Write(buildPcapRecord(1, 2))
+
+	// when
+	lateDone := broadcaster.AddConsumer("late", late)
+	_, _ = broadcaster.Write(buildPcapRecord(3))
+	_ = broadcaster.Close()
+	<-earlyDone
+	<-lateDone
+
+	// then
+	assert.Equal(t, append(append(buildPcapHeader(), buildPcapRecord(1, 2)...), buildPcapRecord(3)...), early.Bytes())
+	assert.Equal(t, append(buildPcapHeader(), buildPcapRecord(3)...), late.Bytes())
+}
+
+func TestListen_TcpConsumer(t *testing.T) {
+	// given
+	broadcaster := NewBroadcaster()
+	_, _ = broadcaster.Write(buildPcapHeader())
+
+	listener, err := Listen("tcp://127.0.0.1:0", broadcaster)
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	// when
+	connection, err := net.Dial("tcp", listener.(net.Listener).Addr().String())
+	assert.Nil(t, err)
+	defer connection.Close()
+
+	_ = connection.SetReadDeadline(time.Now().Add(5 * time.Second))
+	received := make([]byte, 24)
+	_, err = io.ReadFull(connection, received)
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, buildPcapHeader(), received)
+}
+
+func TestListen_UdpConsumer(t *testing.T) {
+	// given
+	broadcaster := NewBroadcaster()
+	_, _ = broadcaster.Write(buildPcapHeader())
+
+	listener, err := Listen("udp://127.0.0.1:0", broadcaster)
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	connection, err := net.Dial("udp", listener.(net.PacketConn).LocalAddr().String())
+	assert.Nil(t, err)
+	defer connection.Close()
+
+	// when
+	_, err = connection.Write([]byte("hello"))
+	assert.Nil(t, err)
+
+	_ = connection.SetReadDeadline(time.Now().Add(5 * time.Second))
+	received := make([]byte, 1500)
+	n, err := connection.Read(received)
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, buildPcapHeader(), received[:n])
+}
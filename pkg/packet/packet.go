@@ -0,0 +1,319 @@
+// Package packet decodes the network and transport headers of the captured packets.
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"ksniff/pkg/pcap"
+
+	"github.com/pkg/errors"
+)
+
+// IP protocol numbers.
+const (
+	ProtocolICMP   uint8 = 1
+	ProtocolTCP    uint8 = 6
+	ProtocolUDP    uint8 = 17
+	ProtocolICMPv6 uint8 = 58
+)
+
+// TCP flags.
+const (
+	FlagFIN uint8 = 0x01
+	FlagSYN uint8 = 0x02
+	FlagRST uint8 = 0x04
+	FlagPSH uint8 = 0x08
+	FlagACK uint8 = 0x10
+)
+
+const (
+	etherTypeIPv4  = 0x0800
+	etherTypeIPv6  = 0x86dd
+	etherTypeVLAN  = 0x8100
+	etherTypeQinQ  = 0x88a8
+	ethernetLength = 14
+	sllLength      = 16
+	sll2Length     = 20
+)
+
+// ErrUnsupported is returned for the packets which aren't IP, e.g. ARP.
+var ErrUnsupported = errors.New("unsupported packet")
+
+// TCP holds the tcp header fields used to follow the connections.
+type TCP struct {
+	Seq    uint32
+	Ack    uint32
+	Flags  uint8
+	Window uint16
+}
+
+// Packet is a decoded IP packet. Payload holds the captured part of the transport payload, whose length is
+// PayloadLength.
+type Packet struct {
+	Timestamp     time.Time
+	Length        int
+	SrcIP         net.IP
+	DstIP         net.IP
+	Protocol      uint8
+	SrcPort       uint16
+	DstPort       uint16
+	TCP           *TCP
+	Payload       []byte
+	PayloadLength int
+}
+
+// Decode decodes the IP packet of a captured frame of the given link type.
+func Decode(captured *pcap.Packet) (*Packet, error) {
+	data := captured.Data
+	var etherType uint16
+
+	switch captured.LinkType {
+	case pcap.LinkTypeEthernet:
+		if len(data) < ethernetLength {
+			return nil, errors.New("truncated ethernet header")
+		}
+
+		etherType = binary.BigEndian.Uint16(data[12:14])
+		data = data[ethernetLength:]
+
+		for (etherType == etherTypeVLAN || etherType == etherTypeQinQ) && len(data) >= 4 {
+			etherType = binary.BigEndian.Uint16(data[2:4])
+			data = data[4:]
+		}
+
+	case pcap.LinkTypeLinuxSLL:
+		if len(data) < sllLength {
+			return nil, errors.New("truncated linux cooked header")
+		}
+
+		etherType = binary.BigEndian.Uint16(data[14:16])
+		data = data[sllLength:]
+
+	case pcap.LinkTypeLinuxSLL2:
+		if len(data) < sll2Length {
+			return nil, errors.New("truncated linux cooked v2 header")
+		}
+
+		etherType = binary.BigEndian.Uint16(data[0:2])
+		data = data[sll2Length:]
+
+	case pcap.LinkTypeRaw:
+		if len(data) == 0 {
+			return nil, errors.New("empty raw packet")
+		}
+
+		switch data[0] >> 4 {
+		case 4:
+			etherType = etherTypeIPv4
+		case 6:
+			etherType = etherTypeIPv6
+		}
+
+	default:
+		return nil, errors.Wrapf(ErrUnsupported, "link type: '%d'", captured.LinkType)
+	}
+
+	p := &Packet{Timestamp: captured.Timestamp, Length: int(captured.OriginalLength)}
+
+	var err error
+	switch etherType {
+	case etherTypeIPv4:
+		data, err = p.decodeIPv4(data)
+	case etherTypeIPv6:
+		data, err = p.decodeIPv6(data)
+	default:
+		return nil, errors.Wrapf(ErrUnsupported, "ether type: '0x%04x'", etherType)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return p, nil
+	}
+
+	switch p.Protocol {
+	case ProtocolTCP:
+		err = p.decodeTCP(data)
+	case ProtocolUDP:
+		err = p.decodeUDP(data)
+	default:
+		p.Payload = data
+	}
+
+	return p, err
+}
+
+// decodeIPv4 returns the transport data, which is nil for the fragments following the first one.
+func (p *Packet) decodeIPv4(data []byte) ([]byte, error) {
+	if len(data) < 20 {
+		return nil, errors.New("truncated ipv4 header")
+	}
+
+	headerLength := int(data[0]&0x0f) * 4
+	totalLength := int(binary.BigEndian.Uint16(data[2:4]))
+	if headerLength < 20 || len(data) < headerLength || totalLength < headerLength {
+		return nil, errors.New("invalid ipv4 header")
+	}
+
+	p.Protocol = data[9]
+	p.SrcIP = net.IP(data[12:16])
+	p.DstIP = net.IP(data[16:20])
+	p.PayloadLength = totalLength - headerLength
+
+	if binary.BigEndian.Uint16(data[6:8])&0x1fff != 0 {
+		return nil, nil
+	}
+
+	return truncate(data[headerLength:], p.PayloadLength), nil
+}
+
+func (p *Packet) decodeIPv6(data []byte) ([]byte, error) {
+	if len(data) < 40 {
+		return nil, errors.New("truncated ipv6 header")
+	}
+
+	nextHeader := data[6]
+	p.SrcIP = net.IP(data[8:24])
+	p.DstIP = net.IP(data[24:40])
+	p.PayloadLength = int(binary.BigEndian.Uint16(data[4:6]))
+	data = truncate(data[40:], p.PayloadLength)
+
+	for {
+		switch nextHeader {
+		case 0, 43, 60:
+			// hop by hop, routing and destination options headers
+			if len(data) < 8 {
+				return nil, errors.New("truncated ipv6 extension header")
+			}
+
+			length := (int(data[1]) + 1) * 8
+			if len(data) < length {
+				return nil, errors.New("truncated ipv6 extension header")
+			}
+
+			nextHeader = data[0]
+			data = data[length:]
+			p.PayloadLength -= length
+
+		case 44:
+			if len(data) < 8 {
+				return nil, errors.New("truncated ipv6 fragment header")
+			}
+
+			nextHeader = data[0]
+			p.Protocol = nextHeader
+			p.PayloadLength -= 8
+
+			if binary.BigEndian.Uint16(data[2:4])&0xfff8 != 0 {
+				return nil, nil
+			}
+			data = data[8:]
+
+		default:
+			p.Protocol = nextHeader
+			return data, nil
+		}
+	}
+}
+
+func (p *Packet) decodeTCP(data []byte) error {
+	if len(data) < 20 {
+		return errors.New("truncated tcp header")
+	}
+
+	headerLength := int(data[12]>>4) * 4
+	if headerLength < 20 || len(data) < headerLength {
+		return errors.New("invalid tcp header")
+	}
+
+	p.SrcPort = binary.BigEndian.Uint16(data[0:2])
+	p.DstPort = binary.BigEndian.Uint16(data[2:4])
+	p.TCP = &TCP{
+		Seq:    binary.BigEndian.Uint32(data[4:8]),
+		Ack:    binary.BigEndian.Uint32(data[8:12]),
+		Flags:  data[13],
+		Window: binary.BigEndian.Uint16(data[14:16]),
+	}
+	p.Payload = data[headerLength:]
+	p.PayloadLength -= headerLength
+
+	return nil
+}
+
+func (p *Packet) decodeUDP(data []byte) error {
+	if len(data) < 8 {
+		return errors.New("truncated udp header")
+	}
+
+	p.SrcPort = binary.BigEndian.Uint16(data[0:2])
+	p.DstPort = binary.BigEndian.Uint16(data[2:4])
+	p.Payload = data[8:]
+	p.PayloadLength -= 8
+
+	return nil
+}
+
+// truncate drops the link layer padding, e.g. of the ethernet frames shorter than 64 bytes.
+func truncate(data []byte, length int) []byte {
+	if length >= 0 && len(data) > length {
+		return data[:length]
+	}
+
+	return data
+}
+
+// ProtocolName returns the name of the IP protocol, e.g. 'TCP'.
+func ProtocolName(protocol uint8) string {
+	switch protocol {
+	case ProtocolTCP:
+		return "TCP"
+	case ProtocolUDP:
+		return "UDP"
+	case ProtocolICMP:
+		return "ICMP"
+	case ProtocolICMPv6:
+		return "ICMPv6"
+	default:
+		return fmt.Sprintf("IP(%d)", protocol)
+	}
+}
+
+// FlagNames returns the names of the tcp flags, e.g. 'SYN,ACK'.
+func FlagNames(flags uint8) string {
+	var names []string
+	for _, flag := range []struct {
+		flag uint8
+		name string
+	}{{FlagSYN, "SYN"}, {FlagFIN, "FIN"}, {FlagRST, "RST"}, {FlagPSH, "PSH"}, {FlagACK, "ACK"}} {
+		if flags&flag.flag != 0 {
+			names = append(names, flag.name)
+		}
+	}
+
+	return strings.Join(names, ",")
+}
+
+// Endpoint formats an address and port, e.g. '10.0.0.1:80' or '[fd00::1]:80'.
+func Endpoint(ip net.IP, port uint16) string {
+	return net.JoinHostPort(ip.String(), fmt.Sprint(port))
+}
+
+// Summary describes the packet on a single line.
+func (p *Packet) Summary() string {
+	if p.Protocol != ProtocolTCP && p.Protocol != ProtocolUDP {
+		return fmt.Sprintf("%s > %s %s length %d", p.SrcIP, p.DstIP, ProtocolName(p.Protocol), p.Length)
+	}
+
+	summary := fmt.Sprintf("%s > %s %s", Endpoint(p.SrcIP, p.SrcPort), Endpoint(p.DstIP, p.DstPort), ProtocolName(p.Protocol))
+	if p.TCP != nil {
+		summary += fmt.Sprintf(" [%s] seq %d ack %d win %d", FlagNames(p.TCP.Flags), p.TCP.Seq, p.TCP.Ack, p.TCP.Window)
+	}
+
+	return summary + fmt.Sprintf(" length %d", p.PayloadLength)
+}
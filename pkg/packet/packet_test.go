@@ -0,0 +1,113 @@
+package packet
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"ksniff/pkg/pcap"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func buildIPv4(protocol uint8, src string, dst string, transport []byte) []byte {
+	header := make([]byte, 20)
+	header[0] = 0x45
+	binary.BigEndian.PutUint16(header[2:4], uint16(20+len(transport)))
+	header[8] = 64
+	header[9] = protocol
+	copy(header[12:16], net.ParseIP(src).To4())
+	copy(header[16:20], net.ParseIP(dst).To4())
+
+	return append(header, transport...)
+}
+
+func buildTCP(srcPort uint16, dstPort uint16, seq uint32, flags uint8, payload []byte) []byte {
+	header := make([]byte, 20)
+	binary.BigEndian.PutUint16(header[0:2], srcPort)
+	binary.BigEndian.PutUint16(header[2:4], dstPort)
+	binary.BigEndian.PutUint32(header[4:8], seq)
+	header[12] = 5 << 4
+	header[13] = flags
+
+	return append(header, payload...)
+}
+
+func TestDecode_LinuxSLL2TCP(t *testing.T) {
+	// given
+	frame := make([]byte, sll2Length)
+	binary.BigEndian.PutUint16(frame[0:2], etherTypeIPv4)
+	frame = append(frame, buildIPv4(ProtocolTCP, "10.0.0.1", "10.0.0.2", buildTCP(40000, 80, 1000, FlagPSH|FlagACK, []byte("GET /")))...)
+
+	// when
+	p, err := Decode(&pcap.Packet{LinkType: pcap.LinkTypeLinuxSLL2, Timestamp: time.Unix(1, 0), Data: frame,
+		CaptureLength: uint32(len(frame)), OriginalLength: uint32(len(frame))})
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.1", p.SrcIP.String())
+	assert.Equal(t, "10.0.0.2", p.DstIP.String())
+	assert.Equal(t, uint16(40000), p.SrcPort)
+	assert.Equal(t, uint16(80), p.DstPort)
+	assert.Equal(t, uint32(1000), p.TCP.Seq)
+	assert.Equal(t, []byte("GET /"), p.Payload)
+	assert.Equal(t, 5, p.PayloadLength)
+	assert.Equal(t, "10.0.0.1:40000 > 10.0.0.2:80 TCP [PSH,ACK] seq 1000 ack 0 win 0 length 5", p.Summary())
+}
+
+func TestDecode_EthernetVLANUDPWithPadding(t *testing.T) {
+	// given
+	frame := make([]byte, ethernetLength+4)
+	binary.BigEndian.PutUint16(frame[12:14], etherTypeVLAN)
+	binary.BigEndian.PutUint16(frame[16:18], etherTypeIPv4)
+
+	udp := make([]byte, 8)
+	binary.BigEndian.PutUint16(udp[0:2], 5353)
+	binary.BigEndian.PutUint16(udp[2:4], 53)
+	frame = append(frame, buildIPv4(ProtocolUDP, "10.0.0.1", "10.0.0.10", append(udp, 1, 2))...)
+	// ethernet frames are padded to 64 bytes
+	frame = append(frame, make([]byte, 10)...)
+
+	// when
+	p, err := Decode(&pcap.Packet{LinkType: pcap.LinkTypeEthernet, Data: frame})
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, ProtocolUDP, p.Protocol)
+	assert.Equal(t, uint16(53), p.DstPort)
+	assert.Equal(t, []byte{1, 2}, p.Payload)
+}
+
+func TestDecode_RawIPv6(t *testing.T) {
+	// given
+	header := make([]byte, 40)
+	header[0] = 6 << 4
+	tcp := buildTCP(443, 50000, 1, FlagSYN|FlagACK, nil)
+	binary.BigEndian.PutUint16(header[4:6], uint16(len(tcp)))
+	header[6] = ProtocolTCP
+	copy(header[8:24], net.ParseIP("fd00::1"))
+	copy(header[24:40], net.ParseIP("fd00::2"))
+
+	// when
+	p, err := Decode(&pcap.Packet{LinkType: pcap.LinkTypeRaw, Data: append(header, tcp...)})
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, "fd00::1", p.SrcIP.String())
+	assert.Equal(t, FlagSYN|FlagACK, p.TCP.Flags)
+	assert.Equal(t, 0, p.PayloadLength)
+}
+
+func TestDecode_UnsupportedEtherType(t *testing.T) {
+	// given
+	frame := make([]byte, ethernetLength+28)
+	binary.BigEndian.PutUint16(frame[12:14], 0x0806)
+
+	// when
+	_, err := Decode(&pcap.Packet{LinkType: pcap.LinkTypeEthernet, Data: frame})
+
+	// then
+	assert.True(t, errors.Is(err, ErrUnsupported))
+}
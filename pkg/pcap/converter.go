@@ -0,0 +1,165 @@
+package pcap
+
+import (
+	"io"
+	"strings"
+	"sync"
+)
+
+// Converter converts consecutive pcap streams, e.g. of restarted tcpdump processes, to a single pcapng stream.
+type Converter struct {
+	writer      io.Writer
+	application string
+	// writeMutex serializes the secrets, written as soon as they're received, with the converted packets
+	writeMutex sync.Mutex
+	pcapng     *Writer
+	// secrets are pending until the section header is written
+	secrets  []secrets
+	mutex    sync.Mutex
+	comments []string
+}
+
+type secrets struct {
+	secretsType uint32
+	data        []byte
+}
+
+func NewConverter(w io.Writer, application string) *Converter {
+	return &Converter{writer: w, application: application}
+}
+
+// Comment attaches the comment to the next converted packet.
+func (c *Converter) Comment(comment string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.comments = append(c.comments, comment)
+}
+
+// Secrets writes the decryption secrets right away, or once the section header is written.
+func (c *Converter) Secrets(secretsType uint32, data []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	if c.pcapng == nil {
+		c.secrets = append(c.secrets, secrets{secretsType: secretsType, data: data})
+		return nil
+	}
+
+	return c.pcapng.WriteSecrets(secretsType, data)
+}
+
+// Flush writes the pending decryption secrets when no stream was converted, after a section header without any
+// interface.
+func (c *Converter) Flush() error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	if len(c.secrets) == 0 {
+		return nil
+	}
+
+	if err := c.startSection(); err != nil {
+		return err
+	}
+
+	return c.writePendingSecrets()
+}
+
+// startSection writes the section header of the pcapng stream once, the write lock must be held.
+func (c *Converter) startSection() error {
+	if c.pcapng != nil {
+		return nil
+	}
+
+	pcapng, err := NewWriter(c.writer, c.application)
+	if err != nil {
+		return err
+	}
+
+	c.pcapng = pcapng
+	return nil
+}
+
+// writePendingSecrets writes the decryption secrets received before the section header, the write lock must be held.
+func (c *Converter) writePendingSecrets() error {
+	for len(c.secrets) > 0 {
+		if err := c.pcapng.WriteSecrets(c.secrets[0].secretsType, c.secrets[0].data); err != nil {
+			return err
+		}
+		c.secrets = c.secrets[1:]
+	}
+
+	return nil
+}
+
+func (c *Converter) pendingComment() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	comment := strings.Join(c.comments, "\n")
+	c.comments = nil
+
+	return comment
+}
+
+// Convert appends the packets of the pcap stream to the pcapng stream until the end of the pcap stream.
+func (c *Converter) Convert(r io.Reader) error {
+	reader, err := NewReader(r)
+	if err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+
+	interfaceIndex, err := c.addInterface(reader.Header)
+	if err != nil {
+		return err
+	}
+
+	for {
+		packet, err := reader.ReadPacket()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := c.writePacket(interfaceIndex, packet); err != nil {
+			return err
+		}
+	}
+}
+
+// addInterface starts the section when needed, and returns the interface of the pcap stream, added when it's new.
+func (c *Converter) addInterface(header Header) (int, error) {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	if err := c.startSection(); err != nil {
+		return 0, err
+	}
+
+	captureInterface := Interface{LinkType: header.LinkType, SnapLength: header.SnapLength,
+		Nanoseconds: header.Nanoseconds}
+
+	interfaceIndex := c.pcapng.InterfaceIndex(captureInterface)
+	if interfaceIndex < 0 {
+		var err error
+		interfaceIndex, err = c.pcapng.AddInterface(captureInterface)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return interfaceIndex, c.writePendingSecrets()
+}
+
+func (c *Converter) writePacket(interfaceIndex int, packet *Packet) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	return c.pcapng.WritePacket(interfaceIndex, packet, c.pendingComment())
+}
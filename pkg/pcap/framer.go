@@ -0,0 +1,131 @@
+package pcap
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// pcapng block types of captured packets, every other block describes the section.
+const (
+	blockTypeObsoletePacket = 0x00000002
+	blockTypeSimplePacket   = 0x00000003
+
+	// maxBlockLength protects against corrupted streams, packet blocks are at most a snapshot plus their options.
+	maxBlockLength = 16 << 20
+)
+
+// Frame is a complete part of a pcap or pcapng stream. Header frames (the pcap global header, and the pcapng
+// section header, interface description and other non packet blocks) are required to read the packet frames.
+type Frame struct {
+	Data   []byte
+	Header bool
+	// Start is set on the first frame of a pcap stream or a pcapng section, the previous header frames don't apply
+	// to the following frames.
+	Start bool
+}
+
+// Framer splits a pcap or pcapng stream written in arbitrary chunks into frames, so consumers joining a live
+// stream can start at a frame boundary.
+type Framer struct {
+	buffer    []byte
+	pcapng    bool
+	started   bool
+	byteOrder binary.ByteOrder
+	onFrame   func(Frame)
+}
+
+func NewFramer(onFrame func(Frame)) *Framer {
+	return &Framer{onFrame: onFrame}
+}
+
+func (f *Framer) Write(p []byte) (int, error) {
+	f.buffer = append(f.buffer, p...)
+
+	for {
+		length, frame, err := f.nextFrame()
+		if err != nil {
+			return 0, err
+		}
+
+		if length == 0 || len(f.buffer) < length {
+			return len(p), nil
+		}
+
+		frame.Data = make([]byte, length)
+		copy(frame.Data, f.buffer)
+		f.buffer = f.buffer[length:]
+
+		f.onFrame(frame)
+	}
+}
+
+// nextFrame returns the length and kind of the frame at the beginning of the buffer, or 0 when more data is needed.
+func (f *Framer) nextFrame() (int, Frame, error) {
+	if len(f.buffer) < 12 {
+		return 0, Frame{}, nil
+	}
+
+	// a pcapng stream starts a new section with its own byte order
+	sectionStart := false
+	if (!f.started || f.pcapng) && binary.LittleEndian.Uint32(f.buffer[0:4]) == blockTypeSectionHeader {
+		f.pcapng, f.started = true, true
+
+		switch binary.LittleEndian.Uint32(f.buffer[8:12]) {
+		case byteOrderMagic:
+			f.byteOrder = binary.LittleEndian
+		default:
+			f.byteOrder = binary.BigEndian
+		}
+
+		sectionStart = true
+	}
+
+	if !f.started {
+		return f.pcapHeaderLength()
+	}
+
+	if f.pcapng {
+		length := int(f.byteOrder.Uint32(f.buffer[4:8]))
+		if length < 12 || length%4 != 0 || length > maxBlockLength {
+			return 0, Frame{}, errors.Errorf("invalid pcapng block length: '%d'", length)
+		}
+
+		switch f.byteOrder.Uint32(f.buffer[0:4]) {
+		case blockTypeSectionHeader:
+			return length, Frame{Header: true, Start: sectionStart}, nil
+		case blockTypeEnhancedPacket, blockTypeSimplePacket, blockTypeObsoletePacket:
+			return length, Frame{}, nil
+		default:
+			return length, Frame{Header: true}, nil
+		}
+	}
+
+	if len(f.buffer) < recordHeaderLength {
+		return 0, Frame{}, nil
+	}
+
+	captureLength := f.byteOrder.Uint32(f.buffer[8:12])
+	if captureLength > maxPacketLength {
+		return 0, Frame{}, errors.Errorf("invalid pcap packet length: '%d'", captureLength)
+	}
+
+	return recordHeaderLength + int(captureLength), Frame{}, nil
+}
+
+func (f *Framer) pcapHeaderLength() (int, Frame, error) {
+	for _, byteOrder := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch byteOrder.Uint32(f.buffer[0:4]) {
+		case magicMicroseconds, magicNanoseconds:
+			// the records are read once the header is complete
+			if len(f.buffer) < globalHeaderLength {
+				return 0, Frame{}, nil
+			}
+
+			f.byteOrder, f.started = byteOrder, true
+			return globalHeaderLength, Frame{Header: true, Start: true}, nil
+		}
+	}
+
+	return 0, Frame{}, errors.Errorf("invalid capture stream, neither pcap nor pcapng: '%x'", f.buffer[0:4])
+}
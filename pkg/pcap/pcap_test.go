@@ -0,0 +1,279 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// buildPcap builds a little endian microseconds pcap stream of the given packets.
+func buildPcap(linkType uint32, packets ...[]byte) []byte {
+	buffer := new(bytes.Buffer)
+	_ = binary.Write(buffer, binary.LittleEndian, []uint32{magicMicroseconds, 0x00040002, 0, 0, 262144, linkType})
+
+	for i, packet := range packets {
+		_ = binary.Write(buffer, binary.LittleEndian, []uint32{1700000000, uint32(i), uint32(len(packet)), uint32(len(packet))})
+		buffer.Write(packet)
+	}
+
+	return buffer.Bytes()
+}
+
+type block struct {
+	blockType uint32
+	body      []byte
+}
+
+func readBlocks(t *testing.T, data []byte) []block {
+	var blocks []block
+
+	for len(data) > 0 {
+		blockType := binary.LittleEndian.Uint32(data[0:4])
+		length := binary.LittleEndian.Uint32(data[4:8])
+		assert.Equal(t, length, binary.LittleEndian.Uint32(data[length-4:length]))
+		assert.Equal(t, uint32(0), length%4)
+
+		blocks = append(blocks, block{blockType: blockType, body: data[8 : length-4]})
+		data = data[length:]
+	}
+
+	return blocks
+}
+
+func TestReader_ReadPackets(t *testing.T) {
+	// given
+	stream := buildPcap(LinkTypeLinuxSLL2, []byte{1, 2, 3}, []byte{4, 5})
+
+	// when
+	reader, err := NewReader(bytes.NewReader(stream))
+	assert.Nil(t, err)
+	first, firstErr := reader.ReadPacket()
+	second, secondErr := reader.ReadPacket()
+	_, endErr := reader.ReadPacket()
+
+	// then
+	assert.Equal(t, Header{LinkType: LinkTypeLinuxSLL2, SnapLength: 262144}, reader.Header)
+	assert.Nil(t, firstErr)
+	assert.Equal(t, []byte{1, 2, 3}, first.Data)
+	assert.Equal(t, time.Unix(1700000000, 0), first.Timestamp)
+	assert.Nil(t, secondErr)
+	assert.Equal(t, time.Unix(1700000000, 1000), second.Timestamp)
+	assert.Equal(t, io.EOF, endErr)
+}
+
+func TestReader_BigEndianNanoseconds(t *testing.T) {
+	// given
+	buffer := new(bytes.Buffer)
+	_ = binary.Write(buffer, binary.BigEndian, []uint32{magicNanoseconds, 0x00020004, 0, 0, 65535, LinkTypeEthernet})
+	_ = binary.Write(buffer, binary.BigEndian, []uint32{1700000000, 5, 1, 60})
+	buffer.WriteByte(0xff)
+
+	// when
+	reader, err := NewReader(buffer)
+	assert.Nil(t, err)
+	packet, packetErr := reader.ReadPacket()
+
+	// then
+	assert.True(t, reader.Header.Nanoseconds)
+	assert.Nil(t, packetErr)
+	assert.Equal(t, time.Unix(1700000000, 5), packet.Timestamp)
+	assert.Equal(t, uint32(60), packet.OriginalLength)
+}
+
+func TestReader_InvalidMagic(t *testing.T) {
+	// when
+	_, err := NewReader(bytes.NewReader(make([]byte, globalHeaderLength)))
+
+	// then
+	assert.NotNil(t, err)
+}
+
+func TestConverter_ConsecutiveStreams(t *testing.T) {
+	// given
+	output := new(bytes.Buffer)
+	converter := NewConverter(output, "ksniff")
+
+	// when
+	assert.Nil(t, converter.Convert(bytes.NewReader(buildPcap(LinkTypeLinuxSLL2, []byte{1}))))
+	converter.Comment("capture filter changed")
+	assert.Nil(t, converter.Convert(bytes.NewReader(buildPcap(LinkTypeLinuxSLL2, []byte{2}, []byte{3}))))
+	assert.Nil(t, converter.Convert(bytes.NewReader(buildPcap(LinkTypeEthernet, []byte{4}))))
+
+	// then
+	blocks := readBlocks(t, output.Bytes())
+	var blockTypes []uint32
+	for _, b := range blocks {
+		blockTypes = append(blockTypes, b.blockType)
+	}
+
+	assert.Equal(t, []uint32{blockTypeSectionHeader, blockTypeInterfaceDescription, blockTypeEnhancedPacket,
+		blockTypeEnhancedPacket, blockTypeEnhancedPacket, blockTypeInterfaceDescription, blockTypeEnhancedPacket}, blockTypes)
+
+	// the first packet after the restart carries the comment, the ethernet packet uses the second interface
+	assert.Contains(t, string(blocks[3].body), "capture filter changed")
+	assert.NotContains(t, string(blocks[4].body), "capture filter changed")
+	assert.Equal(t, uint32(1), binary.LittleEndian.Uint32(blocks[6].body[0:4]))
+}
+
+func TestConverter_Secrets(t *testing.T) {
+	// given
+	output := new(bytes.Buffer)
+	converter := NewConverter(output, "ksniff")
+	keyLog := "CLIENT_RANDOM 0102 0304\n"
+
+	// when
+	assert.Nil(t, converter.Secrets(SecretsTypeTLSKeyLog, []byte(keyLog)))
+	assert.Nil(t, converter.Convert(bytes.NewReader(buildPcap(LinkTypeLinuxSLL2, []byte{1}, []byte{2}))))
+
+	// then
+	blocks := readBlocks(t, output.Bytes())
+	assert.Equal(t, 5, len(blocks))
+
+	// the secrets are written once, before the first packet
+	secrets := blocks[2]
+	assert.Equal(t, uint32(blockTypeDecryptionSecrets), secrets.blockType)
+	assert.Equal(t, SecretsTypeTLSKeyLog, binary.LittleEndian.Uint32(secrets.body[0:4]))
+	assert.Equal(t, uint32(len(keyLog)), binary.LittleEndian.Uint32(secrets.body[4:8]))
+	assert.Equal(t, keyLog, string(secrets.body[8:8+len(keyLog)]))
+	assert.Equal(t, uint32(blockTypeEnhancedPacket), blocks[3].blockType)
+	assert.Equal(t, uint32(blockTypeEnhancedPacket), blocks[4].blockType)
+}
+
+func TestConverter_SecretsAfterLastPacket(t *testing.T) {
+	// given
+	output := new(bytes.Buffer)
+	converter := NewConverter(output, "ksniff")
+	keyLog := "CLIENT_RANDOM 0102 0304\n"
+	assert.Nil(t, converter.Convert(bytes.NewReader(buildPcap(LinkTypeLinuxSLL2, []byte{1}))))
+
+	// when
+	err := converter.Secrets(SecretsTypeTLSKeyLog, []byte(keyLog))
+
+	// then
+	assert.Nil(t, err)
+	blocks := readBlocks(t, output.Bytes())
+	assert.Equal(t, 4, len(blocks))
+	assert.Equal(t, uint32(blockTypeEnhancedPacket), blocks[2].blockType)
+	assert.Equal(t, uint32(blockTypeDecryptionSecrets), blocks[3].blockType)
+	assert.Equal(t, keyLog, string(blocks[3].body[8:8+len(keyLog)]))
+}
+
+func TestConverter_FlushWithoutStream(t *testing.T) {
+	// given
+	output := new(bytes.Buffer)
+	converter := NewConverter(output, "ksniff")
+	assert.Nil(t, converter.Secrets(SecretsTypeTLSKeyLog, []byte("CLIENT_RANDOM 0102 0304\n")))
+	assert.Nil(t, converter.Convert(bytes.NewReader(nil)))
+
+	// when
+	err := converter.Flush()
+
+	// then
+	assert.Nil(t, err)
+	blocks := readBlocks(t, output.Bytes())
+	assert.Equal(t, 2, len(blocks))
+	assert.Equal(t, uint32(blockTypeSectionHeader), blocks[0].blockType)
+	assert.Equal(t, uint32(blockTypeDecryptionSecrets), blocks[1].blockType)
+}
+
+func TestFramer_SplitsChunkedPcap(t *testing.T) {
+	// given
+	stream := buildPcap(LinkTypeLinuxSLL2, []byte{1, 2, 3}, []byte{4, 5})
+	var frames []Frame
+	framer := NewFramer(func(frame Frame) {
+		frames = append(frames, frame)
+	})
+
+	// when
+	for _, b := range stream {
+		_, err := framer.Write([]byte{b})
+		assert.Nil(t, err)
+	}
+
+	// then
+	assert.Equal(t, 3, len(frames))
+	assert.True(t, frames[0].Header)
+	assert.True(t, frames[0].Start)
+	assert.Equal(t, 24, len(frames[0].Data))
+	assert.False(t, frames[1].Header)
+	assert.Equal(t, 19, len(frames[1].Data))
+	assert.Equal(t, 18, len(frames[2].Data))
+}
+
+func TestFramer_SplitsPcapng(t *testing.T) {
+	// given
+	output := new(bytes.Buffer)
+	converter := NewConverter(output, "ksniff")
+	assert.Nil(t, converter.Convert(bytes.NewReader(buildPcap(LinkTypeLinuxSLL2, []byte{1}, []byte{2}))))
+
+	var frames []Frame
+	framer := NewFramer(func(frame Frame) {
+		frames = append(frames, frame)
+	})
+
+	// when
+	_, err := framer.Write(output.Bytes())
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(frames))
+	assert.Equal(t, []bool{true, true, false, false}, []bool{frames[0].Header, frames[1].Header, frames[2].Header, frames[3].Header})
+	assert.Equal(t, []bool{true, false, false, false}, []bool{frames[0].Start, frames[1].Start, frames[2].Start, frames[3].Start})
+}
+
+func TestFramer_InvalidStream(t *testing.T) {
+	// given
+	framer := NewFramer(func(frame Frame) {})
+
+	// when
+	_, err := framer.Write(bytes.Repeat([]byte{0xff}, 24))
+
+	// then
+	assert.NotNil(t, err)
+}
+
+func TestOpenStream_Pcapng(t *testing.T) {
+	// given
+	output := new(bytes.Buffer)
+	converter := NewConverter(output, "ksniff")
+	converter.Comment("capture filter changed")
+	assert.Nil(t, converter.Convert(bytes.NewReader(buildPcap(LinkTypeLinuxSLL2, []byte{1, 2, 3}))))
+	assert.Nil(t, converter.Convert(bytes.NewReader(buildPcap(LinkTypeEthernet, []byte{4}))))
+
+	// when
+	reader, err := OpenStream(output)
+	assert.Nil(t, err)
+
+	first, err := reader.ReadPacket()
+	assert.Nil(t, err)
+	second, err := reader.ReadPacket()
+	assert.Nil(t, err)
+	_, err = reader.ReadPacket()
+
+	// then
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, LinkTypeLinuxSLL2, first.LinkType)
+	assert.Equal(t, []byte{1, 2, 3}, first.Data)
+	assert.Equal(t, time.Unix(1700000000, 0), first.Timestamp)
+	assert.Equal(t, LinkTypeEthernet, second.LinkType)
+	assert.Equal(t, []byte{4}, second.Data)
+}
+
+func TestOpenStream_Pcap(t *testing.T) {
+	// given
+	stream := bytes.NewReader(buildPcap(LinkTypeEthernet, []byte{1}))
+
+	// when
+	reader, err := OpenStream(stream)
+	assert.Nil(t, err)
+	packet, err := reader.ReadPacket()
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, LinkTypeEthernet, packet.LinkType)
+	assert.Equal(t, []byte{1}, packet.Data)
+}
@@ -0,0 +1,173 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// pcapng block types and options, see https://www.ietf.org/archive/id/draft-ietf-opsawg-pcapng.
+const (
+	blockTypeSectionHeader         = 0x0a0d0d0a
+	blockTypeInterfaceDescription  = 0x00000001
+	blockTypeEnhancedPacket        = 0x00000006
+	blockTypeDecryptionSecrets     = 0x0000000a
+	byteOrderMagic                 = 0x1a2b3c4d
+	optionEndOfOptions             = 0
+	optionComment                  = 1
+	optionSectionUserApplication   = 4
+	optionInterfaceName            = 2
+	optionInterfaceTimestampFormat = 9
+
+	timestampResolutionMicroseconds = 6
+	timestampResolutionNanoseconds  = 9
+)
+
+// SecretsTypeTLSKeyLog is the secrets type of the NSS key log files, e.g. written by the applications using the
+// SSLKEYLOGFILE environment variable.
+const SecretsTypeTLSKeyLog uint32 = 0x544c534b
+
+var pcapngByteOrder = binary.LittleEndian
+
+type option struct {
+	code  uint16
+	value []byte
+}
+
+func padding(length int) int {
+	return (4 - length%4) % 4
+}
+
+func writeOptions(buffer *bytes.Buffer, options []option) {
+	if len(options) == 0 {
+		return
+	}
+
+	for _, o := range options {
+		_ = binary.Write(buffer, pcapngByteOrder, o.code)
+		_ = binary.Write(buffer, pcapngByteOrder, uint16(len(o.value)))
+		buffer.Write(o.value)
+		buffer.Write(make([]byte, padding(len(o.value))))
+	}
+
+	_ = binary.Write(buffer, pcapngByteOrder, uint32(optionEndOfOptions))
+}
+
+// writeBlock writes a block with its body padded to 32 bits and its total length before and after the body.
+func writeBlock(w io.Writer, blockType uint32, body []byte) error {
+	length := uint32(12 + len(body) + padding(len(body)))
+
+	buffer := bytes.NewBuffer(make([]byte, 0, length))
+	_ = binary.Write(buffer, pcapngByteOrder, blockType)
+	_ = binary.Write(buffer, pcapngByteOrder, length)
+	buffer.Write(body)
+	buffer.Write(make([]byte, padding(len(body))))
+	_ = binary.Write(buffer, pcapngByteOrder, length)
+
+	_, err := w.Write(buffer.Bytes())
+	return err
+}
+
+// Interface describes the interface of a pcapng section, packets reference their interface by its index.
+type Interface struct {
+	Name        string
+	LinkType    uint32
+	SnapLength  uint32
+	Nanoseconds bool
+}
+
+// Writer writes a single pcapng section.
+type Writer struct {
+	writer     io.Writer
+	interfaces []Interface
+}
+
+// NewWriter writes the pcapng section header.
+func NewWriter(w io.Writer, application string) (*Writer, error) {
+	body := new(bytes.Buffer)
+	_ = binary.Write(body, pcapngByteOrder, uint32(byteOrderMagic))
+	_ = binary.Write(body, pcapngByteOrder, uint16(1))
+	_ = binary.Write(body, pcapngByteOrder, uint16(0))
+	// the section length is unknown when streaming
+	_ = binary.Write(body, pcapngByteOrder, int64(-1))
+	writeOptions(body, []option{{code: optionSectionUserApplication, value: []byte(application)}})
+
+	if err := writeBlock(w, blockTypeSectionHeader, body.Bytes()); err != nil {
+		return nil, err
+	}
+
+	return &Writer{writer: w}, nil
+}
+
+// InterfaceIndex returns the index of a previously added interface matching the given one, or -1.
+func (w *Writer) InterfaceIndex(i Interface) int {
+	for index, existing := range w.interfaces {
+		if existing == i {
+			return index
+		}
+	}
+
+	return -1
+}
+
+// AddInterface writes an interface description block and returns the interface index.
+func (w *Writer) AddInterface(i Interface) (int, error) {
+	timestampResolution := byte(timestampResolutionMicroseconds)
+	if i.Nanoseconds {
+		timestampResolution = timestampResolutionNanoseconds
+	}
+
+	options := []option{{code: optionInterfaceTimestampFormat, value: []byte{timestampResolution}}}
+	if i.Name != "" {
+		options = append(options, option{code: optionInterfaceName, value: []byte(i.Name)})
+	}
+
+	body := new(bytes.Buffer)
+	_ = binary.Write(body, pcapngByteOrder, uint16(i.LinkType))
+	_ = binary.Write(body, pcapngByteOrder, uint16(0))
+	_ = binary.Write(body, pcapngByteOrder, i.SnapLength)
+	writeOptions(body, options)
+
+	if err := writeBlock(w.writer, blockTypeInterfaceDescription, body.Bytes()); err != nil {
+		return 0, err
+	}
+
+	w.interfaces = append(w.interfaces, i)
+
+	return len(w.interfaces) - 1, nil
+}
+
+// WritePacket writes an enhanced packet block, the comment is shown by wireshark as a packet comment.
+func (w *Writer) WritePacket(interfaceIndex int, packet *Packet, comment string) error {
+	var timestamp uint64
+	if w.interfaces[interfaceIndex].Nanoseconds {
+		timestamp = uint64(packet.Timestamp.UnixNano())
+	} else {
+		timestamp = uint64(packet.Timestamp.UnixNano() / 1000)
+	}
+
+	body := bytes.NewBuffer(make([]byte, 0, 20+len(packet.Data)+len(comment)+16))
+	_ = binary.Write(body, pcapngByteOrder, uint32(interfaceIndex))
+	_ = binary.Write(body, pcapngByteOrder, uint32(timestamp>>32))
+	_ = binary.Write(body, pcapngByteOrder, uint32(timestamp))
+	_ = binary.Write(body, pcapngByteOrder, packet.CaptureLength)
+	_ = binary.Write(body, pcapngByteOrder, packet.OriginalLength)
+	body.Write(packet.Data)
+	body.Write(make([]byte, padding(len(packet.Data))))
+
+	if comment != "" {
+		writeOptions(body, []option{{code: optionComment, value: []byte(comment)}})
+	}
+
+	return writeBlock(w.writer, blockTypeEnhancedPacket, body.Bytes())
+}
+
+// WriteSecrets writes a decryption secrets block, wireshark uses the secrets to decrypt the following packets.
+func (w *Writer) WriteSecrets(secretsType uint32, secrets []byte) error {
+	body := bytes.NewBuffer(make([]byte, 0, 8+len(secrets)+4))
+	_ = binary.Write(body, pcapngByteOrder, secretsType)
+	_ = binary.Write(body, pcapngByteOrder, uint32(len(secrets)))
+	body.Write(secrets)
+
+	return writeBlock(w.writer, blockTypeDecryptionSecrets, body.Bytes())
+}
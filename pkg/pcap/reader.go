@@ -0,0 +1,123 @@
+// Package pcap reads the pcap stream written by tcpdump and writes pcapng streams.
+package pcap
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	magicMicroseconds = 0xa1b2c3d4
+	magicNanoseconds  = 0xa1b23c4d
+
+	globalHeaderLength = 24
+	recordHeaderLength = 16
+
+	// maxPacketLength protects against corrupted streams, tcpdump snapshots are at most 262144 bytes.
+	maxPacketLength = 1 << 20
+)
+
+// Link types of the captured packets, see https://www.tcpdump.org/linktypes.html.
+const (
+	LinkTypeEthernet  uint32 = 1
+	LinkTypeRaw       uint32 = 101
+	LinkTypeLinuxSLL  uint32 = 113
+	LinkTypeLinuxSLL2 uint32 = 276
+)
+
+// Header is the pcap global header.
+type Header struct {
+	LinkType    uint32
+	SnapLength  uint32
+	Nanoseconds bool
+}
+
+// Packet is a captured packet, Data holds CaptureLength bytes of the OriginalLength bytes packet.
+type Packet struct {
+	LinkType       uint32
+	Timestamp      time.Time
+	CaptureLength  uint32
+	OriginalLength uint32
+	Data           []byte
+}
+
+// Reader reads the packets of a pcap stream.
+type Reader struct {
+	reader    io.Reader
+	byteOrder binary.ByteOrder
+	Header    Header
+}
+
+// NewReader reads the pcap global header, the stream may use either byte order.
+func NewReader(r io.Reader) (*Reader, error) {
+	var header [globalHeaderLength]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	reader := &Reader{reader: r}
+
+	for _, byteOrder := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch byteOrder.Uint32(header[0:4]) {
+		case magicMicroseconds:
+			reader.byteOrder = byteOrder
+		case magicNanoseconds:
+			reader.byteOrder = byteOrder
+			reader.Header.Nanoseconds = true
+		}
+
+		if reader.byteOrder != nil {
+			break
+		}
+	}
+
+	if reader.byteOrder == nil {
+		return nil, errors.Errorf("invalid pcap magic number: '%x'", header[0:4])
+	}
+
+	reader.Header.SnapLength = reader.byteOrder.Uint32(header[16:20])
+	reader.Header.LinkType = reader.byteOrder.Uint32(header[20:24]) & 0x0fffffff
+
+	return reader, nil
+}
+
+// ReadPacket reads the next packet, io.EOF is returned at the end of the stream.
+func (r *Reader) ReadPacket() (*Packet, error) {
+	var header [recordHeaderLength]byte
+	if _, err := io.ReadFull(r.reader, header[:]); err != nil {
+		return nil, err
+	}
+
+	seconds := r.byteOrder.Uint32(header[0:4])
+	fraction := r.byteOrder.Uint32(header[4:8])
+	captureLength := r.byteOrder.Uint32(header[8:12])
+	originalLength := r.byteOrder.Uint32(header[12:16])
+
+	if captureLength > maxPacketLength {
+		return nil, errors.Errorf("invalid pcap packet length: '%d'", captureLength)
+	}
+
+	data := make([]byte, captureLength)
+	if _, err := io.ReadFull(r.reader, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	nanoseconds := int64(fraction)
+	if !r.Header.Nanoseconds {
+		nanoseconds *= int64(time.Microsecond)
+	}
+
+	return &Packet{
+		LinkType:       r.Header.LinkType,
+		Timestamp:      time.Unix(int64(seconds), nanoseconds),
+		CaptureLength:  captureLength,
+		OriginalLength: originalLength,
+		Data:           data,
+	}, nil
+}
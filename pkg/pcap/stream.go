@@ -0,0 +1,199 @@
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// PacketReader reads the packets of a capture stream, io.EOF is returned at the end of the stream.
+type PacketReader interface {
+	ReadPacket() (*Packet, error)
+}
+
+// OpenStream returns a reader of the pcap or pcapng stream, e.g. the output of the filter refresh.
+func OpenStream(r io.Reader) (PacketReader, error) {
+	buffered := bufio.NewReader(r)
+
+	magic, err := buffered.Peek(4)
+	if err != nil {
+		return nil, err
+	}
+
+	if binary.LittleEndian.Uint32(magic) == blockTypeSectionHeader {
+		return NewNgReader(buffered), nil
+	}
+
+	return NewReader(buffered)
+}
+
+type ngInterface struct {
+	linkType uint32
+	// unitsPerSecond is the resolution of the packet timestamps
+	unitsPerSecond uint64
+}
+
+// NgReader reads the packets of a pcapng stream, the blocks other than the packets and their interfaces are skipped.
+type NgReader struct {
+	reader     io.Reader
+	byteOrder  binary.ByteOrder
+	interfaces []ngInterface
+}
+
+func NewNgReader(r io.Reader) *NgReader {
+	return &NgReader{reader: r}
+}
+
+func (r *NgReader) readBlock() (uint32, []byte, error) {
+	var header [12]byte
+	if _, err := io.ReadFull(r.reader, header[:8]); err != nil {
+		return 0, nil, err
+	}
+
+	// each section starts with its byte order
+	if binary.LittleEndian.Uint32(header[0:4]) == blockTypeSectionHeader {
+		if _, err := io.ReadFull(r.reader, header[8:12]); err != nil {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+
+		r.byteOrder = binary.BigEndian
+		if binary.LittleEndian.Uint32(header[8:12]) == byteOrderMagic {
+			r.byteOrder = binary.LittleEndian
+		}
+	} else if r.byteOrder == nil {
+		return 0, nil, errors.Errorf("invalid pcapng stream, missing section header: '%x'", header[0:4])
+	}
+
+	blockType := r.byteOrder.Uint32(header[0:4])
+	length := r.byteOrder.Uint32(header[4:8])
+	if length < 12 || length%4 != 0 || length > maxBlockLength {
+		return 0, nil, errors.Errorf("invalid pcapng block length: '%d'", length)
+	}
+
+	read := 8
+	if blockType == blockTypeSectionHeader {
+		read = 12
+	}
+
+	body := make([]byte, int(length)-read)
+	if _, err := io.ReadFull(r.reader, body); err != nil {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+
+	if blockType == blockTypeSectionHeader {
+		body = append(header[8:12:12], body...)
+	}
+
+	// drops the trailing block length
+	return blockType, body[:len(body)-4], nil
+}
+
+func (r *NgReader) addInterface(body []byte) error {
+	if len(body) < 8 {
+		return errors.New("invalid pcapng interface description block")
+	}
+
+	i := ngInterface{linkType: uint32(r.byteOrder.Uint16(body[0:2])), unitsPerSecond: 1000000}
+
+	options := body[8:]
+	for len(options) >= 4 {
+		code := r.byteOrder.Uint16(options[0:2])
+		length := int(r.byteOrder.Uint16(options[2:4]))
+		if code == optionEndOfOptions || len(options) < 4+length {
+			break
+		}
+
+		if code == optionInterfaceTimestampFormat && length == 1 {
+			resolution := options[4]
+			i.unitsPerSecond = 1
+			for exponent := 0; exponent < int(resolution&0x7f) && exponent < 63; exponent++ {
+				if resolution&0x80 != 0 {
+					i.unitsPerSecond *= 2
+				} else {
+					i.unitsPerSecond *= 10
+				}
+			}
+		}
+
+		options = options[4+length+padding(length):]
+	}
+
+	r.interfaces = append(r.interfaces, i)
+
+	return nil
+}
+
+func (i *ngInterface) timestamp(units uint64) time.Time {
+	seconds := units / i.unitsPerSecond
+	fraction := units % i.unitsPerSecond
+
+	return time.Unix(int64(seconds), int64(float64(fraction)*1e9/float64(i.unitsPerSecond)))
+}
+
+// ReadPacket reads the next enhanced or simple packet block.
+func (r *NgReader) ReadPacket() (*Packet, error) {
+	for {
+		blockType, body, err := r.readBlock()
+		if err != nil {
+			return nil, err
+		}
+
+		switch blockType {
+		case blockTypeSectionHeader:
+			r.interfaces = nil
+
+		case blockTypeInterfaceDescription:
+			if err := r.addInterface(body); err != nil {
+				return nil, err
+			}
+
+		case blockTypeEnhancedPacket:
+			if len(body) < 20 {
+				return nil, errors.New("invalid pcapng enhanced packet block")
+			}
+
+			interfaceIndex := r.byteOrder.Uint32(body[0:4])
+			if int(interfaceIndex) >= len(r.interfaces) {
+				return nil, errors.Errorf("invalid pcapng interface: '%d'", interfaceIndex)
+			}
+
+			captureLength := r.byteOrder.Uint32(body[12:16])
+			if int(captureLength) > len(body)-20 {
+				return nil, errors.Errorf("invalid pcapng packet length: '%d'", captureLength)
+			}
+
+			i := r.interfaces[interfaceIndex]
+			units := uint64(r.byteOrder.Uint32(body[4:8]))<<32 | uint64(r.byteOrder.Uint32(body[8:12]))
+
+			return &Packet{
+				LinkType:       i.linkType,
+				Timestamp:      i.timestamp(units),
+				CaptureLength:  captureLength,
+				OriginalLength: r.byteOrder.Uint32(body[16:20]),
+				Data:           body[20 : 20+captureLength],
+			}, nil
+
+		case blockTypeSimplePacket:
+			if len(body) < 4 || len(r.interfaces) == 0 {
+				return nil, errors.New("invalid pcapng simple packet block")
+			}
+
+			// the simple packets have no timestamp, and are padded to 32 bits
+			originalLength := r.byteOrder.Uint32(body[0:4])
+			data := body[4:]
+			if int(originalLength) < len(data) {
+				data = data[:originalLength]
+			}
+
+			return &Packet{
+				LinkType:       r.interfaces[0].linkType,
+				CaptureLength:  uint32(len(data)),
+				OriginalLength: originalLength,
+				Data:           data,
+			}, nil
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WriteText writes the summary for the terminal.
+func WriteText(w io.Writer, s *Summary) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "capture summary: %d packets, %d bytes, %s\n", s.Packets, s.Bytes,
+		s.End.Sub(s.Start).Round(time.Millisecond))
+
+	if len(s.TopTalkers) > 0 {
+		fmt.Fprintln(tw, "\ntop talkers:")
+		fmt.Fprintln(tw, "  ADDRESS\tNAME\tPACKETS\tBYTES")
+		for _, talker := range s.TopTalkers {
+			fmt.Fprintf(tw, "  %s\t%s\t%d\t%d\n", talker.Address, orDash(talker.Name), talker.Packets, talker.Bytes)
+		}
+	}
+
+	fmt.Fprintf(tw, "\ntcp connections: %d opened, %d closed, %d reset\n", s.Connections.Opened,
+		s.Connections.Closed, s.Connections.Reset)
+
+	if len(s.UnansweredSyns) > 0 {
+		fmt.Fprintln(tw, "\nSYNs without SYN-ACK:")
+		fmt.Fprintln(tw, "  CLIENT\tSERVER\tATTEMPTS\tREFUSED")
+		for _, syn := range s.UnansweredSyns {
+			fmt.Fprintf(tw, "  %s\t%s\t%d\t%t\n", syn.Client, syn.Server, syn.Attempts, syn.Refused)
+		}
+	}
+
+	if len(s.DNSFailures) > 0 {
+		fmt.Fprintln(tw, "\ndns failures:")
+		fmt.Fprintln(tw, "  SERVER\tNAME\tTYPE\tRCODE\tCOUNT")
+		for _, failure := range s.DNSFailures {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%d\n", failure.Server, failure.Name, failure.Type, failure.Rcode,
+				failure.Count)
+		}
+	}
+
+	if len(s.TLSHandshakes) > 0 {
+		fmt.Fprintln(tw, "\ntls handshakes:")
+		fmt.Fprintln(tw, "  SERVER\tSERVER NAME\tCOUNT")
+		for _, handshake := range s.TLSHandshakes {
+			fmt.Fprintf(tw, "  %s\t%s\t%d\n", handshake.Server, orDash(handshake.ServerName), handshake.Count)
+		}
+	}
+
+	return tw.Flush()
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
+
+// WriteJSON writes the summary as an indented json document.
+func WriteJSON(w io.Writer, s *Summary) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(s)
+}
+
+// csvHeader lists the columns of the csv summary, each row is one entry of a section of the summary.
+var csvHeader = []string{"section", "client", "server", "name", "detail", "packets", "bytes", "count"}
+
+// WriteCSV writes the summary as csv rows, one per entry of each section.
+func WriteCSV(w io.Writer, s *Summary) error {
+	writer := csv.NewWriter(w)
+
+	rows := [][]string{
+		csvHeader,
+		{"capture", "", "", "", fmt.Sprintf("%s/%s", s.Start.Format(time.RFC3339Nano), s.End.Format(time.RFC3339Nano)),
+			fmt.Sprint(s.Packets), fmt.Sprint(s.Bytes), ""},
+	}
+
+	for _, talker := range s.TopTalkers {
+		rows = append(rows, []string{"top_talker", talker.Address, "", talker.Name, "", fmt.Sprint(talker.Packets),
+			fmt.Sprint(talker.Bytes), ""})
+	}
+
+	for _, count := range []struct {
+		detail string
+		count  int
+	}{{"opened", s.Connections.Opened}, {"closed", s.Connections.Closed}, {"reset", s.Connections.Reset}} {
+		rows = append(rows, []string{"connections", "", "", "", count.detail, "", "", fmt.Sprint(count.count)})
+	}
+
+	for _, syn := range s.UnansweredSyns {
+		detail := "unanswered"
+		if syn.Refused {
+			detail = "refused"
+		}
+
+		rows = append(rows, []string{"unanswered_syn", syn.Client, syn.Server, "", detail, fmt.Sprint(syn.Attempts),
+			"", ""})
+	}
+
+	for _, failure := range s.DNSFailures {
+		rows = append(rows, []string{"dns_failure", "", failure.Server, failure.Name, failure.Type + " " + failure.Rcode,
+			"", "", fmt.Sprint(failure.Count)})
+	}
+
+	for _, handshake := range s.TLSHandshakes {
+		rows = append(rows, []string{"tls_handshake", "", handshake.Server, handshake.ServerName, "", "", "",
+			fmt.Sprint(handshake.Count)})
+	}
+
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+
+	return writer.Error()
+}
+
+// WriteFile writes the summary to the file, as csv when its extension is '.csv' and as json otherwise.
+func WriteFile(path string, s *Summary) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create summary file: '%s'", path)
+	}
+
+	write := WriteJSON
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		write = WriteCSV
+	}
+
+	if err := write(file, s); err != nil {
+		_ = file.Close()
+		return errors.Wrapf(err, "failed to write summary file: '%s'", path)
+	}
+
+	return file.Close()
+}
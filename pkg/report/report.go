@@ -0,0 +1,341 @@
+// Package report summarizes a capture: the top talkers, the connections, and the obvious failures.
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"ksniff/pkg/dns"
+	"ksniff/pkg/flow"
+	"ksniff/pkg/packet"
+	"ksniff/pkg/pcap"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxTopTalkers is the number of endpoints listed by the summary.
+const maxTopTalkers = 10
+
+// Names resolves the captured addresses to pod names.
+type Names interface {
+	Name(ip string) string
+}
+
+// Talker is an endpoint of the capture, with the packets it sent and received.
+type Talker struct {
+	Address string `json:"address"`
+	Name    string `json:"name,omitempty"`
+	Packets uint64 `json:"packets"`
+	Bytes   uint64 `json:"bytes"`
+}
+
+// Connections counts the tcp connections whose opening, closing or reset was captured.
+type Connections struct {
+	Opened int `json:"opened"`
+	Closed int `json:"closed"`
+	Reset  int `json:"reset"`
+}
+
+// UnansweredSyn is a connection attempt the server never accepted, e.g. because of a network policy.
+type UnansweredSyn struct {
+	Client    string    `json:"client"`
+	Server    string    `json:"server"`
+	FirstSeen time.Time `json:"firstSeen"`
+	Attempts  uint64    `json:"attempts"`
+	// Refused reports whether the server reset the connection.
+	Refused bool `json:"refused"`
+}
+
+// DNSFailure counts the failed dns responses of a query.
+type DNSFailure struct {
+	Server string `json:"server"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Rcode  string `json:"rcode"`
+	Count  int    `json:"count"`
+}
+
+// TLSHandshake counts the tls client hellos sent to a server with the same server name indication.
+type TLSHandshake struct {
+	Server     string `json:"server"`
+	ServerName string `json:"serverName"`
+	Count      int    `json:"count"`
+}
+
+// Summary is derived from the captured packets.
+type Summary struct {
+	Start          time.Time       `json:"start"`
+	End            time.Time       `json:"end"`
+	Packets        uint64          `json:"packets"`
+	Bytes          uint64          `json:"bytes"`
+	TopTalkers     []Talker        `json:"topTalkers"`
+	Connections    Connections     `json:"connections"`
+	UnansweredSyns []UnansweredSyn `json:"unansweredSyns"`
+	DNSFailures    []DNSFailure    `json:"dnsFailures"`
+	TLSHandshakes  []TLSHandshake  `json:"tlsHandshakes"`
+}
+
+type dnsFailureKey struct {
+	server string
+	name   string
+	qtype  string
+	rcode  string
+}
+
+type tlsHandshakeKey struct {
+	server     flow.Endpoint
+	serverName string
+}
+
+// Collector accounts the captured packets of the summary.
+type Collector struct {
+	names   Names
+	flows   *flow.Table
+	mutex   sync.Mutex
+	packets uint64
+	bytes   uint64
+	start   time.Time
+	end     time.Time
+	talkers map[string]*Talker
+	// clientHellos buffers the client hellos split over several segments, until their record is complete.
+	clientHellos  map[flow.Key][]byte
+	dnsFailures   map[dnsFailureKey]*DNSFailure
+	tlsHandshakes map[tlsHandshakeKey]*TLSHandshake
+}
+
+func NewCollector(names Names) *Collector {
+	return &Collector{
+		names:         names,
+		flows:         flow.NewTable(),
+		talkers:       map[string]*Talker{},
+		clientHellos:  map[flow.Key][]byte{},
+		dnsFailures:   map[dnsFailureKey]*DNSFailure{},
+		tlsHandshakes: map[tlsHandshakeKey]*TLSHandshake{},
+	}
+}
+
+// Consume reads the capture stream until its end.
+func (c *Collector) Consume(r io.Reader) error {
+	reader, err := pcap.OpenStream(r)
+	if err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+
+	for {
+		captured, err := reader.ReadPacket()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		c.Add(captured)
+	}
+}
+
+// Add accounts a captured packet, the packets which aren't IP are only counted.
+func (c *Collector) Add(captured *pcap.Packet) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.packets++
+	c.bytes += uint64(captured.OriginalLength)
+
+	if c.start.IsZero() || captured.Timestamp.Before(c.start) {
+		c.start = captured.Timestamp
+	}
+	if captured.Timestamp.After(c.end) {
+		c.end = captured.Timestamp
+	}
+
+	decoded, err := packet.Decode(captured)
+	if err != nil {
+		log.WithError(err).Debug("packet skipped by the capture summary")
+		return
+	}
+
+	c.addTalker(decoded.SrcIP.String(), decoded)
+	c.addTalker(decoded.DstIP.String(), decoded)
+
+	key, direction := c.flows.Add(decoded)
+
+	if message, ok := dns.Parse(decoded); ok && message.Response && decoded.SrcPort == dns.Port &&
+		message.Rcode != dns.RcodeSuccess {
+		c.addDNSFailure(decoded, message)
+	}
+
+	if decoded.TCP != nil && direction == flow.ClientToServer {
+		c.addClientData(key, decoded)
+	}
+}
+
+func (c *Collector) addTalker(ip string, p *packet.Packet) {
+	talker, ok := c.talkers[ip]
+	if !ok {
+		talker = &Talker{Address: ip}
+		c.talkers[ip] = talker
+	}
+
+	talker.Packets++
+	talker.Bytes += uint64(p.Length)
+}
+
+func (c *Collector) addDNSFailure(p *packet.Packet, message *dns.Message) {
+	key := dnsFailureKey{server: p.SrcIP.String(), name: message.Name, qtype: message.Type, rcode: message.Rcode}
+
+	failure, ok := c.dnsFailures[key]
+	if !ok {
+		failure = &DNSFailure{Server: key.server, Name: key.name, Type: key.qtype, Rcode: key.rcode}
+		c.dnsFailures[key] = failure
+	}
+
+	failure.Count++
+}
+
+// addClientData looks for the tls client hello at the start of the client data of the connections.
+func (c *Collector) addClientData(key flow.Key, p *packet.Packet) {
+	if len(p.Payload) == 0 {
+		return
+	}
+
+	buffered, ok := c.clientHellos[key]
+	if !ok {
+		if !isClientHello(p.Payload) {
+			return
+		}
+		buffered = make([]byte, 0, len(p.Payload))
+	}
+
+	buffered = append(buffered, p.Payload...)
+	length := clientHelloLength(buffered)
+	if len(buffered) < length && len(buffered) < maxClientHelloLength {
+		c.clientHellos[key] = buffered
+		return
+	}
+
+	delete(c.clientHellos, key)
+
+	if length < len(buffered) {
+		buffered = buffered[:length]
+	}
+
+	serverName, _ := parseServerName(buffered)
+	handshakeKey := tlsHandshakeKey{server: flow.Endpoint{IP: p.DstIP.String(), Port: p.DstPort}, serverName: serverName}
+
+	handshake, ok := c.tlsHandshakes[handshakeKey]
+	if !ok {
+		handshake = &TLSHandshake{ServerName: serverName}
+		c.tlsHandshakes[handshakeKey] = handshake
+	}
+
+	handshake.Count++
+}
+
+// name returns the pod name of the ip, or an empty string.
+func (c *Collector) name(ip string) string {
+	if c.names == nil {
+		return ""
+	}
+
+	return c.names.Name(ip)
+}
+
+// endpoint formats the endpoint using the pod name of its ip when known.
+func (c *Collector) endpoint(e flow.Endpoint) string {
+	if name := c.name(e.IP); name != "" {
+		return fmt.Sprintf("%s:%d", name, e.Port)
+	}
+
+	return e.String()
+}
+
+// Summary returns the summary of the packets added so far.
+func (c *Collector) Summary() *Summary {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	summary := &Summary{Start: c.start, End: c.end, Packets: c.packets, Bytes: c.bytes}
+
+	for _, talker := range c.talkers {
+		named := *talker
+		named.Name = c.name(talker.Address)
+		summary.TopTalkers = append(summary.TopTalkers, named)
+	}
+
+	sort.Slice(summary.TopTalkers, func(i, j int) bool {
+		if summary.TopTalkers[i].Bytes != summary.TopTalkers[j].Bytes {
+			return summary.TopTalkers[i].Bytes > summary.TopTalkers[j].Bytes
+		}
+		return summary.TopTalkers[i].Address < summary.TopTalkers[j].Address
+	})
+
+	if len(summary.TopTalkers) > maxTopTalkers {
+		summary.TopTalkers = summary.TopTalkers[:maxTopTalkers]
+	}
+
+	for _, f := range c.flows.Flows() {
+		if f.Protocol != packet.ProtocolTCP {
+			continue
+		}
+
+		if f.Opened {
+			summary.Connections.Opened++
+		}
+
+		switch f.State {
+		case flow.StateClosed:
+			summary.Connections.Closed++
+		case flow.StateReset:
+			summary.Connections.Reset++
+		}
+
+		if f.Opened && !f.Accepted {
+			summary.UnansweredSyns = append(summary.UnansweredSyns, UnansweredSyn{
+				Client:    c.endpoint(f.Client),
+				Server:    c.endpoint(f.Server),
+				FirstSeen: f.FirstSeen,
+				Attempts:  f.Packets[flow.ClientToServer],
+				Refused:   f.State == flow.StateReset,
+			})
+		}
+	}
+
+	for _, failure := range c.dnsFailures {
+		named := *failure
+		if name := c.name(failure.Server); name != "" {
+			named.Server = name
+		}
+		summary.DNSFailures = append(summary.DNSFailures, named)
+	}
+
+	sort.Slice(summary.DNSFailures, func(i, j int) bool {
+		a, b := summary.DNSFailures[i], summary.DNSFailures[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Name < b.Name
+	})
+
+	for key, handshake := range c.tlsHandshakes {
+		named := *handshake
+		named.Server = c.endpoint(key.server)
+		summary.TLSHandshakes = append(summary.TLSHandshakes, named)
+	}
+
+	sort.Slice(summary.TLSHandshakes, func(i, j int) bool {
+		a, b := summary.TLSHandshakes[i], summary.TLSHandshakes[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Server+a.ServerName < b.Server+b.ServerName
+	})
+
+	return summary
+}
@@ -0,0 +1,187 @@
+package report
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"ksniff/pkg/packet"
+	"ksniff/pkg/pcap"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+type fakeNames map[string]string
+
+func (f fakeNames) Name(ip string) string {
+	return f[ip]
+}
+
+var start = time.Unix(1700000000, 0)
+
+// buildFrame builds a raw ipv4 frame.
+func buildFrame(milliseconds int, src string, srcPort uint16, dst string, dstPort uint16, protocol uint8,
+	flags uint8, payload []byte) *pcap.Packet {
+
+	transportLength := 8
+	if protocol == packet.ProtocolTCP {
+		transportLength = 20
+	}
+
+	frame := make([]byte, 20+transportLength+len(payload))
+	frame[0] = 0x45
+	binary.BigEndian.PutUint16(frame[2:4], uint16(len(frame)))
+	frame[9] = protocol
+	copy(frame[12:16], net.ParseIP(src).To4())
+	copy(frame[16:20], net.ParseIP(dst).To4())
+
+	transport := frame[20:]
+	binary.BigEndian.PutUint16(transport[0:2], srcPort)
+	binary.BigEndian.PutUint16(transport[2:4], dstPort)
+	if protocol == packet.ProtocolTCP {
+		transport[12] = 5 << 4
+		transport[13] = flags
+	}
+	copy(transport[transportLength:], payload)
+
+	return &pcap.Packet{LinkType: pcap.LinkTypeRaw, Timestamp: start.Add(time.Duration(milliseconds) * time.Millisecond),
+		Data: frame, CaptureLength: uint32(len(frame)), OriginalLength: uint32(len(frame))}
+}
+
+func buildDNSResponse(t *testing.T, name string, rcode dnsmessage.RCode) []byte {
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: 1, Response: true, RCode: rcode})
+	require.NoError(t, builder.StartQuestions())
+	require.NoError(t, builder.Question(dnsmessage.Question{Name: dnsmessage.MustNewName(name),
+		Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}))
+
+	message, err := builder.Finish()
+	require.NoError(t, err)
+
+	return message
+}
+
+// clientHello returns the first record sent by a tls client.
+func clientHello(t *testing.T, serverName string) []byte {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	go func() {
+		_ = tls.Client(client, &tls.Config{ServerName: serverName}).Handshake()
+		_ = client.Close()
+	}()
+
+	header := make([]byte, 5)
+	_, err := io.ReadFull(server, header)
+	require.NoError(t, err)
+
+	record := make([]byte, 5+int(binary.BigEndian.Uint16(header[3:5])))
+	copy(record, header)
+	_, err = io.ReadFull(server, record[5:])
+	require.NoError(t, err)
+
+	return record
+}
+
+func TestCollector_Summary(t *testing.T) {
+	// given
+	collector := NewCollector(fakeNames{"10.0.0.1": "default/web-0", "10.0.0.10": "kube-system/coredns-0"})
+	hello := clientHello(t, "api.example.com")
+
+	// when
+	// a connection opened, used and closed
+	collector.Add(buildFrame(0, "10.0.0.1", 40000, "10.0.0.2", 443, packet.ProtocolTCP, packet.FlagSYN, nil))
+	collector.Add(buildFrame(1, "10.0.0.2", 443, "10.0.0.1", 40000, packet.ProtocolTCP, packet.FlagSYN|packet.FlagACK, nil))
+	collector.Add(buildFrame(2, "10.0.0.1", 40000, "10.0.0.2", 443, packet.ProtocolTCP, packet.FlagACK, nil))
+	// the client hello is split over two segments
+	collector.Add(buildFrame(3, "10.0.0.1", 40000, "10.0.0.2", 443, packet.ProtocolTCP, packet.FlagACK, hello[:40]))
+	collector.Add(buildFrame(4, "10.0.0.1", 40000, "10.0.0.2", 443, packet.ProtocolTCP, packet.FlagACK, hello[40:]))
+	collector.Add(buildFrame(5, "10.0.0.1", 40000, "10.0.0.2", 443, packet.ProtocolTCP, packet.FlagFIN|packet.FlagACK, nil))
+	// a connection attempt never answered
+	collector.Add(buildFrame(10, "10.0.0.1", 40001, "10.0.0.3", 6379, packet.ProtocolTCP, packet.FlagSYN, nil))
+	collector.Add(buildFrame(1010, "10.0.0.1", 40001, "10.0.0.3", 6379, packet.ProtocolTCP, packet.FlagSYN, nil))
+	// a connection refused
+	collector.Add(buildFrame(20, "10.0.0.1", 40002, "10.0.0.4", 80, packet.ProtocolTCP, packet.FlagSYN, nil))
+	collector.Add(buildFrame(21, "10.0.0.4", 80, "10.0.0.1", 40002, packet.ProtocolTCP, packet.FlagRST|packet.FlagACK, nil))
+	// dns responses
+	collector.Add(buildFrame(30, "10.0.0.10", 53, "10.0.0.1", 50000, packet.ProtocolUDP, 0,
+		buildDNSResponse(t, "redis.default.svc.cluster.local.", dnsmessage.RCodeNameError)))
+	collector.Add(buildFrame(31, "10.0.0.10", 53, "10.0.0.1", 50001, packet.ProtocolUDP, 0,
+		buildDNSResponse(t, "redis.default.svc.cluster.local.", dnsmessage.RCodeNameError)))
+	collector.Add(buildFrame(32, "10.0.0.10", 53, "10.0.0.1", 50002, packet.ProtocolUDP, 0,
+		buildDNSResponse(t, "api.example.com.", dnsmessage.RCodeSuccess)))
+
+	summary := collector.Summary()
+
+	// then
+	assert.Equal(t, uint64(13), summary.Packets)
+	assert.Equal(t, start, summary.Start)
+	assert.Equal(t, start.Add(1010*time.Millisecond), summary.End)
+
+	assert.Equal(t, "10.0.0.1", summary.TopTalkers[0].Address)
+	assert.Equal(t, "default/web-0", summary.TopTalkers[0].Name)
+	assert.Equal(t, uint64(13), summary.TopTalkers[0].Packets)
+
+	assert.Equal(t, Connections{Opened: 3, Closed: 1, Reset: 1}, summary.Connections)
+
+	assert.Equal(t, []UnansweredSyn{
+		{Client: "default/web-0:40001", Server: "10.0.0.3:6379", FirstSeen: start.Add(10 * time.Millisecond), Attempts: 2},
+		{Client: "default/web-0:40002", Server: "10.0.0.4:80", FirstSeen: start.Add(20 * time.Millisecond), Attempts: 1, Refused: true},
+	}, summary.UnansweredSyns)
+
+	assert.Equal(t, []DNSFailure{
+		{Server: "kube-system/coredns-0", Name: "redis.default.svc.cluster.local.", Type: "A", Rcode: "NXDOMAIN", Count: 2},
+	}, summary.DNSFailures)
+
+	assert.Equal(t, []TLSHandshake{{Server: "10.0.0.2:443", ServerName: "api.example.com", Count: 1}}, summary.TLSHandshakes)
+}
+
+func TestParseServerName_Truncated(t *testing.T) {
+	// given
+	hello := clientHello(t, "api.example.com")
+
+	// when
+	_, ok := parseServerName(hello[:50])
+
+	// then
+	assert.False(t, ok)
+}
+
+func TestWriteFormats(t *testing.T) {
+	// given
+	summary := &Summary{
+		Start: start, End: start.Add(time.Second), Packets: 3, Bytes: 180,
+		TopTalkers:    []Talker{{Address: "10.0.0.1", Name: "default/web-0", Packets: 3, Bytes: 180}},
+		Connections:   Connections{Opened: 1},
+		DNSFailures:   []DNSFailure{{Server: "10.0.0.10", Name: "redis.", Type: "A", Rcode: "NXDOMAIN", Count: 1}},
+		TLSHandshakes: []TLSHandshake{{Server: "10.0.0.2:443", ServerName: "api.example.com", Count: 1}},
+	}
+
+	// when
+	text, jsonOutput, csvOutput := new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer)
+	assert.NoError(t, WriteText(text, summary))
+	assert.NoError(t, WriteJSON(jsonOutput, summary))
+	assert.NoError(t, WriteCSV(csvOutput, summary))
+
+	// then
+	assert.Contains(t, text.String(), "capture summary: 3 packets, 180 bytes, 1s")
+	assert.Contains(t, text.String(), "tcp connections: 1 opened, 0 closed, 0 reset")
+	assert.Contains(t, text.String(), "api.example.com")
+
+	var decoded Summary
+	assert.NoError(t, json.Unmarshal(jsonOutput.Bytes(), &decoded))
+	assert.Equal(t, summary.DNSFailures, decoded.DNSFailures)
+
+	rows, err := csv.NewReader(csvOutput).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, csvHeader, rows[0])
+	assert.Equal(t, []string{"top_talker", "10.0.0.1", "", "default/web-0", "", "3", "180", ""}, rows[2])
+	assert.Equal(t, []string{"dns_failure", "", "10.0.0.10", "redis.", "A NXDOMAIN", "", "", "1"}, rows[6])
+}
@@ -0,0 +1,100 @@
+package report
+
+import (
+	"encoding/binary"
+)
+
+const (
+	tlsRecordHandshake      = 0x16
+	tlsHandshakeClientHello = 0x01
+	tlsRecordHeaderLength   = 5
+	tlsExtensionServerName  = 0x0000
+	// maxClientHelloLength bounds the client data buffered for a client hello split over several segments.
+	maxClientHelloLength = 16384 + tlsRecordHeaderLength
+)
+
+// isClientHello reports whether the payload starts a tls client hello record.
+func isClientHello(payload []byte) bool {
+	return len(payload) >= tlsRecordHeaderLength+1 && payload[0] == tlsRecordHandshake && payload[1] == 0x03 &&
+		payload[tlsRecordHeaderLength] == tlsHandshakeClientHello
+}
+
+// clientHelloLength returns the length of the record holding the client hello, including its header.
+func clientHelloLength(payload []byte) int {
+	return tlsRecordHeaderLength + int(binary.BigEndian.Uint16(payload[3:5]))
+}
+
+// parseServerName returns the server name indication of a client hello record. The extensions are parsed as far
+// as the data goes, so the server name of a truncated client hello is usually still found.
+func parseServerName(record []byte) (string, bool) {
+	data := record[tlsRecordHeaderLength:]
+
+	// handshake type and length, client version and random
+	offset := 4 + 2 + 32
+	if len(data) < offset+1 {
+		return "", false
+	}
+
+	// session id
+	offset += 1 + int(data[offset])
+	if len(data) < offset+2 {
+		return "", false
+	}
+
+	// cipher suites
+	offset += 2 + int(binary.BigEndian.Uint16(data[offset:offset+2]))
+	if len(data) < offset+1 {
+		return "", false
+	}
+
+	// compression methods
+	offset += 1 + int(data[offset])
+	if len(data) < offset+2 {
+		return "", false
+	}
+
+	// extensions length
+	offset += 2
+
+	for len(data) >= offset+4 {
+		extensionType := binary.BigEndian.Uint16(data[offset : offset+2])
+		extensionLength := int(binary.BigEndian.Uint16(data[offset+2 : offset+4]))
+		offset += 4
+
+		if len(data) < offset+extensionLength {
+			return "", false
+		}
+
+		if extensionType == tlsExtensionServerName {
+			return parseServerNameExtension(data[offset : offset+extensionLength])
+		}
+
+		offset += extensionLength
+	}
+
+	return "", false
+}
+
+func parseServerNameExtension(extension []byte) (string, bool) {
+	if len(extension) < 2 {
+		return "", false
+	}
+
+	names := extension[2:]
+	for len(names) >= 3 {
+		nameType := names[0]
+		nameLength := int(binary.BigEndian.Uint16(names[1:3]))
+		if len(names) < 3+nameLength {
+			return "", false
+		}
+
+		// host name
+		if nameType == 0 {
+			return string(names[3 : 3+nameLength]), true
+		}
+
+		names = names[3+nameLength:]
+	}
+
+	return "", false
+}
@@ -0,0 +1,114 @@
+package sniffer
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+)
+
+// capNetRaw is the CAP_NET_RAW capability bit, required by tcpdump to open a packet socket.
+const capNetRaw = 13
+
+// MissingCapabilityError is returned when the target container can't capture packets using static tcpdump.
+type MissingCapabilityError struct {
+	ContainerName string
+	Reason        string
+}
+
+func (e *MissingCapabilityError) Error() string {
+	return fmt.Sprintf("container: '%s' can't capture packets, %s: tcpdump requires the NET_RAW capability, "+
+		"consider using the privileged mode (-p)", e.ContainerName, e.Reason)
+}
+
+// CheckCaptureCapabilities verifies the container security context allows tcpdump to capture packets,
+// containers running as non-root or dropping NET_RAW can't open a packet socket.
+func CheckCaptureCapabilities(pod *v1.Pod, containerName string) error {
+	var container *v1.Container
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == containerName {
+			container = &pod.Spec.Containers[i]
+			break
+		}
+	}
+
+	if container == nil {
+		return nil
+	}
+
+	securityContext := container.SecurityContext
+	if securityContext == nil {
+		securityContext = &v1.SecurityContext{}
+	}
+
+	if securityContext.Privileged != nil && *securityContext.Privileged {
+		return nil
+	}
+
+	runAsUser := securityContext.RunAsUser
+	runAsNonRoot := securityContext.RunAsNonRoot
+	if podSecurityContext := pod.Spec.SecurityContext; podSecurityContext != nil {
+		if runAsUser == nil {
+			runAsUser = podSecurityContext.RunAsUser
+		}
+		if runAsNonRoot == nil {
+			runAsNonRoot = podSecurityContext.RunAsNonRoot
+		}
+	}
+
+	// capabilities aren't raised for non-root users, kubernetes doesn't support ambient capabilities
+	if runAsUser != nil && *runAsUser != 0 {
+		return &MissingCapabilityError{ContainerName: containerName, Reason: fmt.Sprintf("it runs as non-root user: '%d'", *runAsUser)}
+	}
+
+	if runAsUser == nil && runAsNonRoot != nil && *runAsNonRoot {
+		return &MissingCapabilityError{ContainerName: containerName, Reason: "it must run as non-root (runAsNonRoot)"}
+	}
+
+	if capabilities := securityContext.Capabilities; capabilities != nil {
+		if containsCapability(capabilities.Drop, "NET_RAW") && !containsCapability(capabilities.Add, "NET_RAW") {
+			return &MissingCapabilityError{ContainerName: containerName, Reason: "its security context drops NET_RAW"}
+		}
+	}
+
+	return nil
+}
+
+func containsCapability(capabilities []v1.Capability, capability string) bool {
+	for _, c := range capabilities {
+		name := strings.TrimPrefix(strings.ToUpper(string(c)), "CAP_")
+		if name == capability || name == "ALL" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseEffectiveCapabilities extracts the effective capability set (CapEff) from a /proc/<pid>/status content.
+func ParseEffectiveCapabilities(status string) (uint64, error) {
+	scanner := bufio.NewScanner(strings.NewReader(status))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || fields[0] != "CapEff:" {
+			continue
+		}
+
+		capabilities, err := strconv.ParseUint(fields[1], 16, 64)
+		if err != nil {
+			return 0, errors.Wrapf(err, "invalid effective capabilities: '%s'", fields[1])
+		}
+
+		return capabilities, nil
+	}
+
+	return 0, errors.New("effective capabilities not found in process status")
+}
+
+// HasNetRawCapability reports whether the capability set includes CAP_NET_RAW.
+func HasNetRawCapability(capabilities uint64) bool {
+	return capabilities&(1<<capNetRaw) != 0
+}
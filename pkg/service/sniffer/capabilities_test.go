@@ -0,0 +1,114 @@
+package sniffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func buildPod(podSecurityContext *v1.PodSecurityContext, securityContext *v1.SecurityContext) *v1.Pod {
+	return &v1.Pod{Spec: v1.PodSpec{
+		SecurityContext: podSecurityContext,
+		Containers:      []v1.Container{{Name: "app", SecurityContext: securityContext}},
+	}}
+}
+
+func TestCheckCaptureCapabilities_DefaultSecurityContext(t *testing.T) {
+	// when
+	err := CheckCaptureCapabilities(buildPod(nil, nil), "app")
+
+	// then
+	assert.Nil(t, err)
+}
+
+func TestCheckCaptureCapabilities_NonRootPodUser(t *testing.T) {
+	// given
+	user := int64(1000)
+	pod := buildPod(&v1.PodSecurityContext{RunAsUser: &user}, nil)
+
+	// when
+	err := CheckCaptureCapabilities(pod, "app")
+
+	// then
+	assert.IsType(t, &MissingCapabilityError{}, err)
+	assert.Contains(t, err.Error(), "non-root user: '1000'")
+}
+
+func TestCheckCaptureCapabilities_ContainerRootUserOverridesPod(t *testing.T) {
+	// given
+	podUser, containerUser := int64(1000), int64(0)
+	pod := buildPod(&v1.PodSecurityContext{RunAsUser: &podUser}, &v1.SecurityContext{RunAsUser: &containerUser})
+
+	// when
+	err := CheckCaptureCapabilities(pod, "app")
+
+	// then
+	assert.Nil(t, err)
+}
+
+func TestCheckCaptureCapabilities_RunAsNonRoot(t *testing.T) {
+	// given
+	runAsNonRoot := true
+	pod := buildPod(&v1.PodSecurityContext{RunAsNonRoot: &runAsNonRoot}, nil)
+
+	// when
+	err := CheckCaptureCapabilities(pod, "app")
+
+	// then
+	assert.IsType(t, &MissingCapabilityError{}, err)
+}
+
+func TestCheckCaptureCapabilities_DroppedNetRaw(t *testing.T) {
+	// given
+	pod := buildPod(nil, &v1.SecurityContext{Capabilities: &v1.Capabilities{Drop: []v1.Capability{"ALL"}}})
+
+	// when
+	err := CheckCaptureCapabilities(pod, "app")
+
+	// then
+	assert.IsType(t, &MissingCapabilityError{}, err)
+	assert.Contains(t, err.Error(), "drops NET_RAW")
+}
+
+func TestCheckCaptureCapabilities_DroppedAllAddedNetRaw(t *testing.T) {
+	// given
+	pod := buildPod(nil, &v1.SecurityContext{Capabilities: &v1.Capabilities{
+		Drop: []v1.Capability{"ALL"}, Add: []v1.Capability{"CAP_NET_RAW"}}})
+
+	// when
+	err := CheckCaptureCapabilities(pod, "app")
+
+	// then
+	assert.Nil(t, err)
+}
+
+func TestParseEffectiveCapabilities(t *testing.T) {
+	// given
+	status := "Name:\tcat\nUid:\t0\t0\t0\t0\nCapInh:\t0000000000000000\nCapPrm:\t00000000a80425fb\nCapEff:\t00000000a80425fb\n"
+
+	// when
+	capabilities, err := ParseEffectiveCapabilities(status)
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0xa80425fb), capabilities)
+	assert.True(t, HasNetRawCapability(capabilities))
+}
+
+func TestParseEffectiveCapabilities_NoNetRaw(t *testing.T) {
+	// when
+	capabilities, err := ParseEffectiveCapabilities("CapEff:\t0000000000000000\n")
+
+	// then
+	assert.Nil(t, err)
+	assert.False(t, HasNetRawCapability(capabilities))
+}
+
+func TestParseEffectiveCapabilities_Missing(t *testing.T) {
+	// when
+	_, err := ParseEffectiveCapabilities("Name:\tcat\n")
+
+	// then
+	assert.NotNil(t, err)
+}
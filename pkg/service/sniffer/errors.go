@@ -0,0 +1,104 @@
+package sniffer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalidFilter is returned when tcpdump rejects the capture filter.
+	ErrInvalidFilter = errors.New("invalid capture filter")
+
+	// ErrInterfaceNotFound is returned when the capture interface doesn't exist in the target network namespace.
+	ErrInterfaceNotFound = errors.New("capture interface not found")
+
+	// ErrPermissionDenied is returned when tcpdump isn't allowed to capture packets.
+	ErrPermissionDenied = errors.New("permission denied")
+
+	// ErrTcpdumpNotExecutable is returned when tcpdump can't be executed, e.g. a binary built for another platform.
+	ErrTcpdumpNotExecutable = errors.New("tcpdump can't be executed")
+
+	// ErrCaptureFailed is returned for any other tcpdump failure.
+	ErrCaptureFailed = errors.New("capture failed")
+)
+
+// tcpdumpErrorPatterns maps lower-cased tcpdump (and libpcap, nsenter or shell) error messages to the error kinds.
+var tcpdumpErrorPatterns = []struct {
+	pattern string
+	kind    error
+}{
+	{"syntax error", ErrInvalidFilter},
+	{"can't parse filter expression", ErrInvalidFilter},
+	{"illegal token", ErrInvalidFilter},
+	{"unknown host", ErrInvalidFilter},
+	{"unknown port", ErrInvalidFilter},
+	{"no such device", ErrInterfaceNotFound},
+	{"that device is not up", ErrInterfaceNotFound},
+	{"permission denied", ErrPermissionDenied},
+	{"operation not permitted", ErrPermissionDenied},
+	{"you don't have permission", ErrPermissionDenied},
+	{"exec format error", ErrTcpdumpNotExecutable},
+}
+
+// TcpdumpError describes a failed remote tcpdump execution, its kind can be checked using errors.Is.
+type TcpdumpError struct {
+	Kind     error
+	ExitCode int
+	StdErr   string
+	Cause    error
+}
+
+func (e *TcpdumpError) Error() string {
+	message := e.Kind.Error()
+
+	if reason := e.reason(); reason != "" {
+		message += fmt.Sprintf(": %s", reason)
+	}
+
+	if e.Cause != nil {
+		message += fmt.Sprintf(": %s", e.Cause)
+	}
+
+	return message + fmt.Sprintf(", exit code: '%d'", e.ExitCode)
+}
+
+func (e *TcpdumpError) Unwrap() error {
+	return e.Kind
+}
+
+// reason returns the last meaningful line written to stderr, tcpdump writes the actual error last.
+func (e *TcpdumpError) reason() string {
+	lines := strings.Split(strings.TrimSpace(e.StdErr), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if line != "" && !strings.HasPrefix(line, "Usage:") && !strings.HasPrefix(line, "(use -") {
+			return line
+		}
+	}
+
+	return ""
+}
+
+// NewTcpdumpError classifies a failed tcpdump execution using its stderr and exit code.
+func NewTcpdumpError(exitCode int, stdErr string, cause error) *TcpdumpError {
+	return &TcpdumpError{Kind: classifyTcpdumpError(exitCode, stdErr), ExitCode: exitCode, StdErr: stdErr, Cause: cause}
+}
+
+func classifyTcpdumpError(exitCode int, stdErr string) error {
+	output := strings.ToLower(stdErr)
+	for _, p := range tcpdumpErrorPatterns {
+		if strings.Contains(output, p.pattern) {
+			return p.kind
+		}
+	}
+
+	// shells, nsenter and container runtimes report commands that can't be executed (126) or found (127), the
+	// 'not found' messages aren't matched as they are also written for e.g. a missing network namespace
+	if exitCode == 126 || exitCode == 127 {
+		return ErrTcpdumpNotExecutable
+	}
+
+	return ErrCaptureFailed
+}
@@ -0,0 +1,48 @@
+package sniffer
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTcpdumpError_Classification(t *testing.T) {
+	testCases := []struct {
+		name     string
+		exitCode int
+		stdErr   string
+		kind     error
+	}{
+		{"invalid filter", 1, "tcpdump: syntax error in filter expression: syntax error", ErrInvalidFilter},
+		{"unknown interface", 1, "tcpdump: eth7: No such device exists\n(SIOCGIFHWADDR: No such device)", ErrInterfaceNotFound},
+		{"no permission", 1, "tcpdump: any: You don't have permission to capture on that device\n(socket: Operation not permitted)", ErrPermissionDenied},
+		{"wrong platform", 126, "exec format error", ErrTcpdumpNotExecutable},
+		{"missing binary", 127, "", ErrTcpdumpNotExecutable},
+		{"missing binary in privileged pod", 127, "nsenter: failed to execute tcpdump: No such file or directory", ErrTcpdumpNotExecutable},
+		{"missing network namespace", 1, "nsenter: cannot open /proc/4242/ns/net: No such file or directory", ErrCaptureFailed},
+		{"unknown failure", 1, "tcpdump: pcap_loop: unexpected failure", ErrCaptureFailed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// when
+			err := NewTcpdumpError(tc.exitCode, tc.stdErr, nil)
+
+			// then
+			assert.True(t, errors.Is(err, tc.kind), "expected kind: %v, actual: %v", tc.kind, err.Kind)
+		})
+	}
+}
+
+func TestTcpdumpError_ReportsLastStdErrLine(t *testing.T) {
+	// given
+	stdErr := "tcpdump: verbose output suppressed, use -v for full protocol decode\n" +
+		"tcpdump: eth7: No such device exists\n(SIOCGIFHWADDR: No such device)\n"
+
+	// when
+	err := NewTcpdumpError(1, stdErr, nil)
+
+	// then
+	assert.Equal(t, "capture interface not found: (SIOCGIFHWADDR: No such device), exit code: '1'", err.Error())
+}
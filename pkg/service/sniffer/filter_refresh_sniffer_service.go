@@ -0,0 +1,124 @@
+package sniffer
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"ksniff/pkg/config"
+	"ksniff/pkg/pcap"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// FilterWatcher notifies the updated capture filter, e.g. when the pods referenced by the filter change.
+type FilterWatcher interface {
+	Watch(ctx context.Context) <-chan string
+}
+
+// FilterRefreshSnifferService restarts the remote tcpdump when the capture filter is updated. The output is
+// converted to a single pcapng stream, where the first packet after each restart is commented with the new filter.
+type FilterRefreshSnifferService struct {
+	snifferService SnifferService
+	settings       *config.KsniffSettings
+	filterWatcher  FilterWatcher
+}
+
+func NewFilterRefreshSnifferService(service SnifferService, options *config.KsniffSettings, watcher FilterWatcher) SnifferService {
+	return &FilterRefreshSnifferService{snifferService: service, settings: options, filterWatcher: watcher}
+}
+
+func (f *FilterRefreshSnifferService) Setup() error {
+	return f.snifferService.Setup()
+}
+
+func (f *FilterRefreshSnifferService) Cleanup() error {
+	return f.snifferService.Cleanup()
+}
+
+func (f *FilterRefreshSnifferService) Stop() error {
+	return f.snifferService.Stop()
+}
+
+// startSession starts the remote sniffing, converting its output until it stops.
+func (f *FilterRefreshSnifferService) startSession(converter *pcap.Converter) (started <-chan error, converted <-chan error) {
+	startErrors := make(chan error, 1)
+	convertErrors := make(chan error, 1)
+	reader, writer := io.Pipe()
+
+	go func() {
+		err := f.snifferService.Start(writer)
+		_ = writer.Close()
+		startErrors <- err
+	}()
+
+	go func() {
+		err := converter.Convert(reader)
+		// stops the remote sniffing output when the conversion failed, e.g. the local output was closed
+		_ = reader.CloseWithError(err)
+		convertErrors <- err
+	}()
+
+	return startErrors, convertErrors
+}
+
+func (f *FilterRefreshSnifferService) Start(stdOut io.Writer) error {
+	return f.startConverting(pcap.NewConverter(stdOut, "ksniff"))
+}
+
+func (f *FilterRefreshSnifferService) startConverting(converter *pcap.Converter) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	filterUpdates := f.filterWatcher.Watch(ctx)
+
+	converter.Comment(fmt.Sprintf("ksniff capture filter: '%s'", f.settings.UserSpecifiedFilter))
+
+	for {
+		started, converted := f.startSession(converter)
+
+		restart := false
+		for !restart {
+			select {
+			case updatedFilter, ok := <-filterUpdates:
+				if !ok {
+					filterUpdates = nil
+					continue
+				}
+
+				log.Infof("capture filter updated to: '%s', restarting tcpdump", updatedFilter)
+
+				if err := f.snifferService.Stop(); err != nil {
+					log.WithError(err).Warn("failed to restart tcpdump, keeping the current capture filter")
+					continue
+				}
+
+				// the remote sniffing fails once stopped, and its output may end in the middle of a packet, only
+				// the local output failures matter
+				<-started
+				if err := <-converted; err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+					return err
+				}
+
+				f.settings.UserSpecifiedFilter = updatedFilter
+				converter.Comment(fmt.Sprintf("ksniff capture filter updated to: '%s'", updatedFilter))
+				restart = true
+
+			case err := <-started:
+				if convertErr := <-converted; err == nil {
+					return convertErr
+				}
+				return err
+
+			case err := <-converted:
+				if err != nil {
+					_ = f.snifferService.Stop()
+					<-started
+					return err
+				}
+				return <-started
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package sniffer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"ksniff/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSnifferService writes a pcap stream with a single packet per Start, and blocks the first Start until stopped.
+// The packet of the first Start is cut in the middle when truncated is set, like a stopped tcpdump output.
+type fakeSnifferService struct {
+	stopped   chan struct{}
+	filters   []string
+	stops     int
+	options   *config.KsniffSettings
+	truncated bool
+}
+
+func (f *fakeSnifferService) Setup() error {
+	return nil
+}
+
+func (f *fakeSnifferService) Cleanup() error {
+	return nil
+}
+
+func (f *fakeSnifferService) Stop() error {
+	f.stops++
+	close(f.stopped)
+	return nil
+}
+
+func (f *fakeSnifferService) Start(stdOut io.Writer) error {
+	f.filters = append(f.filters, f.options.UserSpecifiedFilter)
+
+	stream := new(bytes.Buffer)
+	_ = binary.Write(stream, binary.LittleEndian, []uint32{0xa1b2c3d4, 0x00040002, 0, 0, 262144, 276})
+	_ = binary.Write(stream, binary.LittleEndian, []uint32{1700000000, 0, 1, 1})
+	stream.WriteByte(byte(len(f.filters)))
+
+	if f.truncated && len(f.filters) == 1 {
+		stream.Truncate(stream.Len() - 1)
+	}
+
+	if _, err := stdOut.Write(stream.Bytes()); err != nil {
+		return err
+	}
+
+	if len(f.filters) == 1 {
+		<-f.stopped
+	}
+
+	return nil
+}
+
+type fakeFilterWatcher struct {
+	updates []string
+}
+
+func (f *fakeFilterWatcher) Watch(ctx context.Context) <-chan string {
+	updates := make(chan string)
+
+	go func() {
+		defer close(updates)
+		for _, update := range f.updates {
+			updates <- update
+		}
+	}()
+
+	return updates
+}
+
+func TestFilterRefreshStart_RestartsWithUpdatedFilter(t *testing.T) {
+	// given
+	options := &config.KsniffSettings{UserSpecifiedFilter: "host 10.0.0.1"}
+	inner := &fakeSnifferService{stopped: make(chan struct{}), options: options}
+	service := NewFilterRefreshSnifferService(inner, options, &fakeFilterWatcher{updates: []string{"host 10.0.0.2"}})
+	output := new(bytes.Buffer)
+
+	// when
+	err := service.Start(output)
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, 1, inner.stops)
+	assert.Equal(t, []string{"host 10.0.0.1", "host 10.0.0.2"}, inner.filters)
+	assert.Equal(t, uint32(0x0a0d0d0a), binary.LittleEndian.Uint32(output.Bytes()[0:4]))
+	assert.Contains(t, output.String(), "ksniff capture filter: 'host 10.0.0.1'")
+	assert.Contains(t, output.String(), "ksniff capture filter updated to: 'host 10.0.0.2'")
+}
+
+func TestFilterRefreshStart_IgnoresTruncatedStoppedStream(t *testing.T) {
+	// given
+	options := &config.KsniffSettings{UserSpecifiedFilter: "host 10.0.0.1"}
+	inner := &fakeSnifferService{stopped: make(chan struct{}), options: options, truncated: true}
+	service := NewFilterRefreshSnifferService(inner, options, &fakeFilterWatcher{updates: []string{"host 10.0.0.2"}})
+	output := new(bytes.Buffer)
+
+	// when
+	err := service.Start(output)
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"host 10.0.0.1", "host 10.0.0.2"}, inner.filters)
+	assert.Contains(t, output.String(), "ksniff capture filter updated to: 'host 10.0.0.2'")
+}
@@ -0,0 +1,99 @@
+package sniffer
+
+import (
+	"context"
+	"io"
+
+	"ksniff/pkg/pcap"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// KeyLogWatcher notifies the lines appended to a tls key log file.
+type KeyLogWatcher interface {
+	Watch(ctx context.Context) <-chan []byte
+}
+
+// convertingSnifferService converts its output to pcapng using the given converter, e.g. shared with the key log.
+type convertingSnifferService interface {
+	startConverting(converter *pcap.Converter) error
+}
+
+// KeyLogSnifferService converts the output to a pcapng stream, where the tls key log secrets are embedded as
+// decryption secrets blocks, so wireshark decrypts the captured tls connections.
+type KeyLogSnifferService struct {
+	snifferService SnifferService
+	keyLogWatcher  KeyLogWatcher
+}
+
+func NewKeyLogSnifferService(service SnifferService, watcher KeyLogWatcher) SnifferService {
+	return &KeyLogSnifferService{snifferService: service, keyLogWatcher: watcher}
+}
+
+func (k *KeyLogSnifferService) Setup() error {
+	return k.snifferService.Setup()
+}
+
+func (k *KeyLogSnifferService) Cleanup() error {
+	return k.snifferService.Cleanup()
+}
+
+func (k *KeyLogSnifferService) Stop() error {
+	return k.snifferService.Stop()
+}
+
+func (k *KeyLogSnifferService) Start(stdOut io.Writer) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	converter := pcap.NewConverter(stdOut, "ksniff")
+	secretsWritten := make(chan struct{})
+
+	go func() {
+		defer close(secretsWritten)
+		for lines := range k.keyLogWatcher.Watch(ctx) {
+			if err := converter.Secrets(pcap.SecretsTypeTLSKeyLog, lines); err != nil {
+				log.WithError(err).Debug("failed to write tls key log secrets")
+			}
+		}
+	}()
+
+	err := k.convert(converter)
+
+	// the secrets received after the last packet are written before the output is closed
+	cancel()
+	<-secretsWritten
+	if flushErr := converter.Flush(); err == nil {
+		err = flushErr
+	}
+
+	return err
+}
+
+// convert converts the output of the sniffer service until it stops.
+func (k *KeyLogSnifferService) convert(converter *pcap.Converter) error {
+	// the filter refresh already converts its output, the converter is shared so a single section is written
+	if service, ok := k.snifferService.(convertingSnifferService); ok {
+		return service.startConverting(converter)
+	}
+
+	reader, writer := io.Pipe()
+	startErrors := make(chan error, 1)
+
+	go func() {
+		err := k.snifferService.Start(writer)
+		_ = writer.Close()
+		startErrors <- err
+	}()
+
+	err := converter.Convert(reader)
+	// stops the remote sniffing output when the conversion failed, e.g. the local output was closed
+	_ = reader.CloseWithError(err)
+	if err != nil {
+		_ = k.snifferService.Stop()
+		<-startErrors
+		return err
+	}
+
+	return <-startErrors
+}
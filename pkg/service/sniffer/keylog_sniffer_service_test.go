@@ -0,0 +1,130 @@
+package sniffer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+
+	"ksniff/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeKeyLogWatcher notifies its lines, sent is closed once the first line was handled as the second one was
+// received.
+type fakeKeyLogWatcher struct {
+	lines []string
+	sent  chan struct{}
+}
+
+func (f *fakeKeyLogWatcher) Watch(ctx context.Context) <-chan []byte {
+	lines := make(chan []byte)
+
+	go func() {
+		defer close(lines)
+		for _, line := range f.lines {
+			lines <- []byte(line)
+		}
+		close(f.sent)
+	}()
+
+	return lines
+}
+
+// waitingSnifferService writes its capture once the key log lines were sent.
+type waitingSnifferService struct {
+	fakeSnifferService
+	sent chan struct{}
+}
+
+func (w *waitingSnifferService) Start(stdOut io.Writer) error {
+	<-w.sent
+	return w.fakeSnifferService.Start(stdOut)
+}
+
+func TestKeyLogStart_EmbedsSecrets(t *testing.T) {
+	// given
+	stopped := make(chan struct{})
+	close(stopped)
+	watcher := &fakeKeyLogWatcher{lines: []string{"CLIENT_RANDOM 0102 0304\n", "CLIENT_RANDOM 0506 0708\n"},
+		sent: make(chan struct{})}
+	inner := &waitingSnifferService{sent: watcher.sent,
+		fakeSnifferService: fakeSnifferService{stopped: stopped, options: &config.KsniffSettings{}}}
+	service := NewKeyLogSnifferService(inner, watcher)
+	output := new(bytes.Buffer)
+
+	// when
+	err := service.Start(output)
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(0x0a0d0d0a), binary.LittleEndian.Uint32(output.Bytes()[0:4]))
+
+	// the secrets are written before the packet
+	secrets := strings.Index(output.String(), "CLIENT_RANDOM 0102 0304\n")
+	assert.True(t, secrets > 0)
+	assert.Equal(t, uint32(0x0000000a), binary.LittleEndian.Uint32(output.Bytes()[secrets-16:secrets-12]))
+	assert.Equal(t, uint32(0x544c534b), binary.LittleEndian.Uint32(output.Bytes()[secrets-8:secrets-4]))
+	lastBlock := len(output.Bytes()) - int(binary.LittleEndian.Uint32(output.Bytes()[len(output.Bytes())-4:]))
+	assert.True(t, lastBlock > secrets)
+	assert.Equal(t, uint32(0x00000006), binary.LittleEndian.Uint32(output.Bytes()[lastBlock:lastBlock+4]))
+}
+
+// finishingSnifferService signals once its capture was written and it returned.
+type finishingSnifferService struct {
+	fakeSnifferService
+	finished chan struct{}
+}
+
+func (f *finishingSnifferService) Start(stdOut io.Writer) error {
+	defer close(f.finished)
+	return f.fakeSnifferService.Start(stdOut)
+}
+
+// lateKeyLogWatcher notifies its line once the capture finished.
+type lateKeyLogWatcher struct {
+	line     string
+	finished chan struct{}
+}
+
+func (l *lateKeyLogWatcher) Watch(ctx context.Context) <-chan []byte {
+	lines := make(chan []byte)
+
+	go func() {
+		defer close(lines)
+		<-l.finished
+		lines <- []byte(l.line)
+	}()
+
+	return lines
+}
+
+func TestKeyLogStart_EmbedsSecretsAfterLastPacket(t *testing.T) {
+	// given
+	stopped := make(chan struct{})
+	close(stopped)
+	finished := make(chan struct{})
+	watcher := &lateKeyLogWatcher{line: "CLIENT_RANDOM 0102 0304\n", finished: finished}
+	inner := &finishingSnifferService{finished: finished,
+		fakeSnifferService: fakeSnifferService{stopped: stopped, options: &config.KsniffSettings{}}}
+	service := NewKeyLogSnifferService(inner, watcher)
+	output := new(bytes.Buffer)
+
+	// when
+	err := service.Start(output)
+
+	// then
+	assert.Nil(t, err)
+
+	// the secrets are the last block, after the packet
+	var blockTypes []uint32
+	for data := output.Bytes(); len(data) > 0; data = data[binary.LittleEndian.Uint32(data[4:8]):] {
+		blockTypes = append(blockTypes, binary.LittleEndian.Uint32(data[0:4]))
+	}
+	assert.Equal(t, []uint32{0x0a0d0d0a, 0x00000001, 0x00000006, 0x0000000a}, blockTypes)
+	lastBlock := len(output.Bytes()) - int(binary.LittleEndian.Uint32(output.Bytes()[len(output.Bytes())-4:]))
+	assert.True(t, strings.Index(output.String(), "CLIENT_RANDOM 0102 0304\n") > lastBlock)
+}
@@ -3,7 +3,9 @@ package sniffer
 import (
 	"bytes"
 	"io"
+	"strings"
 
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
 	"ksniff/kube"
@@ -20,6 +22,9 @@ type PrivilegedPodSnifferService struct {
 	runtimeBridge           runtime.ContainerRuntimeBridge
 }
 
+// privilegedPodPidFilePath records the pid of tcpdump in the privileged pod, which is dedicated to a single capture.
+const privilegedPodPidFilePath = "/tmp/ksniff-tcpdump.pid"
+
 func NewPrivilegedPodRemoteSniffingService(options *config.KsniffSettings, service kube.KubernetesApiService, bridge runtime.ContainerRuntimeBridge) SnifferService {
 	return &PrivilegedPodSnifferService{settings: options, privilegedContainerName: "ksniff-privileged", kubernetesApiService: service, runtimeBridge: bridge}
 }
@@ -33,10 +38,6 @@ func (p *PrivilegedPodSnifferService) Setup() error {
 		p.settings.Image = p.runtimeBridge.GetDefaultImage()
 	}
 
-	if p.settings.UseDefaultTCPDumpImage {
-		p.settings.TCPDumpImage = p.runtimeBridge.GetDefaultTCPImage()
-	}
-
 	if p.settings.UseDefaultSocketPath {
 		p.settings.SocketPath = p.runtimeBridge.GetDefaultSocketPath()
 	}
@@ -55,38 +56,34 @@ func (p *PrivilegedPodSnifferService) Setup() error {
 
 	log.Infof("pod: '%s' created successfully on node: '%s'", p.privilegedPod.Name, p.settings.DetectedPodNodeName)
 
-	if p.runtimeBridge.NeedsPid() {
-		var buff bytes.Buffer
-		command := p.runtimeBridge.BuildInspectCommand(p.settings.DetectedContainerId)
-		exitCode, err := p.kubernetesApiService.ExecuteCommand(p.privilegedPod.Name, p.privilegedContainerName, command, &buff)
-		if err != nil {
-			log.WithError(err).Errorf("failed to start sniffing using privileged pod, exit code: '%d'", exitCode)
-		}
-		p.targetProcessId, err = p.runtimeBridge.ExtractPid(buff.String())
-		if err != nil {
-			return err
-		}
+	var buff bytes.Buffer
+	stdErr := new(kube.Writer)
+	command := p.runtimeBridge.BuildInspectCommand(p.settings.DetectedContainerId, p.settings.SocketPath)
+	exitCode, err := p.kubernetesApiService.ExecuteCommandWithStdErr(p.privilegedPod.Name, p.privilegedContainerName,
+		command, &buff, stdErr)
+	if err != nil {
+		return errors.Wrap(err, "failed to inspect target container using privileged pod")
 	}
 
-	return nil
-}
-
-func (p *PrivilegedPodSnifferService) Cleanup() error {
-	log.Infof("removing privileged container: '%s'", p.privilegedContainerName)
-
-	command := p.runtimeBridge.BuildCleanupCommand()
+	if exitCode != 0 {
+		return errors.Errorf("failed to inspect target container using privileged pod, exit code: '%d', stdErr: '%s'",
+			exitCode, strings.TrimSpace(stdErr.Output))
+	}
 
-	exitCode, err := p.kubernetesApiService.ExecuteCommand(p.privilegedPod.Name, p.privilegedContainerName, command, &kube.NopWriter{})
+	p.targetProcessId, err = p.runtimeBridge.ExtractPid(buff.String())
 	if err != nil {
-		log.WithError(err).Errorf("failed to remove privileged container: '%s', exit code: '%d', "+
-			"please manually remove it", p.privilegedContainerName, exitCode)
-	} else {
-		log.Infof("privileged container: '%s' removed successfully", p.privilegedContainerName)
+		return err
 	}
 
+	log.Infof("target container process id: '%s'", *p.targetProcessId)
+
+	return nil
+}
+
+func (p *PrivilegedPodSnifferService) Cleanup() error {
 	log.Infof("removing pod: '%s'", p.privilegedPod.Name)
 
-	err = p.kubernetesApiService.DeletePod(p.privilegedPod.Name)
+	err := p.kubernetesApiService.DeletePod(p.privilegedPod.Name)
 	if err != nil {
 		log.WithError(err).Errorf("failed to remove pod: '%s", p.privilegedPod.Name)
 		return err
@@ -100,22 +97,35 @@ func (p *PrivilegedPodSnifferService) Cleanup() error {
 func (p *PrivilegedPodSnifferService) Start(stdOut io.Writer) error {
 	log.Info("starting remote sniffing using privileged pod")
 
-	command := p.runtimeBridge.BuildTcpdumpCommand(
-		&p.settings.DetectedContainerId,
-		p.settings.UserSpecifiedInterface,
-		p.settings.UserSpecifiedFilter,
-		p.targetProcessId,
-		p.settings.SocketPath,
-		p.settings.TCPDumpImage,
-	)
+	command := wrapWithPidFile(runtime.BuildTcpdumpCommand(*p.targetProcessId, p.settings.UserSpecifiedInterface,
+		p.settings.UserSpecifiedFilter), privilegedPodPidFilePath)
 
-	exitCode, err := p.kubernetesApiService.ExecuteCommand(p.privilegedPod.Name, p.privilegedContainerName, command, stdOut)
-	if err != nil {
+	stdErr := new(kube.Writer)
+
+	exitCode, err := p.kubernetesApiService.ExecuteCommandWithStdErr(p.privilegedPod.Name, p.privilegedContainerName, command, stdOut, stdErr)
+	log.Debugf("tcpdump stderr: '%s'", stdErr.Output)
+	if err != nil || exitCode != 0 {
 		log.WithError(err).Errorf("failed to start sniffing using privileged pod, exit code: '%d'", exitCode)
-		return err
+		return NewTcpdumpError(exitCode, stdErr.Output, err)
 	}
 
 	log.Info("remote sniffing using privileged pod completed")
 
 	return nil
 }
+
+func (p *PrivilegedPodSnifferService) Stop() error {
+	log.Info("stopping tcpdump on privileged pod")
+
+	exitCode, err := p.kubernetesApiService.ExecuteCommand(p.privilegedPod.Name, p.privilegedContainerName,
+		buildStopCommand(privilegedPodPidFilePath), &kube.NopWriter{})
+	if err != nil {
+		return err
+	}
+
+	if exitCode != 0 {
+		return errors.Errorf("failed to stop tcpdump, exit code: '%d'", exitCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package sniffer
+
+import (
+	"testing"
+
+	"ksniff/pkg/config"
+	"ksniff/pkg/service/sniffer/runtime"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivilegedPodSetup_InspectFailure(t *testing.T) {
+	// given
+	bridge, err := runtime.NewContainerRuntimeBridge("containerd")
+	assert.Nil(t, err)
+	kubernetesApiService := &fakeKubernetesApiService{tcpdumpStdErr: "container not found"}
+	service := NewPrivilegedPodRemoteSniffingService(&config.KsniffSettings{DetectedContainerId: "abc"},
+		kubernetesApiService, bridge)
+
+	// when
+	err = service.Setup()
+
+	// then
+	assert.EqualError(t, err,
+		"failed to inspect target container using privileged pod, exit code: '1', stdErr: 'container not found'")
+}
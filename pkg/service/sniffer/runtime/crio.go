@@ -14,13 +14,8 @@ func NewCrioBridge() *CrioBridge {
 	return &CrioBridge{}
 }
 
-func (c *CrioBridge) NeedsPid() bool {
-	return true
-}
-
-func (c *CrioBridge) BuildInspectCommand(containerId string) []string {
-	return []string{"chroot", "/host", "crictl", "inspect",
-		"--output", "json", containerId}
+func (c *CrioBridge) BuildInspectCommand(containerId string, socketPath string) []string {
+	return buildHelperInspectCommand("cri", containerId, socketPath)
 }
 
 func (c *CrioBridge) ExtractPid(inspection string) (*string, error) {
@@ -52,16 +47,8 @@ func (c *CrioBridge) ExtractPid(inspection string) (*string, error) {
 	return &ret, nil
 }
 
-func (c *CrioBridge) BuildTcpdumpCommand(containerId *string, netInterface string, filter string, pid *string, socketPath string, tcpdumpImage string) []string {
-	return []string{"nsenter", "-n", "-t", *pid, "--", "tcpdump", "-i", netInterface, "-U", "-w", "-", filter}
-}
-
-func (c *CrioBridge) BuildCleanupCommand() []string {
-	return nil // No cleanup needed
-}
-
 func (c *CrioBridge) GetDefaultImage() string {
-	return "maintained/tcpdump"
+	return helperImage
 }
 
 func (c *CrioBridge) GetDefaultSocketPath() string {
@@ -87,7 +74,3 @@ func extractPidCrio118(partial map[string]json.RawMessage) (float64, error) {
 	}
 	return result["pid"].(float64), nil
 }
-
-func (d *CrioBridge) GetDefaultTCPImage() string {
-	return ""
-}
\ No newline at end of file
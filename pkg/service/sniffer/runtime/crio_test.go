@@ -32,6 +32,19 @@ var (
   }
 `
 
+	CRICTL_INSPECT_WITH_PID_CONMON_RS = `
+{
+  "status": {},
+  "info": {
+    "sandboxID": "549eb241ba685900fc152501be3c2c31b19e9d649c01f00496b58375e570da52",
+    "pid": 1204,
+    "runtimeSpec": {
+      "annotations": {"io.kubernetes.cri-o.RuntimeHandler": "conmon-rs"}
+    }
+  }
+}
+`
+
 	CRICTL_INSPECT_NO_PID_118 = `
 {
   "status": {},
@@ -113,3 +126,39 @@ func TestExtractPid_Valid118(t *testing.T) {
 	assert.Equal(t, "827137", *result)
 	assert.Nil(t, err)
 }
+
+func TestExtractPid_KsniffHelper(t *testing.T) {
+	// given
+	bridge := NewCrioBridge()
+
+	// when
+	result, err := bridge.ExtractPid(`{"pid":827137,"netns":"/proc/827137/ns/net"}`)
+
+	// then
+	assert.Equal(t, "827137", *result)
+	assert.Nil(t, err)
+}
+
+func TestBuildInspectCommand(t *testing.T) {
+	// given
+	bridge := NewCrioBridge()
+
+	// when
+	result := bridge.BuildInspectCommand("container", "/var/run/crio/crio.sock")
+
+	// then
+	assert.Equal(t, []string{"ksniff-helper", "--runtime-endpoint", "unix:///var/run/crio/crio.sock",
+		"--api", "cri", "inspect", "--output", "json", "container"}, result)
+}
+
+func TestExtractPid_ValidConmonRs(t *testing.T) {
+	// given
+	bridge := NewCrioBridge()
+
+	// when
+	result, err := bridge.ExtractPid(CRICTL_INSPECT_WITH_PID_CONMON_RS)
+
+	// then
+	assert.Equal(t, "1204", *result)
+	assert.Nil(t, err)
+}
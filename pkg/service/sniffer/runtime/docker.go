@@ -1,59 +1,32 @@
 package runtime
 
-import (
-	"fmt"
-
-	"ksniff/utils"
-)
-
+// DockerBridge resolves containers using the docker api, which is also served by the docker engine wrapped by
+// cri-dockerd and by the podman system service.
 type DockerBridge struct {
-	tcpdumpContainerName string
-	cleanupCommand       []string
+	socketPath string
 }
 
 func NewDockerBridge() *DockerBridge {
-	return &DockerBridge{}
+	return &DockerBridge{socketPath: "/var/run/docker.sock"}
 }
 
-func (d *DockerBridge) NeedsPid() bool {
-	return false
+// NewPodmanBridge resolves containers using the docker compatible api of the podman system service.
+func NewPodmanBridge() *DockerBridge {
+	return &DockerBridge{socketPath: "/run/podman/podman.sock"}
 }
 
-func (d *DockerBridge) BuildInspectCommand(string) []string {
-	panic("Docker doesn't need this implemented")
+func (d *DockerBridge) BuildInspectCommand(containerId string, socketPath string) []string {
+	return buildHelperInspectCommand("docker", containerId, socketPath)
 }
 
 func (d *DockerBridge) ExtractPid(inspection string) (*string, error) {
-	panic("Docker doesn't need this implemented")
-}
-
-func (d *DockerBridge) BuildTcpdumpCommand(containerId *string, netInterface string, filter string, pid *string, socketPath string, tcpdumpImage string) []string {
-	d.tcpdumpContainerName = "ksniff-container-" + utils.GenerateRandomString(8)
-	containerNameFlag := fmt.Sprintf("--name=%s", d.tcpdumpContainerName)
-
-	command := []string{"docker", "--host", "unix://" + socketPath,
-		"run", "--rm", containerNameFlag,
-		fmt.Sprintf("--net=container:%s", *containerId), tcpdumpImage, "-i",
-		netInterface, "-U", "-w", "-", filter}
-
-	d.cleanupCommand = []string{"docker", "--host", "unix://" + socketPath,
-		"rm", "-f", d.tcpdumpContainerName}
-
-	return command
-}
-
-func (d *DockerBridge) BuildCleanupCommand() []string {
-	return d.cleanupCommand
+	return extractHelperPid(inspection)
 }
 
 func (d *DockerBridge) GetDefaultImage() string {
-	return "docker"
-}
-
-func (d *DockerBridge) GetDefaultTCPImage() string {
-	return "maintained/tcpdump"
+	return helperImage
 }
 
 func (d *DockerBridge) GetDefaultSocketPath() string {
-	return "/var/run/docker.sock"
+	return d.socketPath
 }
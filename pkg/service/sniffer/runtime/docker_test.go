@@ -6,39 +6,138 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func TestExtractPid(t *testing.T) {
+func TestDockerExtractPid_Empty(t *testing.T) {
+	// given
 	bridge := NewDockerBridge()
-	assert.Panics(t, func() { bridge.ExtractPid("") })
+
+	// when
+	result, err := bridge.ExtractPid("")
+
+	// then
+	assert.Nil(t, result)
+	assert.NotNil(t, err)
 }
 
-func TestInspectCommand(t *testing.T) {
+func TestDockerExtractPid_NoPid(t *testing.T) {
+	// given
 	bridge := NewDockerBridge()
-	assert.Panics(t, func() { bridge.BuildInspectCommand("") })
+
+	// when
+	result, err := bridge.ExtractPid(`{"netns":"/var/run/docker/netns/1f5b4e8a7d21"}`)
+
+	// then
+	assert.Nil(t, result)
+	assert.NotNil(t, err)
 }
 
-func TestPrivilegedPodName(t *testing.T) {
+func TestDockerExtractPid_Valid(t *testing.T) {
+	// given
 	bridge := NewDockerBridge()
-	var containerId = "container"
-	var netInterface = "eth0"
-	var filter = "filter"
-	var pid = "pid"
-	var path = "/path"
-	var tcpdumpImage = bridge.GetDefaultTCPImage()
-	bridge.BuildTcpdumpCommand(&containerId, netInterface, filter, &pid, path, tcpdumpImage)
-	assert.NotEqual(t, "", bridge.tcpdumpContainerName, "tcpdumpContainerName should have been set")
+
+	// when
+	result, err := bridge.ExtractPid(`{"pid":4242,"netns":"/var/run/docker/netns/1f5b4e8a7d21"}`)
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, "4242", *result)
+}
+
+func TestPodmanExtractPid_Empty(t *testing.T) {
+	// given
+	bridge := NewPodmanBridge()
+
+	// when
+	result, err := bridge.ExtractPid("")
+
+	// then
+	assert.Nil(t, result)
+	assert.NotNil(t, err)
+}
+
+func TestPodmanExtractPid_NoPid(t *testing.T) {
+	// given
+	bridge := NewPodmanBridge()
+
+	// when
+	result, err := bridge.ExtractPid(`{"netns":"/run/netns/netns-3b5f6a0e-9c1d-4f7e-8a2b-6d4c1e0f9a87"}`)
+
+	// then
+	assert.Nil(t, result)
+	assert.NotNil(t, err)
+}
+
+func TestPodmanExtractPid_Valid(t *testing.T) {
+	// given
+	bridge := NewPodmanBridge()
+
+	// when
+	result, err := bridge.ExtractPid(`{"pid":3187,"netns":"/run/netns/netns-3b5f6a0e-9c1d-4f7e-8a2b-6d4c1e0f9a87"}`)
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, "3187", *result)
 }
 
-func TestCleanupCommand(t *testing.T) {
+func TestCriDockerdExtractPid_Empty(t *testing.T) {
+	// given
+	bridge, err := NewContainerRuntimeBridge("cri-dockerd")
+	assert.Nil(t, err)
+
+	// when
+	result, err := bridge.ExtractPid("")
+
+	// then
+	assert.Nil(t, result)
+	assert.NotNil(t, err)
+}
+
+func TestCriDockerdExtractPid_NoPid(t *testing.T) {
+	// given
+	bridge, err := NewContainerRuntimeBridge("cri-dockerd")
+	assert.Nil(t, err)
+
+	// when
+	result, err := bridge.ExtractPid(`{"netns":""}`)
+
+	// then
+	assert.Nil(t, result)
+	assert.NotNil(t, err)
+}
+
+// the containers of a cri-dockerd pod share the network namespace of its sandbox container.
+func TestCriDockerdExtractPid_Valid(t *testing.T) {
+	// given
+	bridge, err := NewContainerRuntimeBridge("cri-dockerd")
+	assert.Nil(t, err)
+
+	// when
+	result, err := bridge.ExtractPid(`{"pid":5120,"netns":"/proc/5120/ns/net"}`)
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, "5120", *result)
+}
+
+func TestDockerInspectCommand(t *testing.T) {
+	// given
 	bridge := NewDockerBridge()
-	var containerId = "container"
-	var netInterface = "eth0"
-	var filter = "filter"
-	var pid = "pid"
-	var socketPath = "/path"
-	var tcpdumpImage = bridge.GetDefaultTCPImage()
-	bridge.BuildTcpdumpCommand(&containerId, netInterface, filter, &pid, socketPath, tcpdumpImage)
-	assert.Equal(t,
-		[]string{"docker", "--host", "unix://" + socketPath, "rm", "-f", bridge.tcpdumpContainerName},
-		bridge.BuildCleanupCommand(),
-		"container cleanup command doesn't match")
+
+	// when
+	result := bridge.BuildInspectCommand("container", bridge.GetDefaultSocketPath())
+
+	// then
+	assert.Equal(t, []string{"ksniff-helper", "--runtime-endpoint", "unix:///var/run/docker.sock",
+		"--api", "docker", "inspect", "--output", "json", "container"}, result)
+}
+
+func TestPodmanInspectCommand(t *testing.T) {
+	// given
+	bridge := NewPodmanBridge()
+
+	// when
+	result := bridge.BuildInspectCommand("container", bridge.GetDefaultSocketPath())
+
+	// then
+	assert.Equal(t, []string{"ksniff-helper", "--runtime-endpoint", "unix:///run/podman/podman.sock",
+		"--api", "docker", "inspect", "--output", "json", "container"}, result)
 }
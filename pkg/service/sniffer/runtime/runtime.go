@@ -1,33 +1,134 @@
 package runtime
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// helperImage is built from Dockerfile.helper and published by the helper-image workflow, it contains tcpdump,
+// nsenter and the ksniff-helper CRI client. The Makefile sets it to its HELPER_IMAGE, in the namespace of the
+// repository owner publishing the image.
+var helperImage = "ghcr.io/eldadru/ksniff-helper:v4"
 
 var SupportedContainerRuntimes = []string{
 	"docker",
 	"cri-o",
 	"containerd",
+	"podman",
 }
 
+// runtimeAliases maps alternative runtime names, as reported by the container id scheme
+// or by the node runtime version, to one of the SupportedContainerRuntimes.
+// cri-dockerd wraps the docker engine, which is queried directly.
+var runtimeAliases = map[string]string{
+	"cri-containerd": "containerd",
+	"cri-dockerd":    "docker",
+	"crio":           "cri-o",
+	"libpod":         "podman",
+}
+
+// ContainerRuntimeBridge resolves the process id of a target container using the node container runtime,
+// the capture itself is runtime agnostic, see BuildTcpdumpCommand.
 type ContainerRuntimeBridge interface {
-	NeedsPid() bool
-	BuildInspectCommand(containerId string) []string
+	BuildInspectCommand(containerId string, socketPath string) []string
 	ExtractPid(inspection string) (*string, error)
-	BuildTcpdumpCommand(containerId *string, netInterface string, filter string, pid *string, socketPath string, tcpdumpImage string) []string
-	BuildCleanupCommand() []string
 	GetDefaultImage() string
-	GetDefaultTCPImage() string
 	GetDefaultSocketPath() string
 }
 
-func NewContainerRuntimeBridge(runtimeName string) ContainerRuntimeBridge {
-	switch runtimeName {
+// BuildTcpdumpCommand runs tcpdump from the privileged pod inside the network namespace of the target process,
+// the privileged pod shares the host pid namespace so no additional container is required.
+func BuildTcpdumpCommand(pid string, netInterface string, filter string) []string {
+	return []string{"nsenter", "-n", "-t", pid, "--", "tcpdump", "-i", netInterface, "-U", "-w", "-", filter}
+}
+
+func buildHelperInspectCommand(runtimeApi string, containerId string, socketPath string) []string {
+	return []string{"ksniff-helper", "--runtime-endpoint", "unix://" + socketPath, "--api", runtimeApi, "inspect",
+		"--output", "json", containerId}
+}
+
+// extractHelperPid extracts the pid from the json output of 'ksniff-helper inspect'.
+func extractHelperPid(inspection string) (*string, error) {
+	var result struct {
+		Pid int `json:"pid"`
+	}
+
+	if err := json.Unmarshal([]byte(inspection), &result); err != nil {
+		return nil, err
+	}
+
+	if result.Pid <= 0 {
+		return nil, errors.New("container inspection doesn't contain a pid")
+	}
+
+	ret := strconv.Itoa(result.Pid)
+	return &ret, nil
+}
+
+// UnsupportedContainerRuntimeError is returned when the container runtime of a target container
+// can't be mapped to any of the SupportedContainerRuntimes.
+type UnsupportedContainerRuntimeError struct {
+	ContainerIdScheme  string
+	NodeRuntimeVersion string
+}
+
+func (e *UnsupportedContainerRuntimeError) Error() string {
+	return fmt.Sprintf("unsupported container runtime (container id scheme: '%s', node runtime version: '%s'), "+
+		"supported container runtimes are: %v", e.ContainerIdScheme, e.NodeRuntimeVersion, SupportedContainerRuntimes)
+}
+
+// NormalizeContainerRuntimeName resolves a runtime name or one of its aliases to a supported runtime name.
+func NormalizeContainerRuntimeName(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	if alias, ok := runtimeAliases[name]; ok {
+		name = alias
+	}
+
+	for _, runtime := range SupportedContainerRuntimes {
+		if name == runtime {
+			return runtime, true
+		}
+	}
+
+	return "", false
+}
+
+// DetectContainerRuntime combines the scheme of a container id (e.g. 'containerd://<id>') with the
+// node runtime version (e.g. 'containerd://1.4.3') to select the runtime to use.
+// The node runtime version is preferred as it describes the CRI implementation actually running on the node.
+func DetectContainerRuntime(containerIdScheme string, nodeRuntimeVersion string) (string, error) {
+	nodeRuntimeName := strings.SplitN(nodeRuntimeVersion, "://", 2)[0]
+
+	if runtime, ok := NormalizeContainerRuntimeName(nodeRuntimeName); ok {
+		return runtime, nil
+	}
+
+	if runtime, ok := NormalizeContainerRuntimeName(containerIdScheme); ok {
+		return runtime, nil
+	}
+
+	return "", &UnsupportedContainerRuntimeError{ContainerIdScheme: containerIdScheme, NodeRuntimeVersion: nodeRuntimeVersion}
+}
+
+func NewContainerRuntimeBridge(runtimeName string) (ContainerRuntimeBridge, error) {
+	runtime, ok := NormalizeContainerRuntimeName(runtimeName)
+	if !ok {
+		return nil, &UnsupportedContainerRuntimeError{ContainerIdScheme: runtimeName}
+	}
+
+	switch runtime {
 	case "docker":
-		return NewDockerBridge()
+		return NewDockerBridge(), nil
 	case "cri-o":
-		return NewCrioBridge()
-	case "containerd":
-		return NewContainerdBridge()
+		return NewCrioBridge(), nil
+	case "podman":
+		return NewPodmanBridge(), nil
 	default:
-		panic(fmt.Sprintf("Unable to build bridge to %s", runtimeName))
+		return NewContainerdBridge(), nil
 	}
 }
@@ -7,15 +7,108 @@ import (
 )
 
 func TestNewContainerRuntimeBridge_Docker(t *testing.T) {
-	bridge := NewContainerRuntimeBridge("docker")
+	bridge, err := NewContainerRuntimeBridge("docker")
+	assert.Nil(t, err)
 	assert.IsType(t, &DockerBridge{}, bridge)
 }
 
 func TestNewContainerRuntimeBridge_Crio(t *testing.T) {
-	bridge := NewContainerRuntimeBridge("cri-o")
+	bridge, err := NewContainerRuntimeBridge("cri-o")
+	assert.Nil(t, err)
 	assert.IsType(t, &CrioBridge{}, bridge)
 }
 
+func TestNewContainerRuntimeBridge_Containerd(t *testing.T) {
+	bridge, err := NewContainerRuntimeBridge("containerd")
+	assert.Nil(t, err)
+	assert.IsType(t, &ContainerdBridge{}, bridge)
+}
+
+func TestNewContainerRuntimeBridge_CriDockerd(t *testing.T) {
+	bridge, err := NewContainerRuntimeBridge("cri-dockerd")
+	assert.Nil(t, err)
+	assert.IsType(t, &DockerBridge{}, bridge)
+	assert.Equal(t, "/var/run/docker.sock", bridge.GetDefaultSocketPath())
+}
+
+func TestNewContainerRuntimeBridge_Podman(t *testing.T) {
+	bridge, err := NewContainerRuntimeBridge("podman")
+	assert.Nil(t, err)
+	assert.IsType(t, &DockerBridge{}, bridge)
+	assert.Equal(t, "/run/podman/podman.sock", bridge.GetDefaultSocketPath())
+}
+
+func TestNewContainerRuntimeBridge_Alias(t *testing.T) {
+	bridge, err := NewContainerRuntimeBridge("cri-containerd")
+	assert.Nil(t, err)
+	assert.IsType(t, &ContainerdBridge{}, bridge)
+}
+
 func TestNewContainerRuntimeBridge_Invalid(t *testing.T) {
-	assert.Panics(t, func() { NewContainerRuntimeBridge("i-do-not-exist") })
+	bridge, err := NewContainerRuntimeBridge("i-do-not-exist")
+	assert.Nil(t, bridge)
+	assert.IsType(t, &UnsupportedContainerRuntimeError{}, err)
+}
+
+func TestDetectContainerRuntime_NodeRuntimeVersion(t *testing.T) {
+	// when
+	result, err := DetectContainerRuntime("docker", "containerd://1.4.3")
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, "containerd", result)
+}
+
+func TestDetectContainerRuntime_NodeRuntimeVersionAlias(t *testing.T) {
+	// when
+	result, err := DetectContainerRuntime("docker", "cri-dockerd://0.2.0")
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, "docker", result)
+}
+
+func TestDetectContainerRuntime_NodeRuntimeVersionContainerdAlias(t *testing.T) {
+	// when
+	result, err := DetectContainerRuntime("docker", "cri-containerd://1.2.13")
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, "containerd", result)
+}
+
+// cri-o running containers with the conmon-rs runtime handler still reports itself as cri-o.
+func TestDetectContainerRuntime_CrioConmonRs(t *testing.T) {
+	// when
+	result, err := DetectContainerRuntime("cri-o", "cri-o://1.26.1")
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, "cri-o", result)
+}
+
+func TestDetectContainerRuntime_FallbackToContainerIdScheme(t *testing.T) {
+	// when
+	result, err := DetectContainerRuntime("cri-o", "")
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, "cri-o", result)
+}
+
+func TestDetectContainerRuntime_Unsupported(t *testing.T) {
+	// when
+	result, err := DetectContainerRuntime("rkt", "rkt://1.30.0")
+
+	// then
+	assert.Equal(t, "", result)
+	assert.Equal(t, &UnsupportedContainerRuntimeError{ContainerIdScheme: "rkt", NodeRuntimeVersion: "rkt://1.30.0"}, err)
+}
+
+func TestBuildTcpdumpCommand(t *testing.T) {
+	// when
+	result := BuildTcpdumpCommand("4242", "eth0", "port 80")
+
+	// then
+	assert.Equal(t, []string{"nsenter", "-n", "-t", "4242", "--", "tcpdump", "-i", "eth0", "-U", "-w", "-", "port 80"}, result)
 }
@@ -0,0 +1,45 @@
+package runtime
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sandboxedRuntimeHandlers maps known RuntimeClass handler name fragments to the sandbox they run.
+// Sandboxed pods don't expose their network stack through a host visible process network namespace,
+// so the privileged pod can't capture their traffic.
+var sandboxedRuntimeHandlers = []struct {
+	fragment string
+	sandbox  string
+}{
+	{fragment: "runsc", sandbox: "gVisor"},
+	{fragment: "gvisor", sandbox: "gVisor"},
+	{fragment: "kata", sandbox: "Kata Containers"},
+}
+
+// DetectSandboxedRuntime returns the name of the sandbox used by the given RuntimeClass handler, if any.
+func DetectSandboxedRuntime(handler string) (string, bool) {
+	handler = strings.ToLower(handler)
+
+	for _, sandboxedRuntime := range sandboxedRuntimeHandlers {
+		if strings.Contains(handler, sandboxedRuntime.fragment) {
+			return sandboxedRuntime.sandbox, true
+		}
+	}
+
+	return "", false
+}
+
+// SandboxedRuntimeError is returned when privileged mode is requested for a pod running in a sandbox.
+type SandboxedRuntimeError struct {
+	RuntimeClassName string
+	Handler          string
+	Sandbox          string
+}
+
+func (e *SandboxedRuntimeError) Error() string {
+	return fmt.Sprintf("pod runs in a %s sandbox (runtime class: '%s', handler: '%s'), its network namespace "+
+		"isn't visible from the node so privileged mode can't capture its traffic, "+
+		"use the static tcpdump mode (without -p) to capture from inside the sandbox",
+		e.Sandbox, e.RuntimeClassName, e.Handler)
+}
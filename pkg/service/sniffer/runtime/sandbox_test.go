@@ -0,0 +1,34 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectSandboxedRuntime_GVisor(t *testing.T) {
+	// when
+	result, ok := DetectSandboxedRuntime("runsc")
+
+	// then
+	assert.True(t, ok)
+	assert.Equal(t, "gVisor", result)
+}
+
+func TestDetectSandboxedRuntime_Kata(t *testing.T) {
+	// when
+	result, ok := DetectSandboxedRuntime("kata-qemu")
+
+	// then
+	assert.True(t, ok)
+	assert.Equal(t, "Kata Containers", result)
+}
+
+func TestDetectSandboxedRuntime_NotSandboxed(t *testing.T) {
+	// when
+	result, ok := DetectSandboxedRuntime("runc")
+
+	// then
+	assert.False(t, ok)
+	assert.Equal(t, "", result)
+}
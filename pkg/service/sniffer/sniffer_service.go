@@ -14,4 +14,17 @@ type SnifferService interface {
 	// Start remote sniffing
 	// write remote capture output to the given io writer.
 	Start(stdOut io.Writer) error
+
+	// Stop the remote sniffing started by Start, which then returns.
+	Stop() error
+}
+
+// wrapWithPidFile runs the command using a shell which records its pid to the pid file, allowing to stop it later.
+func wrapWithPidFile(command []string, pidFilePath string) []string {
+	return append([]string{"/bin/sh", "-c", `echo $$ > "$0" && exec "$@"`, pidFilePath}, command...)
+}
+
+// buildStopCommand returns a command stopping the process whose pid was recorded to the pid file.
+func buildStopCommand(pidFilePath string) []string {
+	return []string{"/bin/sh", "-c", `[ -f "$0" ] && kill "$(cat "$0")" 2>/dev/null; rm -f "$0"`, pidFilePath}
 }
@@ -1,9 +1,12 @@
 package sniffer
 
 import (
+	"fmt"
 	"io"
 	"ksniff/kube"
 	"ksniff/pkg/config"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -12,45 +15,205 @@ import (
 type StaticTcpdumpSnifferService struct {
 	settings             *config.KsniffSettings
 	kubernetesApiService kube.KubernetesApiService
+	tcpdumpUploaded      bool
+	pidFilePath          string
 }
 
 func NewUploadTcpdumpRemoteSniffingService(options *config.KsniffSettings, service kube.KubernetesApiService) SnifferService {
 	return &StaticTcpdumpSnifferService{settings: options, kubernetesApiService: service}
 }
 
+// buildTcpdumpPidFilePath returns a pid file path next to the tcpdump binary, unique to this capture so concurrent
+// captures sharing the same binary don't stop each other.
+func buildTcpdumpPidFilePath(tcpdumpPath string) string {
+	return fmt.Sprintf("%s-%d.pid", tcpdumpPath, time.Now().UnixNano())
+}
+
+// buildTcpdumpCommand returns the remote tcpdump command, when a pid file is given tcpdump is started using the
+// container shell which records its pid to allow stopping it.
+func buildTcpdumpCommand(tcpdumpPath string, netInterface string, filter string, pidFilePath string) []string {
+	command := []string{tcpdumpPath, "-i", netInterface, "-U", "-w", "-", filter}
+	if pidFilePath == "" {
+		return command
+	}
+
+	return wrapWithPidFile(command, pidFilePath)
+}
+
+// binaryInUse is written by the remove binary command when the binary is kept for another capture.
+const binaryInUse = "in-use"
+
+// buildRemoveBinaryCommand returns a command removing the tcpdump binary, unless another capture sharing the binary
+// is still running, as recorded by its pid file next to the binary.
+func buildRemoveBinaryCommand(tcpdumpPath string) []string {
+	return []string{"/bin/sh", "-c", `for f in "$0"-*.pid; do [ -f "$f" ] && kill -0 "$(cat "$f")" 2>/dev/null && ` +
+		`echo ` + binaryInUse + ` && exit 0; done; rm -f "$0"`, tcpdumpPath}
+}
+
+// checkRemoteCapabilities verifies the effective capabilities of a process started on the container include
+// NET_RAW, the check is skipped when the process status can't be read (e.g. no cat nor shell).
+func (u *StaticTcpdumpSnifferService) checkRemoteCapabilities() error {
+	commands := [][]string{
+		{"cat", "/proc/self/status"},
+		{"/bin/sh", "-c", `while read -r line; do echo "$line"; done < /proc/self/status`},
+	}
+
+	for _, command := range commands {
+		stdOut := new(kube.Writer)
+
+		exitCode, err := u.kubernetesApiService.ExecuteCommand(u.settings.UserSpecifiedPodName, u.settings.UserSpecifiedContainer, command, stdOut)
+		if err != nil || exitCode != 0 {
+			continue
+		}
+
+		capabilities, err := ParseEffectiveCapabilities(stdOut.Output)
+		if err != nil {
+			log.WithError(err).Debug("failed to parse remote process capabilities")
+			return nil
+		}
+
+		log.Debugf("remote process effective capabilities: '%016x'", capabilities)
+
+		if !HasNetRawCapability(capabilities) {
+			return &MissingCapabilityError{ContainerName: u.settings.UserSpecifiedContainer,
+				Reason: "its processes don't have the NET_RAW capability (CapEff)"}
+		}
+
+		return nil
+	}
+
+	log.Debug("unable to read remote process capabilities, skipping capabilities check")
+
+	return nil
+}
+
 func (u *StaticTcpdumpSnifferService) Setup() error {
-	log.Infof("uploading static tcpdump binary from: '%s' to: '%s'",
-		u.settings.UserSpecifiedLocalTcpdumpPath, u.settings.UserSpecifiedRemoteTcpdumpPath)
+	if err := u.checkRemoteCapabilities(); err != nil {
+		return err
+	}
 
-	err := u.kubernetesApiService.UploadFile(u.settings.UserSpecifiedLocalTcpdumpPath,
-		u.settings.UserSpecifiedRemoteTcpdumpPath, u.settings.UserSpecifiedPodName, u.settings.UserSpecifiedContainer)
+	if u.settings.UseDefaultRemoteTcpdumpPath {
+		checksums, err := kube.ComputeUploadChecksums(u.settings.UserSpecifiedLocalTcpdumpPath, u.settings.EmbeddedTcpdumpBinary)
+		if err != nil {
+			return err
+		}
+
+		u.settings.UserSpecifiedRemoteTcpdumpPath = kube.ContentAddressedPath(u.settings.UserSpecifiedRemoteTcpdumpPath, checksums)
+	}
+
+	var err error
+
+	if u.settings.EmbeddedTcpdumpBinary != nil {
+		log.Infof("uploading embedded static tcpdump binary to: '%s'", u.settings.UserSpecifiedRemoteTcpdumpPath)
+
+		u.tcpdumpUploaded, err = u.kubernetesApiService.UploadFileContent(u.settings.EmbeddedTcpdumpBinary,
+			u.settings.UserSpecifiedRemoteTcpdumpPath, u.settings.UserSpecifiedPodName, u.settings.UserSpecifiedContainer)
+	} else {
+		log.Infof("uploading static tcpdump binary from: '%s' to: '%s'",
+			u.settings.UserSpecifiedLocalTcpdumpPath, u.settings.UserSpecifiedRemoteTcpdumpPath)
+
+		u.tcpdumpUploaded, err = u.kubernetesApiService.UploadFile(u.settings.UserSpecifiedLocalTcpdumpPath,
+			u.settings.UserSpecifiedRemoteTcpdumpPath, u.settings.UserSpecifiedPodName, u.settings.UserSpecifiedContainer)
+	}
 
 	if err != nil {
-		log.WithError(err).Errorf("failed uploading static tcpdump binary to container, please verify the remote container has tar installed")
+		log.WithError(err).Errorf("failed uploading static tcpdump binary to container, consider using the privileged mode (-p)")
 		return err
 	}
 
 	log.Info("tcpdump uploaded successfully")
 
+	exitCode, err := u.kubernetesApiService.ExecuteCommand(u.settings.UserSpecifiedPodName, u.settings.UserSpecifiedContainer,
+		[]string{"/bin/sh", "-c", "true"}, &kube.NopWriter{})
+	if err == nil && exitCode == 0 {
+		u.pidFilePath = buildTcpdumpPidFilePath(u.settings.UserSpecifiedRemoteTcpdumpPath)
+	} else {
+		log.Warn("no shell found on container, tcpdump might keep running on the container after the capture ends")
+	}
+
 	return nil
 }
 
 func (u *StaticTcpdumpSnifferService) Cleanup() error {
+	if u.pidFilePath != "" {
+		if err := u.Stop(); err != nil {
+			log.WithError(err).Warn("failed to stop tcpdump on remote container")
+		}
+	}
+
+	if !u.tcpdumpUploaded {
+		log.Debugf("tcpdump binary: '%s' wasn't uploaded by ksniff, leaving it in place", u.settings.UserSpecifiedRemoteTcpdumpPath)
+		return nil
+	}
+
+	if u.settings.UserSpecifiedKeepBinary {
+		log.Infof("keeping tcpdump binary: '%s' on remote container", u.settings.UserSpecifiedRemoteTcpdumpPath)
+		return nil
+	}
+
+	log.Infof("removing tcpdump binary: '%s' from remote container", u.settings.UserSpecifiedRemoteTcpdumpPath)
+
+	// the binary can't be checked for other captures without a shell, concurrent captures use --keep-binary instead
+	command := []string{"rm", "-f", u.settings.UserSpecifiedRemoteTcpdumpPath}
+	if u.pidFilePath != "" {
+		command = buildRemoveBinaryCommand(u.settings.UserSpecifiedRemoteTcpdumpPath)
+	}
+
+	stdOut := new(kube.Writer)
+
+	exitCode, err := u.kubernetesApiService.ExecuteCommand(u.settings.UserSpecifiedPodName, u.settings.UserSpecifiedContainer,
+		command, stdOut)
+	if err != nil {
+		return err
+	}
+
+	if exitCode != 0 {
+		return errors.Errorf("failed to remove tcpdump binary: '%s', exit code: '%d'", u.settings.UserSpecifiedRemoteTcpdumpPath, exitCode)
+	}
+
+	if strings.TrimSpace(stdOut.Output) == binaryInUse {
+		log.Infof("keeping tcpdump binary: '%s', another capture is using it", u.settings.UserSpecifiedRemoteTcpdumpPath)
+	}
+
 	return nil
 }
 
 func (u *StaticTcpdumpSnifferService) Start(stdOut io.Writer) error {
 	log.Info("start sniffing on remote container")
 
-	command := []string{u.settings.UserSpecifiedRemoteTcpdumpPath, "-i", u.settings.UserSpecifiedInterface,
-		"-U", "-w", "-", u.settings.UserSpecifiedFilter}
+	command := buildTcpdumpCommand(u.settings.UserSpecifiedRemoteTcpdumpPath, u.settings.UserSpecifiedInterface,
+		u.settings.UserSpecifiedFilter, u.pidFilePath)
+
+	stdErr := new(kube.Writer)
 
-	exitCode, err := u.kubernetesApiService.ExecuteCommand(u.settings.UserSpecifiedPodName, u.settings.UserSpecifiedContainer, command, stdOut)
+	exitCode, err := u.kubernetesApiService.ExecuteCommandWithStdErr(u.settings.UserSpecifiedPodName, u.settings.UserSpecifiedContainer,
+		command, stdOut, stdErr)
+	log.Debugf("tcpdump stderr: '%s'", stdErr.Output)
 	if err != nil || exitCode != 0 {
-		return errors.Errorf("executing sniffer failed, exit code: '%d'", exitCode)
+		return NewTcpdumpError(exitCode, stdErr.Output, err)
 	}
 
 	log.Infof("done sniffing on remote container")
 
 	return nil
 }
+
+func (u *StaticTcpdumpSnifferService) Stop() error {
+	if u.pidFilePath == "" {
+		return errors.New("tcpdump can't be stopped on a container without a shell")
+	}
+
+	log.Info("stopping tcpdump on remote container")
+
+	exitCode, err := u.kubernetesApiService.ExecuteCommand(u.settings.UserSpecifiedPodName, u.settings.UserSpecifiedContainer,
+		buildStopCommand(u.pidFilePath), &kube.NopWriter{})
+	if err != nil {
+		return err
+	}
+
+	if exitCode != 0 {
+		return errors.Errorf("failed to stop tcpdump, exit code: '%d'", exitCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,231 @@
+package sniffer
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"ksniff/pkg/config"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+type fakeKubernetesApiService struct {
+	uploaded         bool
+	noShell          bool
+	tcpdumpStdErr    string
+	executedCommands [][]string
+}
+
+func (f *fakeKubernetesApiService) ExecuteCommand(podName string, containerName string, command []string, stdOut io.Writer) (int, error) {
+	f.executedCommands = append(f.executedCommands, command)
+	if f.noShell && command[0] == "/bin/sh" {
+		return 127, nil
+	}
+
+	return 0, nil
+}
+
+func (f *fakeKubernetesApiService) ExecuteCommandWithStdErr(podName string, containerName string, command []string,
+	stdOut io.Writer, stdErr io.Writer) (int, error) {
+
+	f.executedCommands = append(f.executedCommands, command)
+	if f.tcpdumpStdErr != "" {
+		_, _ = io.WriteString(stdErr, f.tcpdumpStdErr)
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
+func (f *fakeKubernetesApiService) DeletePod(podName string) error {
+	return nil
+}
+
+func (f *fakeKubernetesApiService) CreatePrivilegedPod(nodeName string, containerName string, image string, socketPath string, timeout time.Duration) (*corev1.Pod, error) {
+	return &corev1.Pod{}, nil
+}
+
+func (f *fakeKubernetesApiService) UploadFile(localPath string, remotePath string, podName string, containerName string) (bool, error) {
+	return f.uploaded, nil
+}
+
+func (f *fakeKubernetesApiService) UploadFileContent(content []byte, remotePath string, podName string, containerName string) (bool, error) {
+	return f.uploaded, nil
+}
+
+func TestBuildTcpdumpCommand_WithoutPidFile(t *testing.T) {
+	// when
+	command := buildTcpdumpCommand("/tmp/static-tcpdump", "any", "port 80", "")
+
+	// then
+	assert.Equal(t, []string{"/tmp/static-tcpdump", "-i", "any", "-U", "-w", "-", "port 80"}, command)
+}
+
+func TestBuildTcpdumpCommand_WithPidFile(t *testing.T) {
+	// when
+	command := buildTcpdumpCommand("/tmp/static-tcpdump", "any", "port 80", "/tmp/static-tcpdump-1.pid")
+
+	// then
+	assert.Equal(t, []string{"/bin/sh", "-c", `echo $$ > "$0" && exec "$@"`, "/tmp/static-tcpdump-1.pid",
+		"/tmp/static-tcpdump", "-i", "any", "-U", "-w", "-", "port 80"}, command)
+}
+
+func newLocalTcpdumpBinary(t *testing.T) string {
+	if _, err := exec.LookPath("/bin/sh"); err != nil {
+		t.Skip("no shell found")
+	}
+
+	tcpdumpPath := filepath.Join(t.TempDir(), "static-tcpdump")
+	assert.Nil(t, ioutil.WriteFile(tcpdumpPath, []byte{}, 0755))
+
+	return tcpdumpPath
+}
+
+func runRemoveBinaryCommand(t *testing.T, tcpdumpPath string) string {
+	command := buildRemoveBinaryCommand(tcpdumpPath)
+	output, err := exec.Command(command[0], command[1:]...).Output()
+	assert.Nil(t, err)
+
+	return string(output)
+}
+
+func TestBuildRemoveBinaryCommand_NoOtherCapture(t *testing.T) {
+	// given
+	tcpdumpPath := newLocalTcpdumpBinary(t)
+
+	// when
+	output := runRemoveBinaryCommand(t, tcpdumpPath)
+
+	// then
+	assert.Empty(t, output)
+	assert.NoFileExists(t, tcpdumpPath)
+}
+
+func TestBuildRemoveBinaryCommand_StalePidFile(t *testing.T) {
+	// given
+	tcpdumpPath := newLocalTcpdumpBinary(t)
+	process := exec.Command("true")
+	assert.Nil(t, process.Run())
+	assert.Nil(t, ioutil.WriteFile(tcpdumpPath+"-1.pid", []byte(strconv.Itoa(process.ProcessState.Pid())), 0644))
+
+	// when
+	output := runRemoveBinaryCommand(t, tcpdumpPath)
+
+	// then
+	assert.Empty(t, output)
+	assert.NoFileExists(t, tcpdumpPath)
+}
+
+func TestBuildRemoveBinaryCommand_OtherCaptureRunning(t *testing.T) {
+	// given
+	tcpdumpPath := newLocalTcpdumpBinary(t)
+	assert.Nil(t, ioutil.WriteFile(tcpdumpPath+"-1.pid", []byte(strconv.Itoa(os.Getpid())), 0644))
+
+	// when
+	output := runRemoveBinaryCommand(t, tcpdumpPath)
+
+	// then
+	assert.Equal(t, binaryInUse+"\n", output)
+	assert.FileExists(t, tcpdumpPath)
+}
+
+func TestStaticTcpdumpCleanup_RemovesUploadedBinary(t *testing.T) {
+	// given
+	service := &fakeKubernetesApiService{uploaded: true}
+	settings := &config.KsniffSettings{UserSpecifiedRemoteTcpdumpPath: "/tmp/static-tcpdump", UserSpecifiedLocalTcpdumpPath: "/dev/null"}
+	sniffer := NewUploadTcpdumpRemoteSniffingService(settings, service)
+
+	// when
+	assert.Nil(t, sniffer.Setup())
+	err := sniffer.Cleanup()
+
+	// then
+	assert.Nil(t, err)
+	assert.Contains(t, service.executedCommands, buildRemoveBinaryCommand("/tmp/static-tcpdump"))
+}
+
+func TestStaticTcpdumpCleanup_RemovesUploadedBinaryWithoutShell(t *testing.T) {
+	// given
+	service := &fakeKubernetesApiService{uploaded: true, noShell: true}
+	settings := &config.KsniffSettings{UserSpecifiedRemoteTcpdumpPath: "/tmp/static-tcpdump", UserSpecifiedLocalTcpdumpPath: "/dev/null"}
+	sniffer := NewUploadTcpdumpRemoteSniffingService(settings, service)
+
+	// when
+	assert.Nil(t, sniffer.Setup())
+	err := sniffer.Cleanup()
+
+	// then
+	assert.Nil(t, err)
+	assert.Contains(t, service.executedCommands, []string{"rm", "-f", "/tmp/static-tcpdump"})
+}
+
+func TestStaticTcpdumpCleanup_KeepsPreExistingBinary(t *testing.T) {
+	// given
+	service := &fakeKubernetesApiService{uploaded: false}
+	settings := &config.KsniffSettings{UserSpecifiedRemoteTcpdumpPath: "/tmp/static-tcpdump", UserSpecifiedLocalTcpdumpPath: "/dev/null"}
+	sniffer := NewUploadTcpdumpRemoteSniffingService(settings, service)
+
+	// when
+	assert.Nil(t, sniffer.Setup())
+	err := sniffer.Cleanup()
+
+	// then
+	assert.Nil(t, err)
+	assert.NotContains(t, service.executedCommands, buildRemoveBinaryCommand("/tmp/static-tcpdump"))
+}
+
+func TestStaticTcpdumpCleanup_KeepBinary(t *testing.T) {
+	// given
+	service := &fakeKubernetesApiService{uploaded: true}
+	settings := &config.KsniffSettings{UserSpecifiedRemoteTcpdumpPath: "/tmp/static-tcpdump", UserSpecifiedLocalTcpdumpPath: "/dev/null",
+		UserSpecifiedKeepBinary: true}
+	sniffer := NewUploadTcpdumpRemoteSniffingService(settings, service)
+
+	// when
+	assert.Nil(t, sniffer.Setup())
+	err := sniffer.Cleanup()
+
+	// then
+	assert.Nil(t, err)
+	assert.NotContains(t, service.executedCommands, buildRemoveBinaryCommand("/tmp/static-tcpdump"))
+}
+
+func TestStaticTcpdumpCleanup_StopsTcpdump(t *testing.T) {
+	// given
+	service := &fakeKubernetesApiService{uploaded: false}
+	settings := &config.KsniffSettings{UserSpecifiedRemoteTcpdumpPath: "/tmp/static-tcpdump", UserSpecifiedLocalTcpdumpPath: "/dev/null"}
+	sniffer := NewUploadTcpdumpRemoteSniffingService(settings, service)
+
+	// when
+	assert.Nil(t, sniffer.Setup())
+	assert.Nil(t, sniffer.Start(io.Discard))
+	err := sniffer.Cleanup()
+
+	// then
+	assert.Nil(t, err)
+	pidFilePath := sniffer.(*StaticTcpdumpSnifferService).pidFilePath
+	assert.NotEmpty(t, pidFilePath)
+	assert.Contains(t, service.executedCommands, buildStopCommand(pidFilePath))
+}
+
+func TestStaticTcpdumpStart_InvalidFilter(t *testing.T) {
+	// given
+	service := &fakeKubernetesApiService{tcpdumpStdErr: "tcpdump: syntax error in filter expression: syntax error\n"}
+	settings := &config.KsniffSettings{UserSpecifiedRemoteTcpdumpPath: "/tmp/static-tcpdump", UserSpecifiedFilter: "port port"}
+	sniffer := NewUploadTcpdumpRemoteSniffingService(settings, service)
+
+	// when
+	err := sniffer.Start(io.Discard)
+
+	// then
+	assert.True(t, errors.Is(err, ErrInvalidFilter))
+	assert.Contains(t, err.Error(), "syntax error in filter expression")
+}
@@ -0,0 +1,226 @@
+// Package stream reassembles the data of the captured tcp connections.
+package stream
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"ksniff/pkg/flow"
+	"ksniff/pkg/packet"
+)
+
+const (
+	// maxPendingBytes bounds the out of order data buffered by each direction of a connection, the connection is
+	// dropped when a missing segment wasn't captured.
+	maxPendingBytes = 1 << 20
+	// idleTimeout is the inactivity after which a connection whose closing wasn't captured is dropped.
+	idleTimeout     = 5 * time.Minute
+	expireInterval  = time.Minute
+	directionsCount = 2
+)
+
+// Handler receives the reassembled data of a connection, in the flow.ClientToServer and flow.ServerToClient
+// directions.
+type Handler interface {
+	Data(direction int, data []byte, timestamp time.Time)
+	// Close is called once the connection is closed or dropped, no data is received afterwards.
+	Close()
+}
+
+// HandlerFactory returns the handler of a new connection.
+type HandlerFactory func(client flow.Endpoint, server flow.Endpoint) Handler
+
+type segment struct {
+	seq       uint32
+	data      []byte
+	timestamp time.Time
+}
+
+type half struct {
+	started      bool
+	nextSeq      uint32
+	pending      []segment
+	pendingBytes int
+	closed       bool
+}
+
+type connection struct {
+	client   flow.Endpoint
+	server   flow.Endpoint
+	handler  Handler
+	halves   [directionsCount]half
+	lastSeen time.Time
+}
+
+// Assembler reorders the captured segments of the connections, and drops their retransmissions.
+type Assembler struct {
+	newHandler  HandlerFactory
+	mutex       sync.Mutex
+	connections map[flow.Key]*connection
+	lastExpire  time.Time
+}
+
+func NewAssembler(newHandler HandlerFactory) *Assembler {
+	return &Assembler{newHandler: newHandler, connections: map[flow.Key]*connection{}}
+}
+
+// after reports whether the sequence number a is after b, handling the sequence numbers wrap around.
+func after(a uint32, b uint32) bool {
+	return int32(a-b) > 0
+}
+
+// Add reassembles the tcp segment, the other packets are ignored.
+func (a *Assembler) Add(p *packet.Packet) {
+	if p.TCP == nil {
+		return
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.expire(p.Timestamp)
+
+	key := flow.NewKey(p)
+	src := flow.Endpoint{IP: p.SrcIP.String(), Port: p.SrcPort}
+	dst := flow.Endpoint{IP: p.DstIP.String(), Port: p.DstPort}
+	flags := p.TCP.Flags
+
+	c, ok := a.connections[key]
+	if !ok {
+		if flags&packet.FlagRST != 0 || (len(p.Payload) == 0 && flags&packet.FlagSYN == 0) {
+			return
+		}
+
+		c = &connection{client: src, server: dst}
+
+		// the connections opened before the capture have no SYN, their client usually uses an ephemeral port
+		switch {
+		case flags&(packet.FlagSYN|packet.FlagACK) == packet.FlagSYN|packet.FlagACK:
+			c.client, c.server = dst, src
+		case flags&packet.FlagSYN == 0 && src.Port < dst.Port:
+			c.client, c.server = dst, src
+		}
+
+		c.handler = a.newHandler(c.client, c.server)
+		a.connections[key] = c
+	}
+
+	c.lastSeen = p.Timestamp
+
+	direction := flow.ServerToClient
+	if src == c.client {
+		direction = flow.ClientToServer
+	}
+
+	if flags&packet.FlagRST != 0 {
+		a.close(key, c)
+		return
+	}
+
+	// the data is missing when the capture is truncated
+	if len(p.Payload) < p.PayloadLength {
+		a.close(key, c)
+		return
+	}
+
+	h := &c.halves[direction]
+	seq := p.TCP.Seq
+	if flags&packet.FlagSYN != 0 {
+		seq++
+	}
+
+	if !h.started {
+		h.started = true
+		h.nextSeq = seq
+	}
+
+	if len(p.Payload) > 0 && !h.add(c.handler, direction, segment{seq: seq, data: p.Payload, timestamp: p.Timestamp}) {
+		a.close(key, c)
+		return
+	}
+
+	if flags&packet.FlagFIN != 0 {
+		h.closed = true
+	}
+
+	if c.halves[flow.ClientToServer].closed && c.halves[flow.ServerToClient].closed {
+		a.close(key, c)
+	}
+}
+
+// add delivers the segment when it's the next one, and buffers it otherwise. It returns false when too much data
+// is missing.
+func (h *half) add(handler Handler, direction int, s segment) bool {
+	end := s.seq + uint32(len(s.data))
+
+	switch {
+	case !after(end, h.nextSeq):
+		// retransmission
+		return true
+	case after(s.seq, h.nextSeq):
+		if h.pendingBytes+len(s.data) > maxPendingBytes {
+			return false
+		}
+
+		// the segment data is owned by the capture reader
+		s.data = append([]byte(nil), s.data...)
+		h.pending = append(h.pending, s)
+		h.pendingBytes += len(s.data)
+		return true
+	}
+
+	h.deliver(handler, direction, s)
+
+	sort.Slice(h.pending, func(i, j int) bool {
+		return after(h.pending[j].seq, h.pending[i].seq)
+	})
+
+	for len(h.pending) > 0 && !after(h.pending[0].seq, h.nextSeq) {
+		next := h.pending[0]
+		h.pending = h.pending[1:]
+		h.pendingBytes -= len(next.data)
+
+		if after(next.seq+uint32(len(next.data)), h.nextSeq) {
+			h.deliver(handler, direction, next)
+		}
+	}
+
+	return true
+}
+
+// deliver passes the segment data following the already delivered data.
+func (h *half) deliver(handler Handler, direction int, s segment) {
+	data := s.data[h.nextSeq-s.seq:]
+	h.nextSeq += uint32(len(data))
+	handler.Data(direction, data, s.timestamp)
+}
+
+func (a *Assembler) close(key flow.Key, c *connection) {
+	delete(a.connections, key)
+	c.handler.Close()
+}
+
+// expire drops the idle connections.
+func (a *Assembler) expire(now time.Time) {
+	if now.Sub(a.lastExpire) < expireInterval {
+		return
+	}
+	a.lastExpire = now
+
+	for key, c := range a.connections {
+		if now.Sub(c.lastSeen) > idleTimeout {
+			a.close(key, c)
+		}
+	}
+}
+
+// Flush closes all the connections, e.g. at the end of the capture.
+func (a *Assembler) Flush() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for key, c := range a.connections {
+		a.close(key, c)
+	}
+}
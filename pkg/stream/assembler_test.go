@@ -0,0 +1,97 @@
+package stream
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"ksniff/pkg/flow"
+	"ksniff/pkg/packet"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeHandler struct {
+	data   [2]string
+	closed bool
+}
+
+func (f *fakeHandler) Data(direction int, data []byte, _ time.Time) {
+	f.data[direction] += string(data)
+}
+
+func (f *fakeHandler) Close() {
+	f.closed = true
+}
+
+func buildSegment(src string, srcPort uint16, dst string, dstPort uint16, seq uint32, flags uint8, payload string) *packet.Packet {
+	return &packet.Packet{Timestamp: time.Unix(1700000000, 0), SrcIP: net.ParseIP(src), SrcPort: srcPort,
+		DstIP: net.ParseIP(dst), DstPort: dstPort, Protocol: packet.ProtocolTCP,
+		TCP: &packet.TCP{Seq: seq, Flags: flags}, Payload: []byte(payload), PayloadLength: len(payload)}
+}
+
+func TestAssembler_ReordersSegments(t *testing.T) {
+	// given
+	handler := &fakeHandler{}
+	var client, server flow.Endpoint
+	assembler := NewAssembler(func(c flow.Endpoint, s flow.Endpoint) Handler {
+		client, server = c, s
+		return handler
+	})
+
+	// when
+	assembler.Add(buildSegment("10.0.0.1", 40000, "10.0.0.2", 80, 100, packet.FlagSYN, ""))
+	assembler.Add(buildSegment("10.0.0.2", 80, "10.0.0.1", 40000, 500, packet.FlagSYN|packet.FlagACK, ""))
+	assembler.Add(buildSegment("10.0.0.1", 40000, "10.0.0.2", 80, 106, packet.FlagACK, "world"))
+	assembler.Add(buildSegment("10.0.0.1", 40000, "10.0.0.2", 80, 101, packet.FlagACK, "hello"))
+	// retransmissions, the last one overlapping new data
+	assembler.Add(buildSegment("10.0.0.1", 40000, "10.0.0.2", 80, 101, packet.FlagACK, "hello"))
+	assembler.Add(buildSegment("10.0.0.1", 40000, "10.0.0.2", 80, 106, packet.FlagACK, "world!"))
+	assembler.Add(buildSegment("10.0.0.2", 80, "10.0.0.1", 40000, 501, packet.FlagACK|packet.FlagFIN, "bye"))
+	closedBeforeClientFin := handler.closed
+	assembler.Add(buildSegment("10.0.0.1", 40000, "10.0.0.2", 80, 112, packet.FlagACK|packet.FlagFIN, ""))
+
+	// then
+	assert.Equal(t, "10.0.0.1:40000", client.String())
+	assert.Equal(t, "10.0.0.2:80", server.String())
+	assert.Equal(t, "helloworld!", handler.data[flow.ClientToServer])
+	assert.Equal(t, "bye", handler.data[flow.ServerToClient])
+	assert.False(t, closedBeforeClientFin)
+	assert.True(t, handler.closed)
+}
+
+func TestAssembler_ConnectionOpenedBeforeCapture(t *testing.T) {
+	// given
+	handler := &fakeHandler{}
+	var client flow.Endpoint
+	assembler := NewAssembler(func(c flow.Endpoint, _ flow.Endpoint) Handler {
+		client = c
+		return handler
+	})
+
+	// when
+	assembler.Add(buildSegment("10.0.0.2", 80, "10.0.0.1", 40000, 900, packet.FlagACK, "response"))
+	assembler.Add(buildSegment("10.0.0.1", 40000, "10.0.0.2", 80, 300, packet.FlagRST, ""))
+
+	// then
+	assert.Equal(t, "10.0.0.1:40000", client.String())
+	assert.Equal(t, "response", handler.data[flow.ServerToClient])
+	assert.True(t, handler.closed)
+}
+
+func TestAssembler_TruncatedSegmentDropsConnection(t *testing.T) {
+	// given
+	handler := &fakeHandler{}
+	assembler := NewAssembler(func(flow.Endpoint, flow.Endpoint) Handler {
+		return handler
+	})
+	truncated := buildSegment("10.0.0.1", 40000, "10.0.0.2", 80, 100, packet.FlagACK, "hel")
+	truncated.PayloadLength = 5
+
+	// when
+	assembler.Add(truncated)
+
+	// then
+	assert.Equal(t, "", handler.data[flow.ClientToServer])
+	assert.True(t, handler.closed)
+}
@@ -0,0 +1,20 @@
+//go:build embed_tcpdump
+// +build embed_tcpdump
+
+package tcpdump
+
+import "embed"
+
+// embeddedBinaries holds the binaries copied to the bin directory by 'make embedded-binaries'.
+//
+//go:embed bin/static-tcpdump-*
+var embeddedBinaries embed.FS
+
+func embeddedBinary(name string) ([]byte, bool) {
+	content, err := embeddedBinaries.ReadFile("bin/" + name)
+	if err != nil {
+		return nil, false
+	}
+
+	return content, true
+}
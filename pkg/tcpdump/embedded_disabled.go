@@ -0,0 +1,8 @@
+//go:build !embed_tcpdump
+// +build !embed_tcpdump
+
+package tcpdump
+
+func embeddedBinary(string) ([]byte, bool) {
+	return nil, false
+}
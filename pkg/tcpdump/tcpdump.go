@@ -0,0 +1,16 @@
+package tcpdump
+
+import "fmt"
+
+const BinaryName = "static-tcpdump"
+
+// PlatformBinaryName returns the name of the static tcpdump binary built for the given node platform.
+func PlatformBinaryName(operatingSystem string, architecture string) string {
+	return fmt.Sprintf("%s-%s-%s", BinaryName, operatingSystem, architecture)
+}
+
+// Embedded returns the static tcpdump binary embedded in the ksniff executable for the given node platform.
+// Binaries are only embedded when ksniff is built with the 'embed_tcpdump' build tag.
+func Embedded(operatingSystem string, architecture string) ([]byte, bool) {
+	return embeddedBinary(PlatformBinaryName(operatingSystem, architecture))
+}
@@ -0,0 +1,15 @@
+package tcpdump
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlatformBinaryName(t *testing.T) {
+	// when
+	result := PlatformBinaryName("linux", "arm64")
+
+	// then
+	assert.Equal(t, "static-tcpdump-linux-arm64", result)
+}
@@ -0,0 +1,318 @@
+// Package tui shows a live terminal view of the captured connections and packets.
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ksniff/pkg/flow"
+	"ksniff/pkg/packet"
+	"ksniff/pkg/pcap"
+
+	"golang.org/x/term"
+)
+
+const (
+	refreshInterval = time.Second
+	maxPackets      = 200
+	maxLogs         = 3
+
+	enterScreen = "\x1b[?1049h\x1b[?25l"
+	leaveScreen = "\x1b[?25h\x1b[?1049l"
+	cursorHome  = "\x1b[H"
+	clearLine   = "\x1b[K"
+	clearBelow  = "\x1b[J"
+)
+
+// Names resolves the captured addresses to pod names.
+type Names interface {
+	Name(ip string) string
+}
+
+// View parses the capture stream and renders the flows table and the latest packets.
+type View struct {
+	out     io.Writer
+	title   string
+	names   Names
+	flows   *flow.Table
+	mutex   sync.Mutex
+	packets []string
+	logs    []string
+	total   uint64
+	bytes   uint64
+	started time.Time
+	closed  bool
+}
+
+func NewView(out io.Writer, title string, names Names) *View {
+	return &View{out: out, title: title, names: names, flows: flow.NewTable(), started: time.Now()}
+}
+
+// Consume reads the capture stream until its end.
+func (v *View) Consume(r io.Reader) error {
+	reader, err := pcap.OpenStream(r)
+	if err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+
+	for {
+		captured, err := reader.ReadPacket()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		v.add(captured)
+	}
+}
+
+func (v *View) add(captured *pcap.Packet) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	v.total++
+	v.bytes += uint64(captured.OriginalLength)
+
+	decoded, err := packet.Decode(captured)
+	if err != nil {
+		v.addPacket(fmt.Sprintf("%s %s", captured.Timestamp.Format("15:04:05.000"), err))
+		return
+	}
+
+	v.flows.Add(decoded)
+	v.addPacket(v.summary(decoded))
+}
+
+func (v *View) addPacket(summary string) {
+	v.packets = append(v.packets, summary)
+	if len(v.packets) > maxPackets {
+		v.packets = v.packets[len(v.packets)-maxPackets:]
+	}
+}
+
+// name returns the pod name of the ip, or the ip.
+func (v *View) name(ip string) string {
+	if v.names != nil {
+		if name := v.names.Name(ip); name != "" {
+			return name
+		}
+	}
+
+	return ip
+}
+
+func (v *View) endpoint(e flow.Endpoint) string {
+	if e.Port == 0 {
+		return v.name(e.IP)
+	}
+
+	name := v.name(e.IP)
+	if name == e.IP {
+		return e.String()
+	}
+
+	return fmt.Sprintf("%s:%d", name, e.Port)
+}
+
+func (v *View) summary(p *packet.Packet) string {
+	src := v.endpoint(flow.Endpoint{IP: p.SrcIP.String(), Port: p.SrcPort})
+	dst := v.endpoint(flow.Endpoint{IP: p.DstIP.String(), Port: p.DstPort})
+
+	summary := fmt.Sprintf("%s %s > %s %s", p.Timestamp.Format("15:04:05.000"), src, dst, packet.ProtocolName(p.Protocol))
+	if p.TCP != nil {
+		summary += fmt.Sprintf(" [%s]", packet.FlagNames(p.TCP.Flags))
+	}
+
+	return summary + fmt.Sprintf(" length %d", p.PayloadLength)
+}
+
+// Write shows the log lines below the packets, writing them to the terminal would break the view.
+func (v *View) Write(p []byte) (int, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		v.logs = append(v.logs, line)
+	}
+	if len(v.logs) > maxLogs {
+		v.logs = v.logs[len(v.logs)-maxLogs:]
+	}
+
+	return len(p), nil
+}
+
+func formatBytes(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%dB", bytes)
+	}
+
+	value, suffix := float64(bytes), ""
+	for _, suffix = range []string{"KiB", "MiB", "GiB", "TiB"} {
+		value /= unit
+		if value < unit {
+			break
+		}
+	}
+
+	return fmt.Sprintf("%.1f%s", value, suffix)
+}
+
+func formatRTT(rtt time.Duration) string {
+	if rtt == 0 {
+		return "-"
+	}
+
+	return rtt.Round(10 * time.Microsecond).String()
+}
+
+// fit truncates or pads the text to the width.
+func fit(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) > width {
+		if width <= 1 {
+			return string(runes[:width])
+		}
+		return string(runes[:width-1]) + "~"
+	}
+
+	return text + strings.Repeat(" ", width-len(runes))
+}
+
+// Render returns the view lines fitting the terminal size, the busiest flows first.
+func (v *View) Render(width int, height int) []string {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	flows := v.flows.Flows()
+	sort.SliceStable(flows, func(i, j int) bool {
+		return flows[i].TotalBytes() > flows[j].TotalBytes()
+	})
+
+	lines := []string{
+		fmt.Sprintf("ksniff %s | elapsed %s | packets %d | bytes %s | flows %d", v.title,
+			time.Since(v.started).Round(time.Second), v.total, formatBytes(v.bytes), len(flows)),
+		"",
+	}
+
+	packetsHeight := height / 3
+	flowsHeight := height - len(lines) - packetsHeight - len(v.logs) - 3
+	if flowsHeight < 1 {
+		flowsHeight = 1
+	}
+
+	endpointWidth := (width - 68) / 2
+	if endpointWidth < 21 {
+		endpointWidth = 21
+	}
+
+	lines = append(lines, fmt.Sprintf("%s %s %s %s %s %s %s %s %s", fit("PROTO", 6), fit("CLIENT", endpointWidth),
+		fit("SERVER", endpointWidth), fit("STATE", 11), fit("PACKETS", 8), fit("BYTES", 9), fit("RTT", 9),
+		fit("RST", 4), "RETX"))
+
+	for i, f := range flows {
+		if i == flowsHeight {
+			break
+		}
+
+		lines = append(lines, fmt.Sprintf("%s %s %s %s %s %s %s %s %d", fit(packet.ProtocolName(f.Protocol), 6),
+			fit(v.endpoint(f.Client), endpointWidth), fit(v.endpoint(f.Server), endpointWidth), fit(f.State, 11),
+			fit(fmt.Sprint(f.TotalPackets()), 8), fit(formatBytes(f.TotalBytes()), 9), fit(formatRTT(f.RTT), 9),
+			fit(fmt.Sprint(f.Resets), 4), f.Retransmissions))
+	}
+
+	for len(lines) < flowsHeight+3 {
+		lines = append(lines, "")
+	}
+
+	lines = append(lines, "", "PACKETS")
+
+	packets := v.packets
+	if len(packets) > packetsHeight {
+		packets = packets[len(packets)-packetsHeight:]
+	}
+	lines = append(lines, packets...)
+	lines = append(lines, v.logs...)
+
+	for i := range lines {
+		lines[i] = strings.TrimRight(fit(lines[i], width), " ")
+	}
+
+	return lines
+}
+
+func (v *View) size() (int, int) {
+	if file, ok := v.out.(*os.File); ok {
+		if width, height, err := term.GetSize(int(file.Fd())); err == nil {
+			return width, height
+		}
+	}
+
+	return 120, 40
+}
+
+// draw redraws the screen, it returns false once the view is closed.
+func (v *View) draw() bool {
+	width, height := v.size()
+
+	var screen strings.Builder
+	screen.WriteString(cursorHome)
+	screen.WriteString(strings.Join(v.Render(width, height), clearLine+"\r\n"))
+	screen.WriteString(clearLine + clearBelow)
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if v.closed {
+		return false
+	}
+
+	_, _ = io.WriteString(v.out, screen.String())
+
+	return true
+}
+
+// Run draws the view every second until the context is done or the view is closed.
+func (v *View) Run(ctx context.Context) {
+	_, _ = io.WriteString(v.out, enterScreen)
+
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	for v.draw() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close restores the terminal screen.
+func (v *View) Close() error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if !v.closed {
+		v.closed = true
+		_, _ = io.WriteString(v.out, leaveScreen)
+	}
+
+	return nil
+}
+
+// IsTerminal reports whether the output is a terminal the view can be drawn on.
+func IsTerminal(out *os.File) bool {
+	return term.IsTerminal(int(out.Fd()))
+}
@@ -0,0 +1,79 @@
+package tui
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"ksniff/pkg/pcap"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeNames map[string]string
+
+func (f fakeNames) Name(ip string) string {
+	return f[ip]
+}
+
+// buildFrame builds a linux cooked v2 tcp frame.
+func buildFrame(src string, srcPort uint16, dst string, dstPort uint16, flags uint8) *pcap.Packet {
+	frame := make([]byte, 20+20+20)
+	binary.BigEndian.PutUint16(frame[0:2], 0x0800)
+
+	ip := frame[20:40]
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:4], 40)
+	ip[9] = 6
+	copy(ip[12:16], net.ParseIP(src).To4())
+	copy(ip[16:20], net.ParseIP(dst).To4())
+
+	tcp := frame[40:]
+	binary.BigEndian.PutUint16(tcp[0:2], srcPort)
+	binary.BigEndian.PutUint16(tcp[2:4], dstPort)
+	tcp[12] = 5 << 4
+	tcp[13] = flags
+
+	return &pcap.Packet{LinkType: pcap.LinkTypeLinuxSLL2, Timestamp: time.Unix(1700000000, 0), Data: frame,
+		CaptureLength: uint32(len(frame)), OriginalLength: uint32(len(frame))}
+}
+
+func TestView_Render(t *testing.T) {
+	// given
+	view := NewView(nil, "default/web-0/web", fakeNames{"10.0.0.1": "default/web-0"})
+	view.add(buildFrame("10.0.0.1", 40000, "10.0.0.2", 6379, 0x02))
+	view.add(buildFrame("10.0.0.2", 6379, "10.0.0.1", 40000, 0x04|0x10))
+	_, _ = view.Write([]byte("level=info msg=\"capture filter updated\"\n"))
+
+	// when
+	lines := view.Render(160, 30)
+
+	// then
+	screen := strings.Join(lines, "\n")
+	assert.True(t, len(lines) <= 30)
+	assert.Contains(t, lines[0], "packets 2")
+	assert.Contains(t, screen, "default/web-0:40000")
+	assert.Contains(t, screen, "10.0.0.2:6379")
+	assert.Contains(t, screen, "reset")
+	assert.Contains(t, screen, "[RST,ACK]")
+	assert.Contains(t, lines[len(lines)-1], "capture filter updated")
+}
+
+func TestView_RenderFitsTerminal(t *testing.T) {
+	// given
+	view := NewView(nil, "default/web-0/web", nil)
+	for port := uint16(40000); port < 40100; port++ {
+		view.add(buildFrame("10.0.0.1", port, "10.0.0.2", 6379, 0x02))
+	}
+
+	// when
+	lines := view.Render(80, 30)
+
+	// then
+	assert.Equal(t, 30, len(lines))
+	for _, line := range lines {
+		assert.True(t, len([]rune(line)) <= 80)
+	}
+}
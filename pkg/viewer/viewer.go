@@ -0,0 +1,227 @@
+// Package viewer builds the local command displaying the capture, e.g. wireshark or tshark.
+package viewer
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// Data is available to the viewer argument templates, e.g. '{{.Title}}'.
+type Data struct {
+	Title     string
+	Namespace string
+	Pod       string
+	Container string
+	// Filter is the tcpdump capture filter.
+	Filter string
+	// DecodeAs lists the wireshark decode as rules, e.g. 'tcp.port==8080,http'.
+	DecodeAs []string
+}
+
+// Viewer is a command reading the capture from its stdin, its arguments are templates.
+type Viewer struct {
+	Name string
+	args []string
+	// decodeAsFlag is passed before each decode as rule appended to the arguments, the rules aren't appended when
+	// it's empty.
+	decodeAsFlag string
+}
+
+var builtinViewers = map[string]*Viewer{
+	"wireshark": {Name: "wireshark", args: []string{"wireshark", "-k", "-i", "-", "-o", "gui.window_title:{{.Title}}"}, decodeAsFlag: "-d"},
+	"tshark":    {Name: "tshark", args: []string{"tshark", "-i", "-"}, decodeAsFlag: "-d"},
+	// termshark reads the capture from its stdin when it's a pipe, and uses the terminal for its interface
+	"termshark": {Name: "termshark", args: []string{"termshark"}, decodeAsFlag: "-d"},
+}
+
+// BuiltinViewers lists the viewers available by name.
+var BuiltinViewers = []string{"wireshark", "tshark", "termshark"}
+
+var lookPath = exec.LookPath
+var getenv = os.Getenv
+
+var templateFunctions = template.FuncMap{"join": strings.Join}
+
+// New returns the builtin viewer with the given name, or a viewer running the given command template,
+// e.g. 'tshark -i - -Y http'. The decode as rules are appended as '-d <rule>' arguments like for the builtin
+// viewers, unless the template uses them, e.g. 'myviewer {{if .DecodeAs}}--rules={{join .DecodeAs ";"}}{{end}}'.
+func New(spec string) (*Viewer, error) {
+	if viewer, ok := builtinViewers[spec]; ok {
+		return viewer, nil
+	}
+
+	args, err := splitCommand(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) == 0 {
+		return nil, errors.New("viewer command is empty")
+	}
+
+	viewer := &Viewer{Name: args[0], args: args, decodeAsFlag: "-d"}
+
+	for _, arg := range args {
+		if _, err := template.New("viewer").Funcs(templateFunctions).Parse(arg); err != nil {
+			return nil, errors.Wrapf(err, "invalid viewer command template: '%s'", spec)
+		}
+
+		if strings.Contains(arg, ".DecodeAs") {
+			viewer.decodeAsFlag = ""
+		}
+	}
+
+	return viewer, nil
+}
+
+// hasDisplay reports whether a graphical viewer can be used, ssh sessions usually have no display.
+func hasDisplay() bool {
+	switch runtime.GOOS {
+	case "darwin", "windows":
+		return true
+	default:
+		return getenv("DISPLAY") != "" || getenv("WAYLAND_DISPLAY") != ""
+	}
+}
+
+// Detect returns the first installed builtin viewer, wireshark is only used when a display is available.
+func Detect() (*Viewer, error) {
+	names := []string{"termshark", "tshark"}
+	if hasDisplay() {
+		names = append([]string{"wireshark"}, names...)
+	}
+
+	for _, name := range names {
+		if _, err := lookPath(name); err == nil {
+			return builtinViewers[name], nil
+		}
+	}
+
+	return nil, errors.Errorf("no viewer found, install one of: %v, or use '-o' to write the capture to a file or "+
+		"stdout", names)
+}
+
+// Path returns the path of the viewer executable.
+func (v *Viewer) Path() (string, error) {
+	path, err := lookPath(v.args[0])
+	if err != nil {
+		return "", errors.Errorf("viewer '%s' not found, install it or choose another viewer using '--viewer' "+
+			"(one of: %v, or a command template)", v.args[0], BuiltinViewers)
+	}
+
+	return path, nil
+}
+
+// Args renders the argument templates, the arguments rendered empty are omitted.
+func (v *Viewer) Args(data *Data) ([]string, error) {
+	var args []string
+
+	for _, arg := range v.args[1:] {
+		parsed, err := template.New("viewer").Funcs(templateFunctions).Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		rendered := new(bytes.Buffer)
+		if err := parsed.Execute(rendered, data); err != nil {
+			return nil, errors.Wrapf(err, "failed to render viewer argument: '%s'", arg)
+		}
+
+		if rendered.Len() == 0 && strings.Contains(arg, "{{") {
+			continue
+		}
+
+		args = append(args, rendered.String())
+	}
+
+	if v.decodeAsFlag != "" {
+		for _, decodeAs := range data.DecodeAs {
+			args = append(args, v.decodeAsFlag, decodeAs)
+		}
+	}
+
+	return args, nil
+}
+
+// Command returns the viewer command, reading the capture from its stdin.
+func (v *Viewer) Command(data *Data) (*exec.Cmd, error) {
+	path, err := v.Path()
+	if err != nil {
+		return nil, err
+	}
+
+	args, err := v.Args(data)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(path, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd, nil
+}
+
+// splitCommand splits the command template into arguments, on spaces outside quotes and template actions.
+func splitCommand(command string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg, templateDepth := false, 0
+
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case templateDepth > 0:
+			if r == '}' && i+1 < len(runes) && runes[i+1] == '}' {
+				templateDepth--
+				current.WriteString("}}")
+				i++
+				continue
+			}
+			current.WriteRune(r)
+		case r == '{' && i+1 < len(runes) && runes[i+1] == '{':
+			templateDepth++
+			inArg = true
+			current.WriteString("{{")
+			i++
+		case quote != 0:
+			if r == quote {
+				quote = 0
+				continue
+			}
+			current.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 || templateDepth > 0 {
+		return nil, errors.Errorf("unterminated quote or template action in viewer command: '%s'", command)
+	}
+
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
@@ -0,0 +1,119 @@
+package viewer
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func stubLookPath(installed ...string) func(string) (string, error) {
+	return func(file string) (string, error) {
+		for _, name := range installed {
+			if name == file {
+				return "/usr/bin/" + file, nil
+			}
+		}
+		return "", exec.ErrNotFound
+	}
+}
+
+func TestNew_Wireshark(t *testing.T) {
+	// given
+	viewer, err := New("wireshark")
+	assert.Nil(t, err)
+
+	// when
+	args, err := viewer.Args(&Data{Title: "default/redis-0/redis", DecodeAs: []string{"tcp.port==6380,redis"}})
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"-k", "-i", "-", "-o", "gui.window_title:default/redis-0/redis", "-d", "tcp.port==6380,redis"}, args)
+}
+
+func TestNew_CommandTemplate(t *testing.T) {
+	// given
+	viewer, err := New(`tshark -i - -Y "http and tcp" --title {{ .Pod }} {{.Filter}}`)
+	assert.Nil(t, err)
+
+	// when
+	args, err := viewer.Args(&Data{Pod: "redis-0", DecodeAs: []string{"tcp.port==6380,redis", "tcp.port==8080,http"}})
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, "tshark", viewer.Name)
+	assert.Equal(t, []string{"-i", "-", "-Y", "http and tcp", "--title", "redis-0",
+		"-d", "tcp.port==6380,redis", "-d", "tcp.port==8080,http"}, args)
+}
+
+func TestNew_CommandTemplateUsingDecodeAs(t *testing.T) {
+	// given
+	viewer, err := New(`myviewer {{if .DecodeAs}}--decode-as={{join .DecodeAs ";"}}{{end}}`)
+	assert.Nil(t, err)
+
+	// when
+	args, err := viewer.Args(&Data{DecodeAs: []string{"tcp.port==6380,redis", "tcp.port==8080,http"}})
+	emptyArgs, emptyErr := viewer.Args(&Data{})
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"--decode-as=tcp.port==6380,redis;tcp.port==8080,http"}, args)
+	assert.Nil(t, emptyErr)
+	assert.Empty(t, emptyArgs)
+}
+
+func TestNew_InvalidCommandTemplate(t *testing.T) {
+	// when
+	_, err := New("tshark -i - {{.Pod")
+
+	// then
+	assert.NotNil(t, err)
+}
+
+func TestPath_MissingViewer(t *testing.T) {
+	// given
+	lookPath = stubLookPath("tshark")
+	defer func() { lookPath = exec.LookPath }()
+
+	viewer, _ := New("termshark")
+
+	// when
+	_, err := viewer.Path()
+
+	// then
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "viewer 'termshark' not found"))
+}
+
+func TestDetect_WithoutDisplay(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("a display is always available")
+	}
+
+	// given
+	lookPath = stubLookPath("wireshark", "tshark")
+	getenv = func(string) string { return "" }
+	defer func() { lookPath, getenv = exec.LookPath, os.Getenv }()
+
+	// when
+	viewer, err := Detect()
+
+	// then
+	assert.Nil(t, err)
+	assert.Equal(t, "tshark", viewer.Name)
+}
+
+func TestDetect_NoViewer(t *testing.T) {
+	// given
+	lookPath = stubLookPath()
+	defer func() { lookPath = exec.LookPath }()
+
+	// when
+	_, err := Detect()
+
+	// then
+	assert.NotNil(t, err)
+}